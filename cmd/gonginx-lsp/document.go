@@ -0,0 +1,445 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/utils"
+)
+
+const diagnosticSource = "gonginx"
+
+// document is an open file together with the result of its last analysis
+type document struct {
+	uri         string
+	text        string
+	conf        *config.Config // nil if the document does not parse
+	diagnostics []diagnostic
+}
+
+// newDocument parses the text and collects its diagnostics, the validators
+// run on whatever the parser could recover from syntax errors
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, text: text, diagnostics: []diagnostic{}}
+	conf, err := parser.NewStringParser(text, parser.WithErrorRecovery()).Parse()
+	if syntaxErrors, ok := err.(parser.ErrorList); ok {
+		for _, syntaxErr := range syntaxErrors {
+			doc.diagnostics = append(doc.diagnostics, doc.syntaxDiagnostic(syntaxErr))
+		}
+	} else if err != nil {
+		pos := doc.positionAt(len(doc.text))
+		doc.diagnostics = append(doc.diagnostics, diagnostic{
+			Range:    lspRange{Start: pos, End: pos},
+			Severity: severityError,
+			Source:   diagnosticSource,
+			Message:  err.Error(),
+		})
+		return doc
+	}
+	doc.conf = conf
+	doc.validate()
+	return doc
+}
+
+// syntaxDiagnostic reports a syntax error at the token it was found at
+func (doc *document) syntaxDiagnostic(err *parser.SyntaxError) diagnostic {
+	pos := doc.positionAt(err.Pos.Offset)
+	return diagnostic{
+		Range:    lspRange{Start: pos, End: pos},
+		Severity: severityError,
+		Source:   diagnosticSource,
+		Message:  err.Message,
+	}
+}
+
+// validate runs the context, dependency and security checks on a parsed document
+func (doc *document) validate() {
+	for _, err := range config.NewContextValidator().ValidateConfig(doc.conf) {
+		if cve, ok := err.(*config.ContextValidationError); ok {
+			doc.addDiagnostic(doc.findDirective(cve.Directive, cve.Line), severityError,
+				strings.TrimPrefix(cve.Error(), fmt.Sprintf("line %d: ", cve.Line)))
+		}
+	}
+	for _, err := range config.NewDependencyValidator().ValidateDependencies(doc.conf) {
+		if dve, ok := err.(*config.DependencyValidationError); ok {
+			doc.addDiagnostic(doc.findDirective(dve.Directive, dve.Line), severityWarning,
+				strings.TrimPrefix(dve.Error(), fmt.Sprintf("line %d: ", dve.Line)))
+		}
+	}
+	for _, issue := range utils.CheckSecurity(doc.conf).Issues {
+		severity := severityHint
+		switch issue.Level {
+		case utils.SecurityCritical:
+			severity = severityError
+		case utils.SecurityWarning:
+			severity = severityWarning
+		}
+		message := issue.Title + ": " + issue.Description
+		if issue.Fix != "" {
+			message += " (fix: " + issue.Fix + ")"
+		}
+		doc.addDiagnostic(doc.findDirective(issue.Directive, 0), severity, message)
+	}
+}
+
+// addDiagnostic reports a problem on the name of a directive, on the first line if the directive is unknown
+func (doc *document) addDiagnostic(d config.IDirective, severity int, message string) {
+	var r lspRange
+	if dr := config.RangeOf(d); d != nil && dr != nil {
+		r = doc.lspRange(dr.Name)
+	}
+	doc.diagnostics = append(doc.diagnostics, diagnostic{
+		Range:    r,
+		Severity: severity,
+		Source:   diagnosticSource,
+		Message:  message,
+	})
+}
+
+// findDirective returns the first directive with the name, on the line if it is not zero
+func (doc *document) findDirective(name string, line int) config.IDirective {
+	var found config.IDirective
+	walk(doc.conf, func(d config.IDirective) bool {
+		if found == nil && d.GetName() == name && (line == 0 || d.GetLine() == line) {
+			found = d
+		}
+		return found == nil
+	})
+	return found
+}
+
+// completion lists the directives allowed in the context at the position,
+// directives without known context rules are offered after them
+func (doc *document) completion(pos position) []completionItem {
+	context := contextAt(doc.text[:doc.offsetAt(pos)])
+	switch context {
+	case "map", "geo", "split_clients", "types", "lua":
+		return []completionItem{}
+	}
+
+	validator := config.NewContextValidator()
+	items := []completionItem{}
+	for _, name := range config.KnownDirectives() {
+		allowed := validator.GetAllowedContexts(name)
+		switch {
+		case validator.IsDirectiveAllowedInContext(name, context):
+			items = append(items, completionItem{
+				Label:    name,
+				Kind:     completionKindKeyword,
+				Detail:   "context: " + strings.Join(allowed, ", "),
+				SortText: "0" + name,
+			})
+		case len(allowed) == 0:
+			items = append(items, completionItem{
+				Label:    name,
+				Kind:     completionKindKeyword,
+				SortText: "1" + name,
+			})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].SortText < items[j].SortText
+	})
+	return items
+}
+
+// hover describes the directive whose name is under the position
+func (doc *document) hover(pos position) *hover {
+	d := doc.directiveAt(pos)
+	if d == nil {
+		return nil
+	}
+	name := d.GetName()
+	if !config.IsKnownDirective(name) {
+		return nil
+	}
+	dr := config.RangeOf(d)
+	if !dr.Name.Contains(doc.configPosition(pos)) {
+		return nil
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "```nginx\n%s\n```\n", name)
+	if contexts := config.NewContextValidator().GetAllowedContexts(name); len(contexts) > 0 {
+		fmt.Fprintf(&buf, "\nContext: `%s`\n", strings.Join(contexts, "`, `"))
+	}
+	if schema, ok := config.LookupDirective(name, ""); ok && schema.Default != "" {
+		fmt.Fprintf(&buf, "\nDefault: `%s %s;`\n", name, schema.Default)
+	}
+	fmt.Fprintf(&buf, "\n[Documentation](https://nginx.org/r/%s)", name)
+
+	r := doc.lspRange(dr.Name)
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: buf.String()},
+		Range:    &r,
+	}
+}
+
+// definition jumps from a proxy_pass (or another *_pass) target to its upstream block
+func (doc *document) definition(pos position) *location {
+	d := doc.directiveAt(pos)
+	if d == nil || !strings.HasSuffix(d.GetName(), "_pass") || len(d.GetParameters()) == 0 {
+		return nil
+	}
+	name := upstreamName(d.GetParameters()[0].GetValue())
+	var upstream config.IDirective
+	walk(doc.conf, func(directive config.IDirective) bool {
+		if upstream == nil && directive.GetName() == "upstream" && directive.GetBlock() != nil {
+			if params := directive.GetParameters(); len(params) > 0 && params[0].GetValue() == name {
+				upstream = directive
+			}
+		}
+		return upstream == nil
+	})
+	dr := config.RangeOf(upstream)
+	if upstream == nil || dr == nil {
+		return nil
+	}
+	return &location{URI: doc.uri, Range: doc.lspRange(dr.Range)}
+}
+
+// upstreamName extracts the host of a proxy_pass target, http://backend/path gives backend
+func upstreamName(target string) string {
+	if i := strings.Index(target, "://"); i >= 0 {
+		target = target[i+3:]
+	}
+	if i := strings.IndexAny(target, "/:"); i >= 0 {
+		target = target[:i]
+	}
+	return target
+}
+
+// symbols returns the servers of the document with their locations nested inside them
+func (doc *document) symbols() []documentSymbol {
+	if doc.conf == nil {
+		return []documentSymbol{}
+	}
+	var symbols []documentSymbol
+	walk(doc.conf, func(d config.IDirective) bool {
+		if d.GetName() != "server" || d.GetBlock() == nil {
+			return true
+		}
+		if symbol, ok := doc.newSymbol(d, serverLabel(d), symbolKindNamespace); ok {
+			symbol.Children = doc.locationSymbols(d.GetBlock())
+			symbols = append(symbols, symbol)
+		}
+		return false
+	})
+	if symbols == nil {
+		return []documentSymbol{}
+	}
+	return symbols
+}
+
+// locationSymbols collects the locations of a block, nested locations become children
+func (doc *document) locationSymbols(block config.IBlock) []documentSymbol {
+	var symbols []documentSymbol
+	for _, d := range block.GetDirectives() {
+		if d.GetBlock() == nil {
+			continue
+		}
+		if d.GetName() != "location" {
+			// locations may be nested in if or limit_except blocks
+			symbols = append(symbols, doc.locationSymbols(d.GetBlock())...)
+			continue
+		}
+		params := make([]string, 0, len(d.GetParameters()))
+		for _, param := range d.GetParameters() {
+			params = append(params, param.GetValue())
+		}
+		if symbol, ok := doc.newSymbol(d, "location "+strings.Join(params, " "), symbolKindClass); ok {
+			symbol.Children = doc.locationSymbols(d.GetBlock())
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+func (doc *document) newSymbol(d config.IDirective, name string, kind int) (documentSymbol, bool) {
+	dr := config.RangeOf(d)
+	if dr == nil {
+		return documentSymbol{}, false
+	}
+	return documentSymbol{
+		Name:           name,
+		Kind:           kind,
+		Range:          doc.lspRange(dr.Range),
+		SelectionRange: doc.lspRange(dr.Name),
+	}, true
+}
+
+// serverLabel names a server after its server_name, or its listen address when it has none
+func serverLabel(server config.IDirective) string {
+	for _, name := range []string{"server_name", "listen"} {
+		for _, d := range server.GetBlock().GetDirectives() {
+			if d.GetName() != name {
+				continue
+			}
+			values := make([]string, 0, len(d.GetParameters()))
+			for _, param := range d.GetParameters() {
+				values = append(values, param.GetValue())
+			}
+			return "server " + strings.Join(values, " ")
+		}
+	}
+	return "server"
+}
+
+// directiveAt returns the innermost directive whose source range contains the position
+func (doc *document) directiveAt(pos position) config.IDirective {
+	if doc.conf == nil {
+		return nil
+	}
+	p := doc.configPosition(pos)
+	var found config.IDirective
+	walk(doc.conf, func(d config.IDirective) bool {
+		dr := config.RangeOf(d)
+		if dr == nil || !dr.Contains(p) {
+			return false
+		}
+		found = d
+		return true
+	})
+	return found
+}
+
+// walk calls fn for every directive depth first, children are skipped when fn returns false
+func walk(block config.IBlock, fn func(config.IDirective) bool) {
+	if block == nil {
+		return
+	}
+	for _, d := range block.GetDirectives() {
+		if fn(d) && d.GetBlock() != nil {
+			walk(d.GetBlock(), fn)
+		}
+	}
+}
+
+// contextAt returns the context the end of the text is in, following the
+// names used by config.ContextValidator
+func contextAt(text string) string {
+	contexts := []string{"main"}
+	var words []string
+	var word strings.Builder
+	endWord := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '#':
+			endWord()
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case ch == '"' || ch == '\'':
+			for i++; i < len(text) && text[i] != ch; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+			word.WriteString("\"\"")
+		case ch == ';':
+			endWord()
+			words = words[:0]
+		case ch == '{':
+			endWord()
+			name := ""
+			if len(words) > 0 {
+				name = words[0]
+			}
+			contexts = append(contexts, nestedContext(name, contexts[len(contexts)-1]))
+			words = words[:0]
+		case ch == '}':
+			endWord()
+			if len(contexts) > 1 {
+				contexts = contexts[:len(contexts)-1]
+			}
+			words = words[:0]
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			endWord()
+		default:
+			word.WriteByte(ch)
+		}
+	}
+	return contexts[len(contexts)-1]
+}
+
+// nestedContext returns the context of a block opened by the directive inside the parent context
+func nestedContext(name, parent string) string {
+	switch {
+	case name == "server" && parent == "stream":
+		return "stream_server"
+	case name == "upstream" && parent == "stream":
+		return "stream_upstream"
+	case strings.HasSuffix(name, "_by_lua_block") || strings.HasSuffix(name, "_lua_block"):
+		return "lua"
+	case parent == "lua":
+		return "lua"
+	}
+	return name
+}
+
+// offsetAt converts a protocol position to a byte offset in the text, characters are
+// counted in UTF-16 code units as the protocol requires by default
+func (doc *document) offsetAt(pos position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(doc.text[offset:], '\n')
+		if i < 0 {
+			return len(doc.text)
+		}
+		offset += i + 1
+	}
+	for character := 0; character < pos.Character && offset < len(doc.text); {
+		ch, size := utf8.DecodeRuneInString(doc.text[offset:])
+		if ch == '\n' {
+			break
+		}
+		character += utf16Len(ch)
+		offset += size
+	}
+	return offset
+}
+
+// positionAt converts a byte offset in the text to a protocol position
+func (doc *document) positionAt(offset int) position {
+	offset = min(max(offset, 0), len(doc.text))
+	before := doc.text[:offset]
+	line := strings.Count(before, "\n")
+	character := 0
+	for _, ch := range before[strings.LastIndexByte(before, '\n')+1:] {
+		character += utf16Len(ch)
+	}
+	return position{Line: line, Character: character}
+}
+
+// utf16Len returns the number of UTF-16 code units of a character, characters outside
+// the basic multilingual plane take a surrogate pair
+func utf16Len(ch rune) int {
+	if ch >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// configPosition converts a protocol position to a parser position
+func (doc *document) configPosition(pos position) config.Position {
+	offset := doc.offsetAt(pos)
+	lineStart := strings.LastIndexByte(doc.text[:offset], '\n') + 1
+	return config.Position{Line: pos.Line + 1, Column: utf8.RuneCountInString(doc.text[lineStart:offset]) + 1, Offset: offset}
+}
+
+// lspRange converts a parser range to a protocol range, going through the byte offsets
+// since the parser counts columns in characters and the protocol in UTF-16 code units
+func (doc *document) lspRange(r config.Range) lspRange {
+	return lspRange{Start: doc.positionAt(r.Start.Offset), End: doc.positionAt(r.End.Offset)}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const testConfig = `http {
+    upstream backend {
+        server 10.0.0.1:80;
+    }
+    server {
+        listen 80;
+        server_name api.example.com;
+        location /v1 {
+            proxy_pass http://backend/v1;
+            location /v1/admin {
+                deny all;
+            }
+        }
+    }
+}
+`
+
+// findDiagnostic returns the first diagnostic whose message contains the text
+func findDiagnostic(doc *document, text string) *diagnostic {
+	for i, d := range doc.diagnostics {
+		if strings.Contains(d.Message, text) {
+			return &doc.diagnostics[i]
+		}
+	}
+	return nil
+}
+
+func TestDocument_ParseError(t *testing.T) {
+	t.Parallel()
+	doc := newDocument("file:///nginx.conf", "http {\n    foo_bar on;\n    server {\n        listen 80\n    }\n}\n")
+	found := findDiagnostic(doc, "unknown directive 'foo_bar'")
+	assert.Assert(t, found != nil, "%+v", doc.diagnostics)
+	assert.Equal(t, found.Severity, severityError)
+	assert.Equal(t, found.Range.Start, position{Line: 1, Character: 4})
+
+	// parsing goes on after the first error
+	found = findDiagnostic(doc, "unexpected token BlockEnd")
+	assert.Assert(t, found != nil, "%+v", doc.diagnostics)
+	assert.Equal(t, found.Range.Start, position{Line: 4, Character: 4})
+	assert.Assert(t, doc.conf != nil)
+	assert.Equal(t, len(doc.symbols()), 1)
+}
+
+func TestDocument_UnterminatedQuote(t *testing.T) {
+	t.Parallel()
+	doc := newDocument("file:///nginx.conf", "user \"nginx;\n")
+	found := findDiagnostic(doc, "unclosed quote")
+	assert.Assert(t, found != nil, "%+v", doc.diagnostics)
+	assert.Equal(t, found.Range.Start, position{Line: 0, Character: 5})
+}
+
+func TestDocument_ContextDiagnostic(t *testing.T) {
+	t.Parallel()
+	doc := newDocument("file:///nginx.conf", "http {\n    listen 80;\n}\n")
+	found := findDiagnostic(doc, "'listen' is not allowed in 'http'")
+	assert.Assert(t, found != nil, "%+v", doc.diagnostics)
+	assert.Equal(t, found.Severity, severityError)
+	assert.Equal(t, found.Range, lspRange{Start: position{Line: 1, Character: 4}, End: position{Line: 1, Character: 10}})
+}
+
+func TestDocument_Completion(t *testing.T) {
+	t.Parallel()
+	text := "http {\n    server {\n        \n    }\n}\n"
+	doc := newDocument("file:///nginx.conf", text)
+
+	labels := map[string]completionItem{}
+	for _, item := range doc.completion(position{Line: 2, Character: 8}) {
+		labels[item.Label] = item
+	}
+	assert.Equal(t, labels["server_name"].SortText, "0server_name")
+	assert.Equal(t, labels["listen"].SortText, "0listen")
+	_, ok := labels["worker_processes"]
+	assert.Assert(t, !ok, "main directive offered in server")
+	_, ok = labels["proxy_pass"]
+	assert.Assert(t, !ok, "location directive offered in server")
+
+	labels = map[string]completionItem{}
+	for _, item := range doc.completion(position{Line: 0, Character: 0}) {
+		labels[item.Label] = item
+	}
+	assert.Equal(t, labels["worker_processes"].SortText, "0worker_processes")
+}
+
+func TestContextAt(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", "main"},
+		{"http { server {", "server"},
+		{"http { server { location / { if ($a) {", "if"},
+		{"http { server { } ", "http"},
+		{"stream { server {", "stream_server"},
+		{"stream { upstream a {", "stream_upstream"},
+		{"http { # server {\n", "http"},
+		{"http { add_header X \"{\";", "http"},
+		{"http { map $a $b {", "map"},
+		{"http { content_by_lua_block { if a then {", "lua"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, contextAt(tt.text), tt.want, tt.text)
+	}
+}
+
+func TestDocument_Hover(t *testing.T) {
+	t.Parallel()
+	doc := newDocument("file:///nginx.conf", testConfig)
+
+	h := doc.hover(position{Line: 6, Character: 10})
+	assert.Assert(t, h != nil)
+	assert.Assert(t, strings.Contains(h.Contents.Value, "server_name"))
+	assert.Assert(t, strings.Contains(h.Contents.Value, "https://nginx.org/r/server_name"))
+	assert.Equal(t, *h.Range, lspRange{Start: position{Line: 6, Character: 8}, End: position{Line: 6, Character: 19}})
+
+	// parameters have no hover
+	assert.Assert(t, doc.hover(position{Line: 6, Character: 22}) == nil)
+}
+
+func TestDocument_Definition(t *testing.T) {
+	t.Parallel()
+	doc := newDocument("file:///nginx.conf", testConfig)
+
+	loc := doc.definition(position{Line: 8, Character: 30})
+	assert.Assert(t, loc != nil)
+	assert.Equal(t, loc.URI, "file:///nginx.conf")
+	assert.Equal(t, loc.Range, lspRange{Start: position{Line: 1, Character: 4}, End: position{Line: 3, Character: 5}})
+
+	assert.Assert(t, doc.definition(position{Line: 5, Character: 10}) == nil)
+}
+
+func TestUpstreamName(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, upstreamName("http://backend"), "backend")
+	assert.Equal(t, upstreamName("https://backend:8443/path"), "backend")
+	assert.Equal(t, upstreamName("backend"), "backend")
+}
+
+func TestDocument_Symbols(t *testing.T) {
+	t.Parallel()
+	doc := newDocument("file:///nginx.conf", testConfig)
+
+	symbols := doc.symbols()
+	assert.Equal(t, len(symbols), 1)
+	assert.Equal(t, symbols[0].Name, "server api.example.com")
+	assert.Equal(t, symbols[0].Range.Start, position{Line: 4, Character: 4})
+	assert.Equal(t, len(symbols[0].Children), 1)
+	assert.Equal(t, symbols[0].Children[0].Name, "location /v1")
+	assert.Equal(t, len(symbols[0].Children[0].Children), 1)
+	assert.Equal(t, symbols[0].Children[0].Children[0].Name, "location /v1/admin")
+}
+
+func TestDocument_Positions(t *testing.T) {
+	t.Parallel()
+	doc := &document{text: "ab\nçd\n"}
+	assert.Equal(t, doc.offsetAt(position{Line: 1, Character: 1}), 5)
+	assert.Equal(t, doc.positionAt(5), position{Line: 1, Character: 1})
+	assert.Equal(t, doc.offsetAt(position{Line: 0, Character: 10}), 2)
+	assert.Equal(t, doc.offsetAt(position{Line: 9, Character: 0}), len(doc.text))
+
+	// characters are UTF-16 code units, an emoji takes two of them
+	doc = newDocument("file:///nginx.conf", "# 😀 x\nuser nginx; # 😀\nhttp {\n    listen 80;\n}\n")
+	assert.Equal(t, doc.offsetAt(position{Line: 0, Character: 5}), 7)
+	assert.Equal(t, doc.positionAt(7), position{Line: 0, Character: 5})
+	assert.Equal(t, doc.offsetAt(position{Line: 0, Character: 4}), 6)
+	found := findDiagnostic(doc, "'listen' is not allowed in 'http'")
+	assert.Assert(t, found != nil, "%+v", doc.diagnostics)
+	assert.Equal(t, found.Range, lspRange{Start: position{Line: 3, Character: 4}, End: position{Line: 3, Character: 10}})
+	doc = newDocument("file:///nginx.conf", "user \"😀 nginx;\n")
+	found = findDiagnostic(doc, "unclosed quote")
+	assert.Assert(t, found != nil, "%+v", doc.diagnostics)
+	assert.Equal(t, found.Range.Start, position{Line: 0, Character: 5})
+	doc = newDocument("file:///nginx.conf", "user 😀 😀; foo_bar on;\n")
+	found = findDiagnostic(doc, "unknown directive 'foo_bar'")
+	assert.Assert(t, found != nil, "%+v", doc.diagnostics)
+	assert.Equal(t, found.Range.Start, position{Line: 0, Character: 12})
+}
@@ -0,0 +1,22 @@
+// Command gonginx-lsp is a Language Server Protocol server for nginx configuration files.
+//
+// It speaks LSP over stdin/stdout and offers:
+//   - diagnostics from the parser, the context and dependency validators and the security checker
+//   - completion of directive names allowed in the context under the cursor
+//   - hover documentation for directives
+//   - go-to-definition from proxy_pass http://name to the upstream name block
+//   - document symbols for servers and their locations
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	code, err := newServer(os.Stdin, os.Stdout).serve()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gonginx-lsp:", err)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// server keeps the open documents and answers the requests of one client
+type server struct {
+	conn      *conn
+	mu        sync.Mutex
+	documents map[string]*document
+	shutdown  bool
+}
+
+func newServer(r io.Reader, w io.Writer) *server {
+	return &server{
+		conn:      newConn(r, w),
+		documents: make(map[string]*document),
+	}
+}
+
+// serve handles messages until the client sends exit or closes the stream,
+// it returns the process exit code mandated by the protocol
+func (s *server) serve() (int, error) {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return 1, nil
+		}
+		if respErr, ok := err.(*responseError); ok {
+			if err := s.conn.reply(nil, nil, respErr); err != nil {
+				return 1, err
+			}
+			continue
+		}
+		if err != nil {
+			return 1, err
+		}
+
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0, nil
+			}
+			return 1, nil
+		}
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			// notifications are never answered
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, err); err != nil {
+			return 1, err
+		}
+	}
+}
+
+// handle dispatches a message to its handler
+func (s *server) handle(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:       textDocumentSyncKindFull,
+				CompletionProvider:     completionOptions{},
+				HoverProvider:          true,
+				DefinitionProvider:     true,
+				DocumentSymbolProvider: true,
+			},
+			ServerInfo: serverInfo{Name: "gonginx-lsp"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		delete(s.documents, params.TextDocument.URI)
+		s.mu.Unlock()
+		return nil, s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		doc, err := s.documentParams(msg, &params)
+		if doc == nil || err != nil {
+			return nil, err
+		}
+		return doc.completion(params.Position), nil
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		doc, err := s.documentParams(msg, &params)
+		if doc == nil || err != nil {
+			return nil, err
+		}
+		if h := doc.hover(params.Position); h != nil {
+			return h, nil
+		}
+		return nil, nil
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		doc, err := s.documentParams(msg, &params)
+		if doc == nil || err != nil {
+			return nil, err
+		}
+		if loc := doc.definition(params.Position); loc != nil {
+			return loc, nil
+		}
+		return nil, nil
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		doc, err := s.documentParams(msg, &params)
+		if doc == nil || err != nil {
+			return nil, err
+		}
+		return doc.symbols(), nil
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	}
+	if msg.ID == nil {
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+}
+
+// update analyses a new version of a document and publishes its diagnostics
+func (s *server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.mu.Lock()
+	s.documents[uri] = doc
+	s.mu.Unlock()
+	s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics,
+	})
+}
+
+// documentParams decodes params that carry a textDocument and returns the matching open document
+func (s *server) documentParams(msg *message, params interface{}) (*document, error) {
+	if err := unmarshalParams(msg, params); err != nil {
+		return nil, err
+	}
+	var uri string
+	switch p := params.(type) {
+	case *textDocumentPositionParams:
+		uri = p.TextDocument.URI
+	case *documentSymbolParams:
+		uri = p.TextDocument.URI
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.documents[uri], nil
+}
+
+func unmarshalParams(msg *message, v interface{}) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
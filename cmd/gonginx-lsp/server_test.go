@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// clientMessages frames the messages the way an editor sends them
+func clientMessages(t *testing.T, msgs ...string) io.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	return &buf
+}
+
+// serverMessages splits the output of the server into messages
+func serverMessages(t *testing.T, r io.Reader) []message {
+	t.Helper()
+	c := newConn(r, io.Discard)
+	var msgs []message
+	for {
+		msg, err := c.read()
+		if err == io.EOF {
+			return msgs
+		}
+		assert.NilError(t, err)
+		msgs = append(msgs, *msg)
+	}
+}
+
+func TestServer_Session(t *testing.T) {
+	t.Parallel()
+	text, _ := json.Marshal("http {\n    server {\n        listen 80;\n    }\n}\n")
+	in := clientMessages(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.conf","languageId":"nginx","version":1,"text":`+string(text)+`}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":"file:///a.conf"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///missing.conf"},"position":{"line":0,"character":0}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"workspace/symbol","params":{}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	var out bytes.Buffer
+	code, err := newServer(in, &out).serve()
+	assert.NilError(t, err)
+	assert.Equal(t, code, 0)
+
+	msgs := serverMessages(t, &out)
+	assert.Equal(t, len(msgs), 6)
+
+	result, _ := json.Marshal(msgs[0].Result)
+	assert.Assert(t, strings.Contains(string(result), `"documentSymbolProvider":true`), string(result))
+
+	assert.Equal(t, msgs[1].Method, "textDocument/publishDiagnostics")
+	var diagnostics publishDiagnosticsParams
+	assert.NilError(t, json.Unmarshal(msgs[1].Params, &diagnostics))
+	assert.Equal(t, diagnostics.URI, "file:///a.conf")
+
+	result, _ = json.Marshal(msgs[2].Result)
+	assert.Assert(t, strings.Contains(string(result), `"name":"server 80"`), string(result))
+
+	result, _ = json.Marshal(msgs[3].Result)
+	assert.Equal(t, string(result), "null")
+
+	assert.Equal(t, msgs[4].Error.Code, codeMethodNotFound)
+	result, _ = json.Marshal(msgs[5].Result)
+	assert.Equal(t, string(result), "null")
+}
+
+func TestServer_ExitWithoutShutdown(t *testing.T) {
+	t.Parallel()
+	code, err := newServer(clientMessages(t, `{"jsonrpc":"2.0","method":"exit"}`), io.Discard).serve()
+	assert.NilError(t, err)
+	assert.Equal(t, code, 1)
+}
+
+func TestServer_ParseError(t *testing.T) {
+	t.Parallel()
+	in := clientMessages(t, `{"jsonrpc":"2.0","id":1,`, `{"jsonrpc":"2.0","method":"exit"}`)
+	var out bytes.Buffer
+	_, err := newServer(in, &out).serve()
+	assert.NilError(t, err)
+
+	// the id of an unreadable request is null, not missing
+	body := out.String()[strings.Index(out.String(), "\r\n\r\n")+4:]
+	var response map[string]json.RawMessage
+	assert.NilError(t, json.Unmarshal([]byte(body), &response))
+	id, ok := response["id"]
+	assert.Assert(t, ok, body)
+	assert.Equal(t, string(id), "null")
+	assert.Assert(t, strings.Contains(string(response["error"]), `"code":-32700`), body)
+}
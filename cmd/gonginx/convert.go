@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lefeck/gonginx/utils"
+)
+
+// convert translates a configuration between nginx, JSON and YAML with utils.FormatConverter,
+// the ast formats are the lossless schema of utils.ConfigToAST
+func (c *cli) convert(args []string) int {
+	fs := c.newFlagSet("convert", "[-from format] -to format [-o output] [file]")
+	from := fs.String("from", "", "input format: nginx, json, yaml, ast-json or ast-yaml (default: guessed from the file extension)")
+	to := fs.String("to", "", "output format: nginx, json, yaml, ast-json or ast-yaml")
+	output := fs.String("o", "", "write the result to this file instead of stdout")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *to == "" || fs.NArg() > 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	file := "-"
+	if fs.NArg() == 1 {
+		file = fs.Arg(0)
+	}
+	if *from == "" {
+		*from = formatOf(file)
+	}
+	fromFormat, err := utils.ParseConfigFormat(*from)
+	if err != nil {
+		return c.errorf("convert: %v", err)
+	}
+	toFormat, err := utils.ParseConfigFormat(*to)
+	if err != nil {
+		return c.errorf("convert: %v", err)
+	}
+
+	input, err := c.readInput(file)
+	if err != nil {
+		return c.errorf("convert: %s: %v", file, err)
+	}
+	result, err := utils.NewFormatConverter().Convert(input, fromFormat, toFormat)
+	if err != nil {
+		return c.errorf("convert: %s: %v", file, err)
+	}
+	if !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+
+	if *output != "" {
+		if err := os.WriteFile(*output, []byte(result), 0644); err != nil {
+			return c.errorf("convert: %v", err)
+		}
+		return exitOK
+	}
+	fmt.Fprint(c.stdout, result)
+	return exitOK
+}
+
+// formatOf guesses the format of a file from its extension, nginx by default
+func formatOf(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "nginx"
+	}
+}
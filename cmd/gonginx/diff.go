@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/utils"
+)
+
+// diffEntry is the JSON form of a utils.Difference
+type diffEntry struct {
+	Type      string `json:"type"`
+	Path      string `json:"path"`
+	Directive string `json:"directive"`
+	OldValue  string `json:"old_value,omitempty"`
+	NewValue  string `json:"new_value,omitempty"`
+	OldLine   int    `json:"old_line,omitempty"`
+	NewLine   int    `json:"new_line,omitempty"`
+}
+
+// diff compares two configuration files with utils.CompareConfigs,
+// like diff(1) it exits with 1 when they differ. The patch format is applied with the patch command,
+// the unified format compares the dumped files, included files too, with utils.UnifiedConfigDiff.
+func (c *cli) diff(args []string) int {
+	fs := c.newFlagSet("diff", "[-format text|json|patch|unified] [-U n] [-color] old.conf new.conf")
+	format := fs.String("format", "text", "output format: text, json, patch or unified")
+	context := fs.Int("U", 3, "lines of context of the unified format")
+	color := fs.Bool("color", false, "color the unified format with ANSI escape sequences")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	switch *format {
+	case "text", "json", "patch", "unified":
+	default:
+		return c.errorf("diff: unknown format %q", *format)
+	}
+
+	opts := []parser.Option{parser.WithSkipValidDirectivesErr()}
+	if *format == "unified" {
+		opts = append(opts, parser.WithIncludeParsing())
+	}
+	oldConf, err := c.parseInput(fs.Arg(0), opts...)
+	if err != nil {
+		return c.errorf("diff: %s: %v", fs.Arg(0), err)
+	}
+	newConf, err := c.parseInput(fs.Arg(1), opts...)
+	if err != nil {
+		return c.errorf("diff: %s: %v", fs.Arg(1), err)
+	}
+
+	if *format == "unified" {
+		unifiedOpts := []utils.UnifiedOption{utils.WithContextLines(*context)}
+		if *color {
+			unifiedOpts = append(unifiedOpts, utils.WithColor())
+		}
+		out := utils.UnifiedConfigDiff(oldConf, newConf, unifiedOpts...)
+		fmt.Fprint(c.stdout, out)
+		if out != "" {
+			return exitFail
+		}
+		return exitOK
+	}
+	result := utils.CompareConfigs(oldConf, newConf)
+
+	if *format == "patch" {
+		patch, err := result.Patch()
+		if err != nil {
+			return c.errorf("diff: %v", err)
+		}
+		data, err := json.MarshalIndent(patch, "", "  ")
+		if err != nil {
+			return c.errorf("diff: %v", err)
+		}
+		fmt.Fprintln(c.stdout, string(data))
+	} else if *format == "json" {
+		entries := make([]diffEntry, 0, len(result.Differences))
+		for _, d := range result.Differences {
+			entries = append(entries, diffEntry{
+				Type:      d.Type.String(),
+				Path:      d.Path,
+				Directive: d.DirectiveName,
+				OldValue:  d.OldValue,
+				NewValue:  d.NewValue,
+				OldLine:   d.OldLine,
+				NewLine:   d.NewLine,
+			})
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return c.errorf("diff: %v", err)
+		}
+		fmt.Fprintln(c.stdout, string(data))
+	} else if result.HasChanges() {
+		for _, d := range result.Differences {
+			fmt.Fprintln(c.stdout, d.String())
+		}
+		fmt.Fprintln(c.stdout, result.Summary.String())
+	}
+
+	if result.HasChanges() {
+		return exitFail
+	}
+	return exitOK
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/utils"
+)
+
+// fmt reformats configuration files with a dumper.Style
+func (c *cli) fmt(args []string) int {
+	fs := c.newFlagSet("fmt", "[-w] [-d] [-indent n] [-sort] [-space] [files]")
+	write := fs.Bool("w", false, "write the result back to the file instead of stdout")
+	diff := fs.Bool("d", false, "print a diff instead of the formatted config")
+	indent := fs.Int("indent", 4, "number of spaces per indentation level")
+	sortDirectives := fs.Bool("sort", false, "sort directives inside blocks")
+	space := fs.Bool("space", false, "add an empty line before blocks")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	style := dumper.NewStyle()
+	style.Indent = *indent
+	style.SortDirectives = *sortDirectives
+	style.SpaceBeforeBlocks = *space
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	code := exitOK
+	for _, file := range files {
+		if *write && file == "-" {
+			return c.errorf("fmt: cannot use -w with standard input")
+		}
+		if err := c.fmtFile(file, style, *write, *diff); err != nil {
+			code = c.errorf("fmt: %s: %v", file, err)
+		}
+	}
+	return code
+}
+
+func (c *cli) fmtFile(file string, style *dumper.Style, write, diff bool) error {
+	original, err := c.readInput(file)
+	if err != nil {
+		return err
+	}
+	conf, err := parser.NewStringParser(original, parser.WithSkipValidDirectivesErr()).Parse()
+	if err != nil {
+		return err
+	}
+	formatted := dumper.DumpConfig(conf, style)
+	if !strings.HasSuffix(formatted, "\n") {
+		formatted += "\n"
+	}
+
+	if diff {
+		fmt.Fprint(c.stdout, utils.UnifiedDiff(file, file, original, formatted))
+	}
+	if write {
+		if formatted == original {
+			return nil
+		}
+		return dumper.WriteChanges(map[string]string{file: formatted})
+	}
+	if !diff {
+		fmt.Fprint(c.stdout, formatted)
+	}
+	return nil
+}
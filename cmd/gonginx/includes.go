@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lefeck/gonginx/parser"
+)
+
+// includeReport is the JSON form of the include analysis
+type includeReport struct {
+	Cycles         [][]string          `json:"cycles"`
+	UnmatchedGlobs []includeLocation   `json:"unmatched_globs"`
+	MultiContext   map[string][]string `json:"multi_context"`
+	FanIn          map[string]int      `json:"fan_in"`
+	Unused         []string            `json:"unused"`
+}
+
+// includeLocation is an include directive
+type includeLocation struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Pattern string `json:"pattern"`
+}
+
+// includes follows the include directives of a main file and reports cycles,
+// globs matching nothing, files included from several contexts and files of
+// the config directory that are never included. It fails when it finds a cycle,
+// an unmatched glob or an unused file
+func (c *cli) includes(args []string) int {
+	fs := c.newFlagSet("includes", "[-format text|json] [-root dir] [-pattern glob] file")
+	format := fs.String("format", "text", "output format: text or json")
+	root := fs.String("root", "", "directory searched for unused files, the directory of the file by default")
+	pattern := fs.String("pattern", "*.conf", "names of the files searched for unused files, empty for all")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 || fs.Arg(0) == "-" {
+		fs.Usage()
+		return exitError
+	}
+	if *format != "text" && *format != "json" {
+		return c.errorf("includes: unknown format %q", *format)
+	}
+
+	file := fs.Arg(0)
+	p, err := parser.NewParser(file, parser.WithIncludeParsing(), parser.WithSkipValidDirectivesErr(), parser.WithSkipIncludeParsingErr())
+	if err != nil {
+		return c.errorf("includes: %v", err)
+	}
+	if _, err := p.Parse(); err != nil {
+		return c.errorf("includes: %s: %v", file, err)
+	}
+	graph := p.IncludeGraph()
+	if *root == "" {
+		*root = filepath.Dir(file)
+	}
+	unused, err := graph.UnusedFiles(*root, *pattern)
+	if err != nil {
+		return c.errorf("includes: %v", err)
+	}
+
+	report := includeReport{
+		Cycles:         [][]string{},
+		UnmatchedGlobs: []includeLocation{},
+		MultiContext:   map[string][]string{},
+		FanIn:          map[string]int{},
+		Unused:         unused,
+	}
+	for _, cycle := range graph.Cycles {
+		report.Cycles = append(report.Cycles, cycle.Path)
+	}
+	for _, edge := range graph.UnmatchedGlobs() {
+		report.UnmatchedGlobs = append(report.UnmatchedGlobs, includeLocation{File: edge.From, Line: edge.Line, Pattern: edge.Pattern})
+	}
+	for _, node := range graph.MultiContextFiles() {
+		report.MultiContext[node.Path] = node.Contexts()
+	}
+	for path, node := range graph.Nodes {
+		if path != graph.Root {
+			report.FanIn[path] = node.FanIn()
+		}
+	}
+	if report.Unused == nil {
+		report.Unused = []string{}
+	}
+
+	if *format == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return c.errorf("includes: %v", err)
+		}
+		fmt.Fprintln(c.stdout, string(data))
+	} else {
+		for _, cycle := range report.Cycles {
+			fmt.Fprintf(c.stdout, "cycle: %s\n", strings.Join(cycle, " -> "))
+		}
+		for _, glob := range report.UnmatchedGlobs {
+			fmt.Fprintf(c.stdout, "%s:%d: include %s matches no file\n", glob.File, glob.Line, glob.Pattern)
+		}
+		for _, node := range graph.MultiContextFiles() {
+			fmt.Fprintf(c.stdout, "%s: included from %s (%d includes)\n", node.Path, strings.Join(node.Contexts(), ", "), node.FanIn())
+		}
+		for _, path := range report.Unused {
+			fmt.Fprintf(c.stdout, "unused: %s\n", path)
+		}
+	}
+
+	if len(report.Cycles) > 0 || len(report.UnmatchedGlobs) > 0 || len(report.Unused) > 0 {
+		return exitFail
+	}
+	return exitOK
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+)
+
+// readInput returns the content of a file, "-" reads standard input
+func (c *cli) readInput(path string) (string, error) {
+	if path == "-" {
+		data, err := io.ReadAll(c.stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// parseInput parses a file, "-" reads standard input.
+// Files are parsed from their path so that positions carry the file name
+func (c *cli) parseInput(path string, opts ...parser.Option) (*config.Config, error) {
+	if path == "-" {
+		content, err := c.readInput(path)
+		if err != nil {
+			return nil, err
+		}
+		return parser.NewStringParser(content, opts...).Parse()
+	}
+	p, err := parser.NewParser(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return p.Parse()
+}
+
+// newFlagSet creates the flags of a command, errors are reported on stderr
+func (c *cli) newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: gonginx %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the arguments of a command, it returns false with the exit code when the command must stop
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK, false
+		}
+		return exitError, false
+	}
+	return exitOK, true
+}
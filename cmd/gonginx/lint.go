@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/utils"
+)
+
+// lintIssue is one problem reported by lint
+type lintIssue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"` // error, warning or info
+	Source   string `json:"source"`   // syntax, validation or security
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"`
+}
+
+func (i lintIssue) String() string {
+	s := fmt.Sprintf("%s:%d:%d: %s: [%s] %s", i.File, i.Line, i.Column, i.Severity, i.Source, i.Message)
+	if i.Fix != "" {
+		s += " (fix: " + i.Fix + ")"
+	}
+	return s
+}
+
+// lint reports syntax errors, validation issues and security issues.
+// With -profile the directives the target build does not have are reported too.
+// It fails when errors are found, or warnings too with -strict
+func (c *cli) lint(args []string) int {
+	fs := c.newFlagSet("lint", "[-format text|json] [-security=false] [-strict] [-profile name [-modules list]] [files]")
+	format := fs.String("format", "text", "output format: text or json")
+	security := fs.Bool("security", true, "run the security checks")
+	strict := fs.Bool("strict", false, "fail on warnings too")
+	profileName := fs.String("profile", "", "target build, like nginx-1.24, nginx-plus-r30, openresty-1.21 or tengine-3.0")
+	modules := fs.String("modules", "", "comma separated modules the target build has besides the default ones, like http_ssl,http_v2")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return c.errorf("lint: unknown format %q", *format)
+	}
+	var profile *config.Profile
+	if *profileName != "" {
+		var extra []string
+		if *modules != "" {
+			extra = strings.Split(*modules, ",")
+		}
+		var err error
+		if profile, err = config.LookupProfile(*profileName, extra...); err != nil {
+			return c.errorf("lint: %v", err)
+		}
+	} else if *modules != "" {
+		return c.errorf("lint: -modules requires -profile")
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	issues := []lintIssue{}
+	for _, file := range files {
+		fileIssues, err := c.lintFile(file, *security, profile)
+		if err != nil {
+			return c.errorf("lint: %s: %v", file, err)
+		}
+		issues = append(issues, fileIssues...)
+	}
+
+	if *format == "json" {
+		data, err := json.MarshalIndent(issues, "", "  ")
+		if err != nil {
+			return c.errorf("lint: %v", err)
+		}
+		fmt.Fprintln(c.stdout, string(data))
+	} else {
+		for _, issue := range issues {
+			fmt.Fprintln(c.stdout, issue)
+		}
+	}
+
+	for _, issue := range issues {
+		if issue.Severity == "error" || (*strict && issue.Severity == "warning") {
+			return exitFail
+		}
+	}
+	return exitOK
+}
+
+// lintFile collects the issues of one file, only I/O errors are returned as errors
+func (c *cli) lintFile(file string, security bool, profile *config.Profile) ([]lintIssue, error) {
+	opts := []parser.Option{parser.WithErrorRecovery()}
+	validator := config.NewConfigValidator()
+	if profile != nil {
+		opts = append(opts, parser.WithProfile(profile))
+		validator = config.NewConfigValidatorForProfile(profile)
+	}
+	conf, err := c.parseInput(file, opts...)
+	var issues []lintIssue
+	syntaxLines := map[int]bool{}
+	if syntaxErrors, ok := err.(parser.ErrorList); ok {
+		for _, syntaxErr := range syntaxErrors {
+			syntaxLines[syntaxErr.Pos.Line] = true
+			issues = append(issues, lintIssue{
+				File:     file,
+				Line:     syntaxErr.Pos.Line,
+				Column:   syntaxErr.Pos.Column,
+				Severity: "error",
+				Source:   "syntax",
+				Message:  syntaxErr.Message,
+			})
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	report := validator.ValidateConfig(conf)
+	for _, issue := range report.Issues {
+		// the parser already rejected the directives the profile does not have
+		if issue.Category == "Profile" && issue.Level == config.ValidationError && syntaxLines[issue.Line] {
+			continue
+		}
+		line, column := locate(conf, issue.Directive, issue.Line)
+		issueFile := file
+		if issue.File != "" {
+			issueFile = issue.File
+		}
+		issues = append(issues, lintIssue{
+			File:     issueFile,
+			Line:     line,
+			Column:   column,
+			Severity: validationSeverity(issue.Level),
+			Source:   "validation",
+			Message:  issue.Title + ": " + issue.Description,
+			Fix:      issue.Fix,
+		})
+	}
+
+	if security {
+		for _, issue := range utils.CheckSecurity(conf).Issues {
+			line, column := locate(conf, issue.Directive, 0)
+			issues = append(issues, lintIssue{
+				File:     file,
+				Line:     line,
+				Column:   column,
+				Severity: securitySeverity(issue.Level),
+				Source:   "security",
+				Message:  issue.Title + ": " + issue.Description,
+				Fix:      issue.Fix,
+			})
+		}
+	}
+	return issues, nil
+}
+
+// locate returns the position of the first directive with the name, on the line if it is not zero
+func locate(conf *config.Config, name string, line int) (int, int) {
+	if name == "" {
+		return line, 0
+	}
+	for _, d := range conf.FindDirectives(name) {
+		if line != 0 && d.GetLine() != line {
+			continue
+		}
+		if r := config.RangeOf(d); r != nil {
+			return r.Start.Line, r.Start.Column
+		}
+		return d.GetLine(), 0
+	}
+	return line, 0
+}
+
+func validationSeverity(level config.ValidationLevel) string {
+	switch level {
+	case config.ValidationError:
+		return "error"
+	case config.ValidationWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+func securitySeverity(level utils.SecurityLevel) string {
+	switch level {
+	case utils.SecurityCritical:
+		return "error"
+	case utils.SecurityWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
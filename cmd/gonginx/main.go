@@ -0,0 +1,102 @@
+// Command gonginx formats, lints, compares, converts and queries nginx configuration files,
+// and tells which server and location handle a request.
+//
+// Usage:
+//
+//	gonginx <command> [flags] [files]
+//
+// The commands are:
+//
+//	fmt      reformat configuration files
+//	lint     run the validators and security checks
+//	diff     compare two configuration files
+//	patch    apply a patch made by diff -format patch
+//	convert  convert between nginx, JSON and YAML
+//	query    print the directives matching a query
+//	includes analyze the include graph of a main file
+//	route    show the server and location handling a URL
+//
+// A file named "-" is read from standard input. Exit codes are 0 on success,
+// 1 when lint finds problems, diff finds changes, patch does not apply, query matches
+// nothing or includes finds cycles, unmatched globs or unused files,
+// and 2 on usage, I/O or parse errors.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// exit codes of the commands
+const (
+	exitOK    = 0
+	exitFail  = 1 // lint found problems, diff found changes, patch did not apply, query matched nothing, includes found problems
+	exitError = 2 // bad usage, unreadable or unparsable input
+)
+
+// cli holds the standard streams the commands use
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// command is a gonginx subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(c *cli, args []string) int
+}
+
+var commands = []command{
+	{"fmt", "reformat configuration files", (*cli).fmt},
+	{"lint", "run the validators and security checks", (*cli).lint},
+	{"diff", "compare two configuration files", (*cli).diff},
+	{"patch", "apply a patch made by diff -format patch", (*cli).patch},
+	{"convert", "convert between nginx, JSON and YAML", (*cli).convert},
+	{"query", "print the directives matching a query", (*cli).query},
+	{"includes", "analyze the include graph of a main file", (*cli).includes},
+	{"route", "show the server and location handling a URL", (*cli).route},
+}
+
+func main() {
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	os.Exit(c.run(os.Args[1:]))
+}
+
+// run dispatches to the subcommand named by the first argument
+func (c *cli) run(args []string) int {
+	if len(args) == 0 {
+		c.usage()
+		return exitError
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		c.usage()
+		return exitOK
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(c, args[1:])
+		}
+	}
+	fmt.Fprintf(c.stderr, "gonginx: unknown command %q\n", args[0])
+	c.usage()
+	return exitError
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "Usage: gonginx <command> [flags] [files]")
+	fmt.Fprintln(c.stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(c.stderr, "\nRun 'gonginx <command> -h' for the flags of a command.")
+}
+
+// errorf reports an error on stderr and returns exitError
+func (c *cli) errorf(format string, args ...interface{}) int {
+	fmt.Fprintf(c.stderr, "gonginx: "+format+"\n", args...)
+	return exitError
+}
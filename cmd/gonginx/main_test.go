@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lefeck/gonginx/utils"
+	"gotest.tools/v3/assert"
+)
+
+// runCLI runs gonginx with the arguments and returns the exit code, stdout and stderr
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	c := &cli{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}
+	code := c.run(args)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0o640))
+	return path
+}
+
+func TestCLI_Usage(t *testing.T) {
+	t.Parallel()
+	code, _, stderr := runCLI(t, "")
+	assert.Equal(t, code, exitError)
+	assert.Assert(t, strings.Contains(stderr, "Usage: gonginx"))
+
+	code, _, stderr = runCLI(t, "", "frobnicate")
+	assert.Equal(t, code, exitError)
+	assert.Assert(t, strings.Contains(stderr, `unknown command "frobnicate"`))
+
+	code, _, _ = runCLI(t, "", "fmt", "-h")
+	assert.Equal(t, code, exitOK)
+}
+
+func TestCLI_Fmt(t *testing.T) {
+	t.Parallel()
+	code, stdout, _ := runCLI(t, "http{server{listen 80;}}", "fmt")
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "http {\n    server {\n        listen 80;\n    }\n}\n")
+
+	code, stdout, _ = runCLI(t, "http{server{listen 80;}}", "fmt", "-indent", "2")
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "http {\n  server {\n    listen 80;\n  }\n}\n")
+
+	code, _, stderr := runCLI(t, "http {", "fmt")
+	assert.Equal(t, code, exitError)
+	assert.Assert(t, strings.Contains(stderr, "unexpected eof in block"))
+}
+
+func TestCLI_FmtWriteAndDiff(t *testing.T) {
+	t.Parallel()
+	path := writeFile(t, "nginx.conf", "user nginx;\nevents {\nworker_connections 1024;\n}\n")
+
+	code, stdout, _ := runCLI(t, "", "fmt", "-d", path)
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "--- "+path+"\n+++ "+path+"\n@@ -1,4 +1,4 @@\n user nginx;\n events {\n-worker_connections 1024;\n+    worker_connections 1024;\n }\n")
+
+	code, stdout, _ = runCLI(t, "", "fmt", "-w", path)
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "")
+	data, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), "user nginx;\nevents {\n    worker_connections 1024;\n}\n")
+	info, err := os.Stat(path)
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0o640))
+
+	code, stdout, _ = runCLI(t, "", "fmt", "-d", path)
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "")
+}
+
+func TestCLI_Lint(t *testing.T) {
+	t.Parallel()
+	code, stdout, _ := runCLI(t, "events {\n    worker_connections 1024;\n}\n", "lint", "-security=false")
+	assert.Equal(t, code, exitOK, stdout)
+
+	path := writeFile(t, "bad.conf", "http {\n    listen 80;\n    servr_name a;\n}\n")
+	code, stdout, _ = runCLI(t, "", "lint", "-security=false", path)
+	assert.Equal(t, code, exitFail)
+	assert.Assert(t, strings.Contains(stdout, path+":3:5: error: [syntax] unknown directive 'servr_name'"), stdout)
+	assert.Assert(t, strings.Contains(stdout, path+":2:5: error: [validation]"), stdout)
+
+	code, stdout, _ = runCLI(t, "", "lint", "-format", "json", "-security=false", path)
+	assert.Equal(t, code, exitFail)
+	var issues []lintIssue
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &issues))
+	assert.Equal(t, issues[0].Source, "syntax")
+	assert.Equal(t, issues[0].Line, 3)
+
+	code, _, stderr := runCLI(t, "", "lint", filepath.Join(t.TempDir(), "missing.conf"))
+	assert.Equal(t, code, exitError)
+	assert.Assert(t, strings.Contains(stderr, "no such file or directory"))
+}
+
+func TestCLI_LintProfile(t *testing.T) {
+	t.Parallel()
+	conf := "http {\n    server {\n        listen 443 ssl http2;\n        auth_jwt api;\n    }\n}\n"
+	code, stdout, _ := runCLI(t, conf, "lint", "-security=false", "-profile", "nginx-1.25.3", "-modules", "http_ssl")
+	assert.Equal(t, code, exitFail)
+	assert.Assert(t, strings.Contains(stdout, "-:4:9: error: [syntax] auth_jwt is only available in NGINX Plus"), stdout)
+	assert.Assert(t, strings.Contains(stdout, "-:3:9: warning: [validation] Deprecated directive: parameter http2 of listen"), stdout)
+	assert.Assert(t, !strings.Contains(stdout, "Directive not available"), stdout)
+
+	_, stdout, _ = runCLI(t, conf, "lint", "-security=false", "-profile", "nginx-plus-r30")
+	assert.Assert(t, !strings.Contains(stdout, "auth_jwt"), stdout)
+
+	code, _, stderr := runCLI(t, conf, "lint", "-profile", "nginx-1.24", "-modules", "http_api")
+	assert.Equal(t, code, exitError)
+	assert.Assert(t, strings.Contains(stderr, `module "http_api" is only available in NGINX Plus`), stderr)
+}
+
+func TestCLI_LintSecurity(t *testing.T) {
+	t.Parallel()
+	conf := "http {\n    server {\n        listen 80;\n        server_name a;\n    }\n}\n"
+	code, stdout, _ := runCLI(t, conf, "lint")
+	assert.Assert(t, strings.Contains(stdout, "[security]"), stdout)
+	if code == exitOK {
+		code, _, _ = runCLI(t, conf, "lint", "-strict")
+		assert.Equal(t, code, exitFail)
+	}
+}
+
+func TestCLI_Diff(t *testing.T) {
+	t.Parallel()
+	oldPath := writeFile(t, "old.conf", "http {\n    gzip on;\n}\n")
+	newPath := writeFile(t, "new.conf", "http {\n    gzip off;\n}\n")
+
+	code, stdout, _ := runCLI(t, "", "diff", oldPath, newPath)
+	assert.Equal(t, code, exitFail)
+	assert.Assert(t, strings.Contains(stdout, "~ [http] gzip: on -> off"), stdout)
+	assert.Assert(t, strings.Contains(stdout, "Modified: 1"), stdout)
+
+	code, stdout, _ = runCLI(t, "", "diff", "-format", "json", oldPath, newPath)
+	assert.Equal(t, code, exitFail)
+	var entries []diffEntry
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &entries))
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, entries[0].OldLine, 2)
+	assert.Equal(t, entries[0].NewLine, 2)
+
+	code, stdout, _ = runCLI(t, "", "diff", oldPath, oldPath)
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "")
+
+	code, _, _ = runCLI(t, "", "diff", oldPath)
+	assert.Equal(t, code, exitError)
+}
+
+func TestCLI_DiffUnified(t *testing.T) {
+	t.Parallel()
+	writeTree := func(files map[string]string) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+			assert.NilError(t, os.WriteFile(path, []byte(content), 0o640))
+		}
+		return dir
+	}
+	oldDir := writeTree(map[string]string{
+		"nginx.conf":       "http {\n    gzip on;\n    include conf.d/*.conf;\n}\n",
+		"conf.d/a.conf":    "server {\n    listen 80;\n    server_name a.com;\n}\n",
+		"conf.d/gone.conf": "server {\n    listen 80;\n    server_name gone.com;\n}\n",
+	})
+	newDir := writeTree(map[string]string{
+		"nginx.conf":    "http {\n    gzip on;\n    include conf.d/*.conf;\n}\n",
+		"conf.d/a.conf": "server {\n    listen 8080;\n    server_name a.com;\n}\n",
+	})
+	oldPath, newPath := filepath.Join(oldDir, "nginx.conf"), filepath.Join(newDir, "nginx.conf")
+
+	code, stdout, stderr := runCLI(t, "", "diff", "-format", "unified", "-U", "1", oldPath, newPath)
+	assert.Equal(t, code, exitFail, stderr)
+	assert.Equal(t, stdout, "--- "+filepath.Join(oldDir, "conf.d/a.conf")+`
++++ `+filepath.Join(newDir, "conf.d/a.conf")+`
+@@ -1,3 +1,3 @@
+ server {
+-    listen 80;
++    listen 8080;
+     server_name a.com;
+--- `+filepath.Join(oldDir, "conf.d/gone.conf")+`
++++ /dev/null
+@@ -1,4 +0,0 @@
+-server {
+-    listen 80;
+-    server_name gone.com;
+-}
+`)
+
+	code, stdout, _ = runCLI(t, "", "diff", "-format", "unified", "-color", oldPath, newPath)
+	assert.Equal(t, code, exitFail)
+	assert.Assert(t, strings.Contains(stdout, "\x1b[31m-    listen 80;\x1b[0m\n\x1b[32m+    listen 8080;\x1b[0m\n"), stdout)
+	assert.Assert(t, strings.Contains(stdout, "\x1b[36m@@ -1,4 +1,4 @@\x1b[0m\n"), stdout)
+
+	code, stdout, _ = runCLI(t, "", "diff", "-format", "unified", oldPath, oldPath)
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "")
+}
+
+func TestCLI_DiffUnnamedServer(t *testing.T) {
+	t.Parallel()
+	oldPath := writeFile(t, "old.conf", "http {\n    server {\n        root /a;\n    }\n}\n")
+	newPath := writeFile(t, "new.conf", "http {\n    server {\n        root /b;\n    }\n}\n")
+	code, stdout, _ := runCLI(t, "", "diff", oldPath, newPath)
+	assert.Equal(t, code, exitFail)
+	assert.Equal(t, stdout, "~ [http/server] root: /a -> /b\nTotal: 1, Added: 0, Removed: 0, Modified: 1, Moved: 0, Reordered: 0\n")
+
+	// unnamed servers are told apart by their listen, a lone one is paired even when its listen changed
+	oldPath = writeFile(t, "old.conf", "http {\n    server {\n        listen 80 default_server;\n        root /a;\n    }\n    server {\n        listen 443;\n        root /c;\n    }\n}\n")
+	newPath = writeFile(t, "new.conf", "http {\n    server {\n        listen 80 default_server;\n        root /b;\n    }\n    server {\n        listen 443;\n        root /c;\n    }\n}\n")
+	code, stdout, _ = runCLI(t, "", "diff", oldPath, newPath)
+	assert.Equal(t, code, exitFail)
+	assert.Assert(t, strings.HasPrefix(stdout, "~ [http/server[80]] root: /a -> /b\nTotal: 1,"), stdout)
+
+	oldPath = writeFile(t, "old.conf", "http {\n    server {\n        listen 80;\n        root /a;\n    }\n}\n")
+	newPath = writeFile(t, "new.conf", "http {\n    server {\n        listen 8080;\n        root /a;\n    }\n}\n")
+	code, stdout, _ = runCLI(t, "", "diff", oldPath, newPath)
+	assert.Equal(t, code, exitFail)
+	assert.Assert(t, strings.HasPrefix(stdout, "~ [http/server] listen: 80 -> 8080\nTotal: 1,"), stdout)
+
+	code, stdout, _ = runCLI(t, "", "diff", "-format", "patch", oldPath, newPath)
+	assert.Equal(t, code, exitFail)
+	patchPath := writeFile(t, "change.json", stdout)
+	code, stdout, stderr := runCLI(t, "", "patch", patchPath, oldPath)
+	assert.Equal(t, code, exitOK, stderr)
+	assert.Equal(t, stdout, "http {\n    server {\n        listen 8080;\n        root /a;\n    }\n}\n")
+}
+
+func TestCLI_DiffIdentity(t *testing.T) {
+	t.Parallel()
+	oldPath := writeFile(t, "old.conf", `http {
+    add_header X-A a;
+    add_header X-B b;
+    server {
+        listen 80;
+        server_name a.com;
+        location /api {
+            proxy_pass http://api;
+        }
+        location /static {
+            root /srv;
+        }
+        location = /health {
+            return 200;
+        }
+    }
+    server {
+        listen 80;
+        server_name b.com;
+        root /b;
+    }
+}
+`)
+	newPath := writeFile(t, "new.conf", `http {
+    add_header X-A a;
+    add_header X-C c;
+    add_header X-B b;
+    server {
+        listen 80;
+        server_name b.com;
+        root /b2;
+        location = /health {
+            return 200;
+        }
+    }
+    server {
+        listen 80;
+        server_name a.com;
+        location /static {
+            root /srv;
+        }
+        location /api {
+            proxy_pass http://api2;
+        }
+    }
+}
+`)
+
+	code, stdout, _ := runCLI(t, "", "diff", oldPath, newPath)
+	assert.Equal(t, code, exitFail)
+	assert.Equal(t, stdout, `+ [http] add_header: X-C c
+> [http] server: reordered (line 17 -> 5)
+~ [http/server[b.com]] root: /b -> /b2
+^ [http/server[a.com]] location: moved to http/server[b.com]/location[= /health]
+> [http/server[a.com]] location /static: reordered (line 10 -> 16)
+~ [http/server[a.com]/location[/api]] proxy_pass: http://api -> http://api2
+Total: 6, Added: 1, Removed: 0, Modified: 2, Moved: 1, Reordered: 2
+`)
+
+	code, stdout, _ = runCLI(t, "", "diff", "-format", "json", oldPath, newPath)
+	assert.Equal(t, code, exitFail)
+	var entries []diffEntry
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &entries))
+	assert.Equal(t, entries[3].Type, "MOVED")
+	assert.Equal(t, entries[3].OldLine, 13)
+	assert.Equal(t, entries[3].NewLine, 9)
+}
+
+func TestCLI_Patch(t *testing.T) {
+	t.Parallel()
+	stagingOld := writeFile(t, "old.conf", `http {
+    server {
+        listen 80;
+        server_name api.example.com;
+        location /v1 {
+            proxy_pass http://v1;
+        }
+        location /legacy {
+            return 410;
+        }
+    }
+}
+`)
+	stagingNew := writeFile(t, "new.conf", `http {
+    server {
+        listen 80;
+        server_name api.example.com;
+        location /v1 {
+            proxy_pass http://v1-next;
+            proxy_read_timeout 5s;
+        }
+    }
+}
+`)
+	code, stdout, _ := runCLI(t, "", "diff", "-format", "patch", stagingOld, stagingNew)
+	assert.Equal(t, code, exitFail)
+	patchFile := writeFile(t, "change.json", stdout)
+
+	production := `http {
+    server {
+        listen 80;
+        server_name api.example.com;
+        access_log /var/log/api.log;
+        location /v1 {
+            proxy_pass http://v1; # pinned
+        }
+        location /legacy {
+            return 410;
+        }
+    }
+}
+`
+	prodPath := writeFile(t, "prod.conf", production)
+	code, stdout, _ = runCLI(t, "", "patch", "-dry-run", patchFile, prodPath)
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "")
+
+	code, stdout, _ = runCLI(t, "", "patch", "-w", patchFile, prodPath)
+	assert.Equal(t, code, exitOK)
+	data, err := os.ReadFile(prodPath)
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), `http {
+    server {
+        listen 80;
+        server_name api.example.com;
+        access_log /var/log/api.log;
+        location /v1 {
+            proxy_pass http://v1-next; # pinned
+            proxy_read_timeout 5s;
+        }
+    }
+}
+`)
+
+	// the patched file no longer has what the patch expects
+	code, _, stderr := runCLI(t, "", "patch", patchFile, prodPath)
+	assert.Equal(t, code, exitFail)
+	assert.Assert(t, strings.Contains(stderr, "no proxy_pass http://v1"), stderr)
+	assert.Assert(t, strings.Contains(stderr, "no location[/legacy]"), stderr)
+
+	code, stdout, _ = runCLI(t, "", "patch", "-R", patchFile, prodPath)
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, production)
+
+	// a removed block must be as the patch expects it
+	diverged := writeFile(t, "diverged.conf", strings.Replace(production, "return 410;", "return 404;", 1))
+	code, _, stderr = runCLI(t, "", "patch", patchFile, diverged)
+	assert.Equal(t, code, exitFail)
+	assert.Assert(t, strings.Contains(stderr, "http/server[api.example.com]/location[/legacy] differs from the expected directive"), stderr)
+}
+
+func TestCLI_Convert(t *testing.T) {
+	t.Parallel()
+	code, stdout, stderr := runCLI(t, "worker_processes 4;\n", "convert", "-to", "json")
+	assert.Equal(t, code, exitOK, stderr)
+	var converted map[string]interface{}
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &converted))
+	assert.Equal(t, converted["worker_processes"], "4")
+
+	jsonPath := writeFile(t, "nginx.json", stdout)
+	out := filepath.Join(t.TempDir(), "nginx.conf")
+	code, _, stderr = runCLI(t, "", "convert", "-to", "nginx", "-o", out, jsonPath)
+	assert.Equal(t, code, exitOK, stderr)
+	data, err := os.ReadFile(out)
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), "worker_processes 4;\n")
+
+	conf := "http {\n    server {\n        listen 80;\n    }\n    server {\n        listen 81;\n    }\n}\n"
+	code, stdout, stderr = runCLI(t, conf, "convert", "-to", "ast-json")
+	assert.Equal(t, code, exitOK, stderr)
+	var payload utils.ASTPayload
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &payload))
+	assert.Equal(t, len(*payload.Config[0].Parsed[0].Block), 2)
+	code, stdout, stderr = runCLI(t, stdout, "convert", "-from", "ast-json", "-to", "nginx")
+	assert.Equal(t, code, exitOK, stderr)
+	assert.Equal(t, stdout, conf)
+
+	code, _, stderr = runCLI(t, "", "convert", "-to", "xml")
+	assert.Equal(t, code, exitError)
+	assert.Assert(t, strings.Contains(stderr, "unknown format: xml"))
+}
+
+func TestCLI_Query(t *testing.T) {
+	t.Parallel()
+	conf := "http {\n    server {\n        listen 80;\n    }\n    server {\n        listen 443 ssl;\n    }\n}\n"
+	code, stdout, _ := runCLI(t, conf, "query", "listen")
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "-:3: listen 80\n-:6: listen 443 ssl\n")
+
+	code, stdout, _ = runCLI(t, conf, "query", "-format", "json", "listen")
+	assert.Equal(t, code, exitOK)
+	var matches []queryMatch
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &matches))
+	assert.DeepEqual(t, matches[1].Parameters, []string{"443", "ssl"})
+	assert.Equal(t, matches[1].Path, "http > server > listen")
+
+	code, stdout, _ = runCLI(t, conf, "query", "server > listen[param=ssl]")
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "-:6: listen 443 ssl\n")
+
+	code, _, _ = runCLI(t, conf, "query", "proxy_pass")
+	assert.Equal(t, code, exitFail)
+
+	code, _, stderr := runCLI(t, conf, "query", "server[")
+	assert.Equal(t, code, exitError)
+	assert.Assert(t, strings.Contains(stderr, "invalid query"))
+}
+
+func TestCLI_Includes(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"nginx.conf":  "http {\n    include common.conf;\n    server {\n        include common.conf;\n    }\n}\n",
+		"common.conf": "gzip on;\n",
+	} {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	main := filepath.Join(dir, "nginx.conf")
+	code, stdout, _ := runCLI(t, "", "includes", main)
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, filepath.Join(dir, "common.conf")+": included from http, server (2 includes)\n")
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "old.conf"), []byte("gzip off;\n"), 0o644))
+	assert.NilError(t, os.WriteFile(main, []byte("include missing/*.conf;\n"), 0o644))
+	code, stdout, _ = runCLI(t, "", "includes", "-format", "json", main)
+	assert.Equal(t, code, exitFail)
+	var report includeReport
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Equal(t, report.UnmatchedGlobs[0].Pattern, "missing/*.conf")
+	assert.DeepEqual(t, report.Unused, []string{filepath.Join(dir, "common.conf"), filepath.Join(dir, "old.conf")})
+}
+
+func TestCLI_Route(t *testing.T) {
+	t.Parallel()
+	conf := "http {\n    gzip on;\n    server {\n        listen 80 default_server;\n    }\n    server {\n        listen 8080;\n        server_name example.com;\n        location /api/ {\n            proxy_pass http://api;\n        }\n    }\n}\n"
+	code, stdout, _ := runCLI(t, conf, "route", "example.com:8080/api/users?id=1")
+	assert.Equal(t, code, exitOK)
+	assert.Equal(t, stdout, "server: -:6: server_name example.com\nlocation: -:9: location /api/\n  -:2: gzip on\n  -:10: proxy_pass http://api\n")
+
+	code, stdout, _ = runCLI(t, conf, "route", "-format", "json", "http://other.org/")
+	assert.Equal(t, code, exitOK)
+	var result routeResult
+	assert.NilError(t, json.Unmarshal([]byte(stdout), &result))
+	assert.Assert(t, result.DefaultServer)
+	assert.Equal(t, result.Server.Line, 3)
+	assert.Assert(t, result.Location == nil)
+
+	code, stdout, _ = runCLI(t, strings.Replace(conf, "location /api/ {", "location /api/ {\n            rewrite ^/api/(.*)$ /v2/$1 permanent;", 1), "route", "-trace", "example.com:8080/api/users")
+	assert.Equal(t, code, exitOK)
+	assert.Assert(t, strings.HasPrefix(stdout, "1. location location /api/: selected for /api/users\n2. return rewrite ^/api/(.*)$ /v2/$1 permanent: 301 redirect to http://example.com:8080/v2/users\nuri: /api/users\nstatus: 301 http://example.com:8080/v2/users\n"), stdout)
+
+	code, _, stderr := runCLI(t, conf, "route", "https://example.com/")
+	assert.Equal(t, code, exitError)
+	assert.Assert(t, strings.Contains(stderr, "no server listens on port 443"))
+}
+
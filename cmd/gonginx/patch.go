@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/utils"
+)
+
+// patch applies a patch made by diff -format patch with utils.ApplyPatch. The patched config is
+// written with dumper.LosslessStyle, what the patch does not touch keeps its formatting.
+func (c *cli) patch(args []string) int {
+	fs := c.newFlagSet("patch", "[-R] [-dry-run] [-w] patch.json file.conf")
+	reverse := fs.Bool("R", false, "apply the reverse of the patch")
+	dryRun := fs.Bool("dry-run", false, "only check that the patch applies")
+	write := fs.Bool("w", false, "write the result back to the file instead of stdout")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	patchFile, file := fs.Arg(0), fs.Arg(1)
+	if *write && file == "-" {
+		return c.errorf("patch: cannot use -w with standard input")
+	}
+
+	data, err := os.ReadFile(patchFile)
+	if err != nil {
+		return c.errorf("patch: %v", err)
+	}
+	var patch utils.Patch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return c.errorf("patch: %s: %v", patchFile, err)
+	}
+	conf, err := c.parseInput(file, parser.WithSkipValidDirectivesErr(), parser.WithPreserveTrivia())
+	if err != nil {
+		return c.errorf("patch: %s: %v", file, err)
+	}
+
+	var opts []utils.PatchOption
+	if *reverse {
+		opts = append(opts, utils.WithReverse())
+	}
+	if *dryRun {
+		opts = append(opts, utils.WithDryRun())
+	}
+	if err := utils.ApplyPatch(conf, &patch, opts...); err != nil {
+		var patchErr *utils.PatchError
+		if !errors.As(err, &patchErr) {
+			return c.errorf("patch: %s: %v", file, err)
+		}
+		for _, failure := range patchErr.Failures {
+			fmt.Fprintf(c.stderr, "%s: operation %d (%s): %s\n", file, failure.Index+1, failure.Operation, failure.Reason)
+		}
+		return exitFail
+	}
+	if *dryRun {
+		return exitOK
+	}
+
+	patched := dumper.DumpConfig(conf, dumper.LosslessStyle)
+	if !strings.HasSuffix(patched, "\n") {
+		patched += "\n"
+	}
+	if *write {
+		if err := dumper.WriteChanges(map[string]string{file: patched}); err != nil {
+			return c.errorf("patch: %v", err)
+		}
+		return exitOK
+	}
+	fmt.Fprint(c.stdout, patched)
+	return exitOK
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+)
+
+// queryMatch is the JSON form of a matching directive
+type queryMatch struct {
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+	Path       string   `json:"path"`
+	Name       string   `json:"name"`
+	Parameters []string `json:"parameters"`
+}
+
+// query prints the directives matching a config.Query selector, with their position.
+// Like grep it exits with 1 when nothing matches
+func (c *cli) query(args []string) int {
+	fs := c.newFlagSet("query", "[-format text|json|nginx] query [files]")
+	format := fs.String("format", "text", "output format: text, json, or nginx to print whole blocks")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return exitError
+	}
+	switch *format {
+	case "text", "json", "nginx":
+	default:
+		return c.errorf("query: unknown format %q", *format)
+	}
+
+	selector, err := config.CompileQuery(fs.Arg(0))
+	if err != nil {
+		return c.errorf("query: %v", err)
+	}
+
+	files := fs.Args()[1:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	matches := []queryMatch{}
+	for _, file := range files {
+		conf, err := c.parseInput(file, parser.WithSkipValidDirectivesErr())
+		if err != nil {
+			return c.errorf("query: %s: %v", file, err)
+		}
+		results := selector.Select(conf)
+		for _, r := range results {
+			d := r.Directive
+			line := d.GetLine()
+			if r := config.RangeOf(d); r != nil {
+				line = r.Start.Line
+			}
+			params := []string{}
+			for _, p := range d.GetParameters() {
+				params = append(params, p.GetValue())
+			}
+			switch *format {
+			case "nginx":
+				fmt.Fprintln(c.stdout, dumper.DumpDirective(d, dumper.IndentedStyle))
+			case "text":
+				fmt.Fprintf(c.stdout, "%s:%d: %s\n", file, line, strings.Join(append([]string{d.GetName()}, params...), " "))
+			}
+			matches = append(matches, queryMatch{File: file, Line: line, Path: r.Path(), Name: d.GetName(), Parameters: params})
+		}
+	}
+
+	if *format == "json" {
+		data, err := json.MarshalIndent(matches, "", "  ")
+		if err != nil {
+			return c.errorf("query: %v", err)
+		}
+		fmt.Fprintln(c.stdout, string(data))
+	}
+	if len(matches) == 0 {
+		return exitFail
+	}
+	return exitOK
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/sim"
+)
+
+// routeResult is the JSON form of sim.Match
+type routeResult struct {
+	Server        routeDirective    `json:"server"`
+	ServerName    string            `json:"server_name"`
+	DefaultServer bool              `json:"default_server"`
+	Location      *routeDirective   `json:"location"`
+	Captures      map[string]string `json:"captures"`
+	Directives    []routeDirective  `json:"directives"`
+	Warnings      []string          `json:"warnings"`
+	Trace         []string          `json:"trace,omitempty"`
+	URI           string            `json:"uri,omitempty"`
+	Status        int               `json:"status,omitempty"`
+	Redirect      string            `json:"redirect,omitempty"`
+}
+
+// routeDirective is a directive with its position
+type routeDirective struct {
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+	Name       string   `json:"name"`
+	Parameters []string `json:"parameters"`
+}
+
+// route prints the server and location handling a URL, and the directives in effect there.
+// With -trace the rewrite rules are followed first, see sim.Trace
+func (c *cli) route(args []string) int {
+	fs := c.newFlagSet("route", "[-format text|json] [-trace] url [file]")
+	format := fs.String("format", "text", "output format: text or json")
+	trace := fs.Bool("trace", false, "follow rewrite, return, try_files and error_page and print each step")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return exitError
+	}
+	if *format != "text" && *format != "json" {
+		return c.errorf("route: unknown format %q", *format)
+	}
+
+	req, err := parseRequest(fs.Arg(0))
+	if err != nil {
+		return c.errorf("route: %v", err)
+	}
+	file := "-"
+	if fs.NArg() == 2 {
+		file = fs.Arg(1)
+	}
+	opts := []parser.Option{parser.WithSkipValidDirectivesErr()}
+	if file != "-" {
+		opts = append(opts, parser.WithIncludeParsing())
+	}
+	conf, err := c.parseInput(file, opts...)
+	if err != nil {
+		return c.errorf("route: %s: %v", file, err)
+	}
+	var m *sim.Match
+	var traced *sim.TraceResult
+	if *trace {
+		traced, err = sim.Trace(conf, req)
+		if traced != nil {
+			m = traced.Match
+		}
+	} else {
+		m, err = sim.Route(conf, req)
+	}
+	if err != nil {
+		return c.errorf("route: %v", err)
+	}
+
+	position := func(d config.IDirective) routeDirective {
+		rd := routeDirective{File: file, Line: d.GetLine(), Name: d.GetName(), Parameters: []string{}}
+		if origin := conf.OriginOf(d); origin.File != nil && origin.File.FilePath != "" {
+			rd.File = origin.File.FilePath
+		}
+		if r := config.RangeOf(d); r != nil {
+			rd.Line = r.Start.Line
+		}
+		for _, p := range d.GetParameters() {
+			rd.Parameters = append(rd.Parameters, p.GetValue())
+		}
+		return rd
+	}
+	result := routeResult{
+		Server:        position(m.Server),
+		ServerName:    m.ServerName,
+		DefaultServer: m.DefaultServer,
+		Captures:      m.Captures,
+		Directives:    []routeDirective{},
+		Warnings:      m.Warnings,
+	}
+	if m.Location != nil {
+		location := position(m.Location)
+		result.Location = &location
+	}
+	for _, d := range m.Directives {
+		result.Directives = append(result.Directives, position(d))
+	}
+	if traced != nil {
+		for _, step := range traced.Steps {
+			result.Trace = append(result.Trace, step.String())
+		}
+		result.URI, result.Status, result.Redirect = traced.URI, traced.Status, traced.Redirect
+	}
+
+	if *format == "json" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return c.errorf("route: %v", err)
+		}
+		fmt.Fprintln(c.stdout, string(data))
+		return exitOK
+	}
+
+	for i, step := range result.Trace {
+		fmt.Fprintf(c.stdout, "%d. %s\n", i+1, step)
+	}
+	if traced != nil {
+		fmt.Fprintf(c.stdout, "uri: %s\n", traced.URI)
+		if traced.Status != 0 {
+			fmt.Fprintf(c.stdout, "status: %d %s\n", traced.Status, traced.Redirect)
+		}
+	}
+	server := fmt.Sprintf("server_name %s", m.ServerName)
+	if m.DefaultServer {
+		server = "default server"
+	}
+	fmt.Fprintf(c.stdout, "server: %s:%d: %s\n", result.Server.File, result.Server.Line, server)
+	if loc := result.Location; loc != nil {
+		fmt.Fprintf(c.stdout, "location: %s:%d: location %s\n", loc.File, loc.Line, strings.Join(loc.Parameters, " "))
+	} else {
+		fmt.Fprintln(c.stdout, "location: none")
+	}
+	for _, d := range result.Directives {
+		fmt.Fprintf(c.stdout, "  %s:%d: %s\n", d.File, d.Line, strings.Join(append([]string{d.Name}, d.Parameters...), " "))
+	}
+	for _, warning := range m.Warnings {
+		fmt.Fprintf(c.stderr, "route: warning: %s\n", warning)
+	}
+	return exitOK
+}
+
+// parseRequest turns a URL like https://example.com:8443/path into a request
+func parseRequest(rawURL string) (sim.Request, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return sim.Request{}, err
+	}
+	req := sim.Request{Scheme: u.Scheme, Host: u.Host, Path: u.EscapedPath()}
+	if u.RawQuery != "" {
+		req.Path += "?" + u.RawQuery
+	}
+	if port := u.Port(); port != "" {
+		if req.Port, err = strconv.Atoi(port); err != nil {
+			return sim.Request{}, fmt.Errorf("invalid port %q", port)
+		}
+	}
+	return req, nil
+}
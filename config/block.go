@@ -57,3 +57,13 @@ func (b *Block) AddDirective(directive IDirective) {
 func (b *Block) SetDirectives(directives []IDirective) {
 	b.Directives = directives
 }
+
+// Clone returns a deep copy of the block and its directives, detached from its parent.
+func (b *Block) Clone() *Block {
+	if b == nil {
+		return nil
+	}
+	clone := cloneTree(b, b.Parent).(*Block)
+	clone.Parent = nil
+	return clone
+}
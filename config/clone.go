@@ -0,0 +1,112 @@
+package config
+
+import "reflect"
+
+// Clone returns a deep copy of directive and everything below it, including the
+// typed fields of the wrappers, comments, parameter types, trivia and resolved
+// include configs. Parent pointers inside the copy point to the copied
+// directives, the copy itself is detached and has no parent.
+func Clone(directive IDirective) IDirective {
+	if directive == nil || isNilPointer(directive) {
+		return directive
+	}
+	clone := cloneTree(directive, directive.GetParent()).(IDirective)
+	clone.SetParent(nil)
+	return clone
+}
+
+// cloneTree deep copies root, references to outside are set to nil in the copy
+func cloneTree(root interface{}, outside IDirective) interface{} {
+	c := &cloner{copies: map[cloneKey]reflect.Value{}}
+	r := reflect.ValueOf(root)
+	if outside != nil && !isNilPointer(outside) {
+		// the parent is outside of the copied tree, it must not be copied with it.
+		// The parser makes leaf directives at the top level their own parent
+		if o := reflect.ValueOf(outside); o.Kind() == reflect.Ptr && o.Pointer() != r.Pointer() {
+			c.copies[cloneKey{o.Pointer(), o.Type()}] = reflect.Zero(o.Type())
+		}
+	}
+	return c.copy(r).Interface()
+}
+
+// isNilPointer returns true if v holds a nil pointer
+func isNilPointer(v interface{}) bool {
+	r := reflect.ValueOf(v)
+	return r.Kind() == reflect.Ptr && r.IsNil()
+}
+
+type cloneKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// cloner deep copies values, remembering the copied pointers so that shared
+// pointers and the cycles made by parent pointers are kept as they are
+type cloner struct {
+	copies map[cloneKey]reflect.Value
+}
+
+func (c *cloner) copy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		key := cloneKey{v.Pointer(), v.Type()}
+		if copied, ok := c.copies[key]; ok {
+			return copied
+		}
+		copied := reflect.New(v.Type().Elem())
+		c.copies[key] = copied
+		copied.Elem().Set(c.copy(v.Elem()))
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := c.copy(v.Elem())
+		if copied.Kind() == reflect.Ptr && copied.IsNil() {
+			// do not turn a nil parent into a non nil interface holding a nil pointer
+			return reflect.Zero(v.Type())
+		}
+		result := reflect.New(v.Type()).Elem()
+		result.Set(copied)
+		return result
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(c.copy(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), c.copy(iter.Value()))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		// unexported fields, like the trivia fingerprints, only hold plain values
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := copied.Field(i); field.CanSet() {
+				field.Set(c.copy(v.Field(i)))
+			}
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(c.copy(v.Index(i)))
+		}
+		return copied
+	}
+	return v
+}
@@ -0,0 +1,150 @@
+package config_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+const cloneConfig = `# main
+user nginx; # inline
+http {
+    limit_conn_zone $binary_remote_addr zone=addr:10m;
+    limit_req_zone $binary_remote_addr zone=one:10m rate=1r/s;
+    proxy_cache_path /data/cache levels=1:2 keys_zone=cache:10m;
+    map $host $name {
+        default a;
+        example.com b;
+    }
+    geo $country {
+        default ZZ;
+        127.0.0.0/8 LO;
+    }
+    split_clients "${remote_addr}" $variant {
+        50% a;
+        * b;
+    }
+    upstream backend {
+        server 127.0.0.1:8080 weight=5;
+        keepalive 8;
+    }
+    server {
+        listen 80;
+        location / {
+            root /var/www;
+            content_by_lua_block {
+                ngx.say("hello")
+            }
+        }
+    }
+}
+stream {
+    upstream tcp {
+        server 10.0.0.1:53;
+    }
+    server {
+        listen 53;
+    }
+}
+`
+
+func TestClone_AllDirectives(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(cloneConfig).Parse()
+	assert.NilError(t, err)
+
+	clone := conf.Clone()
+	assert.Equal(t, dumper.DumpConfig(clone, dumper.IndentedStyle), dumper.DumpConfig(conf, dumper.IndentedStyle))
+
+	// every directive was copied with its type, and parents point inside the copy
+	var original []config.IDirective
+	_ = config.Walk(conf, config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		original = append(original, c.Directive())
+		return nil
+	}})
+	i := 0
+	err = config.Walk(clone, config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		d := c.Directive()
+		assert.Assert(t, d != original[i], "%s was not copied", d.GetName())
+		assert.Equal(t, typeName(d), typeName(original[i]))
+		if parent := c.Parent(); parent != nil {
+			assert.Equal(t, d.GetParent(), parent, "parent of %s", d.GetName())
+		}
+		i++
+		return nil
+	}})
+	assert.NilError(t, err)
+	assert.Equal(t, i, len(original))
+
+	user := clone.FindDirectives("user")[0]
+	assert.DeepEqual(t, user.GetComment(), []string{"# main"})
+	assert.Equal(t, user.GetInlineComment()[0].Value, "# inline")
+	weight := clone.FindUpstreams()[0].UpstreamServers[0]
+	assert.Equal(t, weight.Parameters["weight"], "5")
+}
+
+func TestClone_Independent(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(cloneConfig).Parse()
+	assert.NilError(t, err)
+	http := conf.FindDirectives("http")[0].(*config.HTTP)
+	server := http.Servers[0]
+
+	clone := server.Clone()
+	assert.Assert(t, clone.GetParent() == nil)
+	location := clone.FindDirectives("location")[0].(*config.Location)
+	assert.Equal(t, location.GetParent(), config.IDirective(clone))
+	root := location.FindDirectives("root")[0].(*config.Directive)
+	assert.Equal(t, root.Parameters[0].Type, config.ParameterTypePath)
+	root.Parameters[0].Value = "/srv"
+	assert.Equal(t, server.FindDirectives("root")[0].GetParameters()[0].GetValue(), "/var/www")
+
+	// the copy can be inserted next to the original
+	assert.NilError(t, config.InsertAfter(http, server, clone))
+	assert.Equal(t, len(http.Servers), 2)
+	assert.Equal(t, clone.GetParent(), config.IDirective(http))
+
+	upstream := conf.FindUpstreams()[0].Clone()
+	assert.Equal(t, upstream.UpstreamServers[0].GetParent(), config.IDirective(upstream))
+	upstream.UpstreamServers[0].Address = "10.0.0.2:80"
+	assert.Equal(t, conf.FindUpstreams()[0].UpstreamServers[0].Address, "127.0.0.1:8080")
+
+	// top level leaf directives are parsed as their own parent
+	user := conf.FindDirectives("user")[0]
+	assert.Assert(t, config.Clone(user).GetParent() == nil)
+	assert.Assert(t, config.Clone(nil) == nil)
+}
+
+func TestClone_Includes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	included := filepath.Join(dir, "site.conf")
+	assert.NilError(t, os.WriteFile(included, []byte("server {\n    listen 80;\n}\n"), 0o644))
+
+	conf, err := parser.NewStringParser("http {\n    include "+included+";\n}\n", parser.WithIncludeParsing()).Parse()
+	assert.NilError(t, err)
+
+	clone := conf.Clone()
+	include := clone.FindDirectives("include")[0].(*config.Include)
+	assert.Equal(t, len(include.Configs), 1)
+	assert.Equal(t, include.Configs[0].FilePath, included)
+	assert.Equal(t, len(clone.FindDirectives("listen")), 1)
+
+	// what-if edits on the copy leave the included file of the original alone
+	listen := clone.FindDirectives("listen")[0].(*config.Directive)
+	listen.Parameters[0].Value = "8080"
+	assert.Equal(t, conf.FindDirectives("listen")[0].GetParameters()[0].GetValue(), "80")
+}
+
+func typeName(d config.IDirective) string {
+	return fmt.Sprintf("%T", d)
+}
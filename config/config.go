@@ -1,9 +1,60 @@
 package config
 
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
 // Config represents a complete nginx configuration file.
 type Config struct {
 	*Block
 	FilePath string
+	Trailing string // source text after the last directive, kept when parsed with trivia
+
+	snapshot     string // fingerprint of the config when parsed or last written
+	snapshotPath string // file path of the config when the snapshot was taken
+	snapshotted  bool
+}
+
+// Snapshot records the current state of the config so that Modified can tell
+// whether it changed. The parser takes one for every file it parses.
+func (c *Config) Snapshot() {
+	c.snapshot = c.fingerprint()
+	c.snapshotPath = c.FilePath
+	c.snapshotted = true
+}
+
+// Modified returns true if the directives or the path of the config changed since
+// the last Snapshot, or if it never had one. Included files are separate configs
+// and are not taken into account.
+func (c *Config) Modified() bool {
+	return !c.snapshotted || c.snapshotPath != c.FilePath || c.snapshot != c.fingerprint()
+}
+
+// fingerprint builds a string that changes whenever anything written for the config changes
+func (c *Config) fingerprint() string {
+	var buf strings.Builder
+	if c.Block != nil {
+		for _, directive := range c.Block.GetDirectives() {
+			writeFingerprint(&buf, directive, true)
+			buf.WriteByte('\x00')
+		}
+	}
+	buf.WriteString(c.Trailing)
+	return buf.String()
+}
+
+// Clone returns a deep copy of the config, including the files resolved by its include directives.
+func (c *Config) Clone() *Config {
+	if c == nil {
+		return nil
+	}
+	// an included config is cloned without the tree that includes it
+	if c.Block == nil {
+		return cloneTree(c, nil).(*Config)
+	}
+	return cloneTree(c, c.Parent).(*Config)
 }
 
 // Global wrappers provide extension points for custom directive handling.
@@ -69,8 +120,10 @@ func (c *Config) FindUpstreams() []*Upstream {
 	var upstreams []*Upstream
 	directives := c.Block.FindDirectives("upstream")
 	for _, directive := range directives {
-		//	up, _ := NewUpstream(directive)
-		upstreams = append(upstreams, directive.(*Upstream))
+		// stream upstreams are *StreamUpstream
+		if upstream, ok := directive.(*Upstream); ok {
+			upstreams = append(upstreams, upstream)
+		}
 	}
 	return upstreams
 }
@@ -112,69 +165,34 @@ func (c *Config) FindUpstreamByName(name string) *Upstream {
 // FindLocationsByPattern finds locations by pattern (exact match or regex)
 func (c *Config) FindLocationsByPattern(pattern string) []*Location {
 	var locations []*Location
-	c.findLocationsRecursive(c.Block, pattern, &locations)
-	return locations
-}
-
-// findLocationsRecursive recursively finds locations in blocks
-func (c *Config) findLocationsRecursive(block IBlock, pattern string, locations *[]*Location) {
-	if block == nil {
-		return
-	}
-
-	directives := block.GetDirectives()
-	for _, directive := range directives {
-		if directive.GetName() == "location" {
-			if location, ok := directive.(*Location); ok {
-				// Check if pattern matches location's match string
-				// For locations with modifiers, we need to check the full pattern
-				var fullPattern string
-				if location.Modifier != "" {
-					fullPattern = location.Modifier + " " + location.Match
-				} else {
-					fullPattern = location.Match
-				}
-
-				if fullPattern == pattern || location.Match == pattern {
-					*locations = append(*locations, location)
-				}
+	_ = Walk(c, VisitorFuncs{EnterFunc: func(cur *Cursor) error {
+		// For locations with modifiers, we need to check the full pattern
+		if location, ok := cur.Directive().(*Location); ok {
+			fullPattern := location.Match
+			if location.Modifier != "" {
+				fullPattern = location.Modifier + " " + location.Match
+			}
+			if fullPattern == pattern || location.Match == pattern {
+				locations = append(locations, location)
 			}
 		}
-
-		// Recursively search in nested blocks
-		if directive.GetBlock() != nil {
-			c.findLocationsRecursive(directive.GetBlock(), pattern, locations)
-		}
-	}
+		return nil
+	}})
+	return locations
 }
 
 // GetAllSSLCertificates gets all SSL certificate paths from the configuration
 func (c *Config) GetAllSSLCertificates() []string {
 	var certificates []string
-	c.findSSLCertificatesRecursive(c.Block, &certificates)
-	return certificates
-}
-
-// findSSLCertificatesRecursive recursively finds SSL certificates in blocks
-func (c *Config) findSSLCertificatesRecursive(block IBlock, certificates *[]string) {
-	if block == nil {
-		return
-	}
-
-	directives := block.GetDirectives()
-	for _, directive := range directives {
-		if directive.GetName() == "ssl_certificate" {
-			params := directive.GetParameters()
-			if len(params) > 0 {
-				*certificates = append(*certificates, params[0].GetValue())
+	_ = Walk(c, VisitorFuncs{EnterFunc: func(cur *Cursor) error {
+		if cur.Directive().GetName() == "ssl_certificate" {
+			if params := cur.Directive().GetParameters(); len(params) > 0 {
+				certificates = append(certificates, params[0].GetValue())
 			}
 		}
-
-		// Recursively search in nested blocks
-		if directive.GetBlock() != nil {
-			c.findSSLCertificatesRecursive(directive.GetBlock(), certificates)
-		}
-	}
+		return nil
+	}})
+	return certificates
 }
 
 // GetAllUpstreamServers gets all upstream servers from all upstream blocks
@@ -519,3 +537,13 @@ func init() {
 		return NewInclude(directive)
 	}
 }
+
+// FSName returns the name of a config file path in an fs.FS: slash separated and relative to
+// its root, absolute paths start at the root and the root itself is "."
+func FSName(name string) string {
+	name = strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
@@ -36,6 +36,7 @@ type ValidationIssue struct {
 	Category    string
 	Title       string
 	Description string
+	File        string // the file of the directive when known, it may be an included file
 	Line        int
 	Directive   string
 	Context     string
@@ -102,6 +103,7 @@ type ConfigValidator struct {
 	contextValidator    *ContextValidator
 	dependencyValidator *DependencyValidator
 	enableAllChecks     bool
+	profile             *Profile // the build the configuration targets, nil for any
 }
 
 // NewConfigValidator creates a new configuration validator
@@ -122,6 +124,14 @@ func NewConfigValidatorWithOptions(enableAllChecks bool) *ConfigValidator {
 	}
 }
 
+// NewConfigValidatorForProfile creates a configuration validator that also reports the directives
+// and parameters the target build does not have or has deprecated, see LookupProfile
+func NewConfigValidatorForProfile(profile *Profile) *ConfigValidator {
+	cv := NewConfigValidator()
+	cv.profile = profile
+	return cv
+}
+
 // ValidateConfig performs comprehensive validation of a configuration
 func (cv *ConfigValidator) ValidateConfig(config *Config) *ValidationReport {
 	report := &ValidationReport{
@@ -139,6 +149,7 @@ func (cv *ConfigValidator) ValidateConfig(config *Config) *ValidationReport {
 					Category:    "Context",
 					Title:       "Invalid directive context",
 					Description: contextErr.Message,
+					File:        contextErr.File,
 					Line:        contextErr.Line,
 					Directive:   contextErr.Directive,
 					Context:     contextErr.Context,
@@ -172,6 +183,16 @@ func (cv *ConfigValidator) ValidateConfig(config *Config) *ValidationReport {
 		}
 	}
 
+	// Variable validation
+	if cv.enableAllChecks {
+		report.Issues = append(report.Issues, cv.validateVariables(config)...)
+	}
+
+	// Profile validation
+	if cv.profile != nil {
+		report.Issues = append(report.Issues, cv.validateProfile(config)...)
+	}
+
 	// Structural validation
 	structuralIssues := cv.validateStructure(config)
 	report.Issues = append(report.Issues, structuralIssues...)
@@ -186,47 +207,88 @@ func (cv *ConfigValidator) ValidateConfig(config *Config) *ValidationReport {
 	return report
 }
 
-// validateStructure performs structural validation of the configuration
-func (cv *ConfigValidator) validateStructure(config *Config) []ValidationIssue {
+// validateVariables reports the variables that are used without being defined, and those defined but never used
+func (cv *ConfigValidator) validateVariables(config *Config) []ValidationIssue {
 	var issues []ValidationIssue
-
-	// Check for multiple http blocks
-	httpBlocks := config.FindDirectives("http")
-	if len(httpBlocks) > 1 {
-		for i, httpBlock := range httpBlocks {
-			if i > 0 { // First one is OK
-				issues = append(issues, ValidationIssue{
-					Level:       ValidationError,
-					Category:    "Structure",
-					Title:       "Multiple HTTP blocks",
-					Description: "Only one http block is allowed in nginx configuration",
-					Line:        httpBlock.GetLine(),
-					Directive:   "http",
-					Context:     "main",
-					Fix:         "Merge all http configurations into a single http block",
-				})
-			}
-		}
+	analysis := AnalyzeVariables(config)
+	for _, use := range analysis.Undefined() {
+		issues = append(issues, ValidationIssue{
+			Level:       ValidationWarning,
+			Category:    "Variable",
+			Title:       "Undefined variable",
+			Description: fmt.Sprintf("Variable '$%s' is neither defined in the configuration nor built into nginx", use.Name),
+			File:        filePath(use.File),
+			Line:        use.Directive.GetLine(),
+			Directive:   use.Directive.GetName(),
+			Context:     use.Context,
+			Fix:         "Define the variable with set, map, geo or split_clients, or fix its name",
+		})
+	}
+	for _, def := range analysis.Unused() {
+		issues = append(issues, ValidationIssue{
+			Level:       ValidationInfo,
+			Category:    "Variable",
+			Title:       "Unused variable",
+			Description: fmt.Sprintf("Variable '$%s' is defined but never used", def.Name),
+			File:        filePath(def.File),
+			Line:        def.Directive.GetLine(),
+			Directive:   def.Directive.GetName(),
+			Context:     def.Context,
+			Fix:         "Remove the definition if nothing reads the variable",
+		})
 	}
+	return issues
+}
 
-	// Check for multiple events blocks
-	eventsBlocks := config.FindDirectives("events")
-	if len(eventsBlocks) > 1 {
-		for i, eventsBlock := range eventsBlocks {
-			if i > 0 { // First one is OK
-				issues = append(issues, ValidationIssue{
-					Level:       ValidationError,
-					Category:    "Structure",
-					Title:       "Multiple events blocks",
-					Description: "Only one events block is allowed in nginx configuration",
-					Line:        eventsBlock.GetLine(),
-					Directive:   "events",
-					Context:     "main",
-					Fix:         "Merge all events configurations into a single events block",
-				})
+// validateProfile reports the directives and parameters that are not available in the profile
+func (cv *ConfigValidator) validateProfile(config *Config) []ValidationIssue {
+	var issues []ValidationIssue
+	_ = Walk(config, VisitorFuncs{EnterFunc: func(c *Cursor) error {
+		directive := c.Directive()
+		var params []string
+		for _, param := range directive.GetParameters() {
+			params = append(params, param.Value)
+		}
+		for _, issue := range cv.profile.CheckDirective(directive.GetName(), params) {
+			title := "Directive not available"
+			fix := fmt.Sprintf("Remove the directive or target a build of %s that has it", cv.profile.Product)
+			switch {
+			case issue.Level == ValidationWarning:
+				title, fix = "Deprecated directive", "Replace the deprecated directive or parameter"
+			case issue.Parameter != "":
+				title, fix = "Parameter not available", fmt.Sprintf("Remove the %s parameter", issue.Parameter)
 			}
+			issues = append(issues, ValidationIssue{
+				Level:       issue.Level,
+				Category:    "Profile",
+				Title:       title,
+				Description: issue.Message,
+				File:        filePath(c.File()),
+				Line:        directive.GetLine(),
+				Directive:   directive.GetName(),
+				Context:     c.Context(),
+				Fix:         fix,
+			})
 		}
+		return nil
+	}}, WalkIncludes())
+	return issues
+}
+
+// filePath returns the path of a file, empty when it is unknown
+func filePath(c *Config) string {
+	if c == nil {
+		return ""
 	}
+	return c.FilePath
+}
+
+// validateStructure performs structural validation of the configuration
+func (cv *ConfigValidator) validateStructure(config *Config) []ValidationIssue {
+	var issues []ValidationIssue
+
+	// Check for directives given twice in a block while nginx allows them once
+	issues = append(issues, cv.validateDuplicates(config, "main")...)
 
 	// Check for server_name conflicts
 	issues = append(issues, cv.validateServerNameConflicts(config)...)
@@ -237,6 +299,36 @@ func (cv *ConfigValidator) validateStructure(config *Config) []ValidationIssue {
 	return issues
 }
 
+// validateDuplicates reports the directives that are not repeatable but given more than once in a block
+func (cv *ConfigValidator) validateDuplicates(block IBlock, context string) []ValidationIssue {
+	var issues []ValidationIssue
+	if block == nil {
+		return issues
+	}
+
+	seen := make(map[string]bool)
+	for _, directive := range block.GetDirectives() {
+		name := directive.GetName()
+		if schema, ok := LookupDirective(name, context); ok && !schema.Repeatable {
+			if seen[name] {
+				issues = append(issues, ValidationIssue{
+					Level:       ValidationError,
+					Category:    "Structure",
+					Title:       "Duplicate directive",
+					Description: fmt.Sprintf("%s directive is given more than once in %s context, nginx allows it only once", name, context),
+					Line:        directive.GetLine(),
+					Directive:   name,
+					Context:     context,
+					Fix:         fmt.Sprintf("Merge the %s directives into one", name),
+				})
+			}
+			seen[name] = true
+		}
+		issues = append(issues, cv.validateDuplicates(directive.GetBlock(), blockContext(context, name))...)
+	}
+	return issues
+}
+
 // validateServerNameConflicts checks for conflicting server_name directives
 func (cv *ConfigValidator) validateServerNameConflicts(config *Config) []ValidationIssue {
 	var issues []ValidationIssue
@@ -389,182 +481,63 @@ func (cv *ConfigValidator) validateBlockParameters(block IBlock, context string)
 	return issues
 }
 
-// validateDirectiveParameters validates parameters for a specific directive
+// validateDirectiveParameters validates the parameters of a directive against its schema,
+// see RegisterDirective. Directives without a schema for the context are not checked.
 func (cv *ConfigValidator) validateDirectiveParameters(directive IDirective, context string) []ValidationIssue {
 	var issues []ValidationIssue
 
-	directiveName := directive.GetName()
-	params := directive.GetParameters()
-
-	// Validate based on directive type
-	switch directiveName {
-	case "listen":
-		issues = append(issues, cv.validateListenParameters(directive, params)...)
-	case "ssl_certificate", "ssl_certificate_key":
-		issues = append(issues, cv.validateSSLFileParameters(directive, params)...)
-	case "proxy_pass":
-		issues = append(issues, cv.validateProxyPassParameters(directive, params)...)
-	case "root", "alias":
-		issues = append(issues, cv.validatePathParameters(directive, params)...)
-	case "worker_processes":
-		issues = append(issues, cv.validateWorkerProcessesParameters(directive, params)...)
-	case "worker_connections":
-		issues = append(issues, cv.validateWorkerConnectionsParameters(directive, params)...)
-	}
-
-	return issues
-}
-
-// validateListenParameters validates listen directive parameters
-func (cv *ConfigValidator) validateListenParameters(directive IDirective, params []Parameter) []ValidationIssue {
-	var issues []ValidationIssue
-
-	if len(params) == 0 {
-		issues = append(issues, ValidationIssue{
-			Level:       ValidationError,
-			Category:    "Parameter",
-			Title:       "Missing listen address/port",
-			Description: "listen directive requires at least one parameter",
-			Line:        directive.GetLine(),
-			Directive:   "listen",
-			Fix:         "Add port number or address:port (e.g., 'listen 80;')",
-		})
-	}
-
-	return issues
-}
-
-// validateSSLFileParameters validates SSL file parameters
-func (cv *ConfigValidator) validateSSLFileParameters(directive IDirective, params []Parameter) []ValidationIssue {
-	var issues []ValidationIssue
-
-	if len(params) == 0 {
-		issues = append(issues, ValidationIssue{
-			Level:       ValidationError,
-			Category:    "Parameter",
-			Title:       "Missing SSL file path",
-			Description: fmt.Sprintf("%s directive requires a file path", directive.GetName()),
-			Line:        directive.GetLine(),
-			Directive:   directive.GetName(),
-			Fix:         "Add path to SSL certificate or key file",
-		})
-	} else {
-		// Check if parameter looks like a file path
-		for _, param := range params {
-			if param.Type != ParameterTypePath && param.Type != ParameterTypeString {
-				issues = append(issues, ValidationIssue{
-					Level:       ValidationWarning,
-					Category:    "Parameter",
-					Title:       "SSL file path format",
-					Description: fmt.Sprintf("Parameter '%s' may not be a valid file path", param.Value),
-					Line:        directive.GetLine(),
-					Directive:   directive.GetName(),
-					Fix:         "Ensure parameter is a valid file path",
-				})
-			}
-		}
+	schema, ok := LookupDirective(directive.GetName(), context)
+	if !ok {
+		return issues
 	}
-
-	return issues
-}
-
-// validateProxyPassParameters validates proxy_pass directive parameters
-func (cv *ConfigValidator) validateProxyPassParameters(directive IDirective, params []Parameter) []ValidationIssue {
-	var issues []ValidationIssue
-
-	if len(params) == 0 {
+	issue := func(level ValidationLevel, title, description, fix string) {
 		issues = append(issues, ValidationIssue{
-			Level:       ValidationError,
+			Level:       level,
 			Category:    "Parameter",
-			Title:       "Missing proxy target",
-			Description: "proxy_pass directive requires a target URL or upstream name",
-			Line:        directive.GetLine(),
-			Directive:   "proxy_pass",
-			Fix:         "Add target URL (e.g., 'http://backend') or upstream name",
-		})
-	}
-
-	return issues
-}
-
-// validatePathParameters validates path-related directive parameters
-func (cv *ConfigValidator) validatePathParameters(directive IDirective, params []Parameter) []ValidationIssue {
-	var issues []ValidationIssue
-
-	if len(params) == 0 {
-		issues = append(issues, ValidationIssue{
-			Level:       ValidationError,
-			Category:    "Parameter",
-			Title:       "Missing path",
-			Description: fmt.Sprintf("%s directive requires a path parameter", directive.GetName()),
+			Title:       title,
+			Description: description,
 			Line:        directive.GetLine(),
 			Directive:   directive.GetName(),
-			Fix:         "Add file system path",
+			Context:     context,
+			Fix:         fix,
 		})
 	}
 
-	return issues
-}
-
-// validateWorkerProcessesParameters validates worker_processes directive parameters
-func (cv *ConfigValidator) validateWorkerProcessesParameters(directive IDirective, params []Parameter) []ValidationIssue {
-	var issues []ValidationIssue
-
-	if len(params) == 0 {
-		issues = append(issues, ValidationIssue{
-			Level:       ValidationError,
-			Category:    "Parameter",
-			Title:       "Missing worker processes count",
-			Description: "worker_processes directive requires a number or 'auto'",
-			Line:        directive.GetLine(),
-			Directive:   "worker_processes",
-			Fix:         "Add number of worker processes (e.g., 'auto' or '4')",
-		})
-	} else if len(params) > 1 {
-		issues = append(issues, ValidationIssue{
-			Level:       ValidationWarning,
-			Category:    "Parameter",
-			Title:       "Too many parameters",
-			Description: "worker_processes directive should have only one parameter",
-			Line:        directive.GetLine(),
-			Directive:   "worker_processes",
-			Fix:         "Use only one parameter",
-		})
+	params := directive.GetParameters()
+	switch {
+	case len(params) < schema.MinArgs:
+		issue(ValidationError, "Missing parameters",
+			fmt.Sprintf("%s directive requires at least %d parameter(s), got %d", directive.GetName(), schema.MinArgs, len(params)),
+			fmt.Sprintf("Add the missing parameters, see https://nginx.org/r/%s", directive.GetName()))
+	case schema.MaxArgs != Unlimited && len(params) > schema.MaxArgs:
+		issue(ValidationError, "Too many parameters",
+			fmt.Sprintf("%s directive takes at most %d parameter(s), got %d", directive.GetName(), schema.MaxArgs, len(params)),
+			fmt.Sprintf("Remove the extra parameters, see https://nginx.org/r/%s", directive.GetName()))
 	}
-
-	return issues
-}
-
-// validateWorkerConnectionsParameters validates worker_connections directive parameters
-func (cv *ConfigValidator) validateWorkerConnectionsParameters(directive IDirective, params []Parameter) []ValidationIssue {
-	var issues []ValidationIssue
-
-	if len(params) == 0 {
-		issues = append(issues, ValidationIssue{
-			Level:       ValidationError,
-			Category:    "Parameter",
-			Title:       "Missing worker connections count",
-			Description: "worker_connections directive requires a number",
-			Line:        directive.GetLine(),
-			Directive:   "worker_connections",
-			Fix:         "Add number of worker connections (e.g., '1024')",
-		})
-	} else {
-		for _, param := range params {
-			if param.Type != ParameterTypeNumber {
-				issues = append(issues, ValidationIssue{
-					Level:       ValidationWarning,
-					Category:    "Parameter",
-					Title:       "Invalid worker connections format",
-					Description: fmt.Sprintf("Parameter '%s' should be a number", param.Value),
-					Line:        directive.GetLine(),
-					Directive:   "worker_connections",
-					Fix:         "Use a numeric value",
-				})
+	for i, param := range params {
+		if !schema.Accepts(i, param.Value) {
+			argType := schema.ArgTypes[min(i, len(schema.ArgTypes)-1)]
+			expected := "a " + argType.String()
+			if argType == ParameterTypeBoolean {
+				expected = "'on' or 'off'"
 			}
+			issue(ValidationWarning, "Invalid parameter type",
+				fmt.Sprintf("Parameter '%s' of %s should be %s", param.Value, directive.GetName(), expected),
+				fmt.Sprintf("Use %s", expected))
 		}
 	}
 
+	switch {
+	case schema.Block && directive.GetBlock() == nil:
+		issue(ValidationError, "Missing block",
+			fmt.Sprintf("%s directive requires a block", directive.GetName()),
+			fmt.Sprintf("Add a { } block to %s", directive.GetName()))
+	case !schema.Block && directive.GetBlock() != nil:
+		issue(ValidationError, "Unexpected block",
+			fmt.Sprintf("%s directive does not take a block", directive.GetName()),
+			"End the directive with ';' instead of a block")
+	}
+
 	return issues
 }
 
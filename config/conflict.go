@@ -0,0 +1,20 @@
+package config
+
+// Conflict stands in a merged config for a directive the two sides of a three-way merge
+// changed differently, see utils.Merge. It behaves as the directive of Ours, or of Theirs
+// when ours removed it. The dumper writes both sides between comment markers when asked to.
+type Conflict struct {
+	IDirective            // the directive in effect
+	Path       string     // the path of the directive, like http/server[example.com]/root
+	Ours       IDirective // nil when ours removed the directive
+	Theirs     IDirective // nil when theirs removed the directive
+}
+
+// NewConflict returns the conflict between the directives of both sides, one of them may be nil
+func NewConflict(path string, ours, theirs IDirective) *Conflict {
+	resolved := ours
+	if resolved == nil {
+		resolved = theirs
+	}
+	return &Conflict{IDirective: resolved, Path: path, Ours: ours, Theirs: theirs}
+}
@@ -7,6 +7,8 @@ type Directive struct {
 	Parameters []Parameter //TODO: Save parameters with their type
 	Comment    []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -55,3 +57,8 @@ func (d *Directive) GetBlock() IBlock {
 func (d *Directive) GetComment() []string {
 	return d.Comment
 }
+
+// Clone returns a deep copy of the directive, detached from its parent.
+func (d *Directive) Clone() *Directive {
+	return Clone(d).(*Directive)
+}
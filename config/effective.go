@@ -0,0 +1,154 @@
+package config
+
+// InheritanceRule tells how a directive passes from a block to the blocks inside it.
+// By default a directive is inherited unless the inner block gives it again, in which
+// case none of the outer occurrences are kept. That is how nginx merges both single
+// value directives like root and array directives like add_header or proxy_set_header.
+type InheritanceRule struct {
+	// NotInherited directives only apply to the block they are written in, like return or proxy_pass
+	NotInherited bool
+	// Group names directives that are inherited together: giving one of them in a block drops
+	// all of them from the outer blocks, like allow and deny. Empty means the directive alone.
+	Group string
+}
+
+// InheritanceRules is the per directive table used by Effective, directives missing from it
+// follow the default rule. Directives of third party modules can be added to it.
+var InheritanceRules = map[string]InheritanceRule{
+	// blocks opening a context of their own
+	"server":        {NotInherited: true},
+	"location":      {NotInherited: true},
+	"if":            {NotInherited: true},
+	"limit_except":  {NotInherited: true},
+	"upstream":      {NotInherited: true},
+	"map":           {NotInherited: true},
+	"geo":           {NotInherited: true},
+	"split_clients": {NotInherited: true},
+
+	// server identity
+	"listen":      {NotInherited: true},
+	"server_name": {NotInherited: true},
+
+	// rewrite module, run in the block they are written in
+	"rewrite": {NotInherited: true},
+	"return":  {NotInherited: true},
+	"set":     {NotInherited: true},
+	"break":   {NotInherited: true},
+
+	// content handlers
+	"proxy_pass":           {NotInherited: true},
+	"fastcgi_pass":         {NotInherited: true},
+	"uwsgi_pass":           {NotInherited: true},
+	"scgi_pass":            {NotInherited: true},
+	"grpc_pass":            {NotInherited: true},
+	"memcached_pass":       {NotInherited: true},
+	"stub_status":          {NotInherited: true},
+	"js_content":           {NotInherited: true},
+	"content_by_lua":       {NotInherited: true},
+	"content_by_lua_block": {NotInherited: true},
+	"content_by_lua_file":  {NotInherited: true},
+	"alias":                {NotInherited: true},
+	"internal":             {NotInherited: true},
+	"try_files":            {NotInherited: true},
+
+	// access rules are a single list
+	"allow": {Group: "access"},
+	"deny":  {Group: "access"},
+}
+
+// inheritanceRule returns the rule of a directive
+func inheritanceRule(name string) InheritanceRule {
+	rule := InheritanceRules[name]
+	if rule.Group == "" {
+		rule.Group = name
+	}
+	return rule
+}
+
+// EffectiveDirective is a directive in effect in a block, with where its value comes from
+type EffectiveDirective struct {
+	Directive IDirective
+	Block     IDirective // the block giving the directive, the resolved block or one around it
+	Inherited bool       // the directive is given by a block around the resolved one
+	Include   *Include   // the include the directive was read through, nil when it is written in Block
+	File      *Config    // the file of Include holding the directive
+}
+
+// Effective returns the directives in effect in a server, location or other block, after
+// nginx inheritance: the directives of the block itself, and those of the blocks around it
+// that it does not give again, see InheritanceRules. Included files are read in place of their
+// include. Directives are returned from the outermost block to the block itself, in file order.
+// The blocks around are found through the parents, as set by the parser.
+func Effective(block IDirective) []*EffectiveDirective {
+	levels := []IDirective{block}
+	for d := block; ; {
+		parent := d.GetParent()
+		if parent == nil || parent == d {
+			break
+		}
+		d = parent
+		if _, ok := d.(*Include); ok {
+			continue
+		}
+		levels = append([]IDirective{d}, levels...)
+	}
+	return EffectiveIn(levels...)
+}
+
+// EffectiveIn is Effective for blocks given explicitly, from the outermost to the resolved one
+func EffectiveIn(levels ...IDirective) []*EffectiveDirective {
+	contents := make([][]*EffectiveDirective, len(levels))
+	winner := map[string]int{}
+	for i, level := range levels {
+		contents[i] = levelDirectives(level, level.GetBlock(), nil, nil)
+		for _, e := range contents[i] {
+			rule := inheritanceRule(e.Directive.GetName())
+			if !rule.NotInherited || i == len(levels)-1 {
+				winner[rule.Group] = i
+			}
+		}
+	}
+
+	var effective []*EffectiveDirective
+	for i, content := range contents {
+		for _, e := range content {
+			rule := inheritanceRule(e.Directive.GetName())
+			if w, ok := winner[rule.Group]; ok && w == i && (!rule.NotInherited || i == len(levels)-1) {
+				e.Inherited = i < len(levels)-1
+				effective = append(effective, e)
+			}
+		}
+	}
+	return effective
+}
+
+// levelDirectives returns the directives of a block with those of the included files in place of
+// their include, leaving out the blocks opening a context of their own
+func levelDirectives(level IDirective, block IBlock, include *Include, file *Config) []*EffectiveDirective {
+	if block == nil {
+		return nil
+	}
+	var all []*EffectiveDirective
+	for _, d := range block.GetDirectives() {
+		if inc, ok := d.(*Include); ok {
+			for _, c := range inc.Configs {
+				all = append(all, levelDirectives(level, c.Block, inc, c)...)
+			}
+			continue
+		}
+		if d.GetBlock() != nil && inheritanceRule(d.GetName()).NotInherited {
+			continue
+		}
+		all = append(all, &EffectiveDirective{Directive: d, Block: level, Include: include, File: file})
+	}
+	return all
+}
+
+// Directives returns the directives of an effective set
+func Directives(effective []*EffectiveDirective) []IDirective {
+	directives := make([]IDirective, len(effective))
+	for i, e := range effective {
+		directives[i] = e.Directive
+	}
+	return directives
+}
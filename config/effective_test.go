@@ -0,0 +1,93 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+func TestEffective(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(`http {
+    add_header X-Frame-Options DENY;
+    add_header X-Content-Type-Options nosniff;
+    proxy_set_header Host $host;
+    allow 10.0.0.0/8;
+    deny all;
+    root /srv;
+    server {
+        listen 80;
+        return 301 https://$host$request_uri;
+        location / {
+            proxy_pass http://app;
+        }
+        location /api/ {
+            add_header X-Api 1;
+            deny 10.1.0.0/16;
+            location /api/v1/ {
+                proxy_set_header X-Version 1;
+            }
+        }
+    }
+}`).Parse()
+	assert.NilError(t, err)
+
+	describe := func(effective []*config.EffectiveDirective) []string {
+		var got []string
+		for _, e := range effective {
+			d := e.Directive
+			text := d.GetName() + " " + d.GetParameters()[0].GetValue()
+			if e.Inherited {
+				text += " <" + e.Block.GetName()
+			}
+			got = append(got, text)
+		}
+		return got
+	}
+
+	locations := conf.FindDirectives("location")
+	root := locations[0]
+	assert.DeepEqual(t, describe(config.Effective(root)), []string{
+		"add_header X-Frame-Options <http",
+		"add_header X-Content-Type-Options <http",
+		"proxy_set_header Host <http",
+		"allow 10.0.0.0/8 <http",
+		"deny all <http",
+		"root /srv <http",
+		"proxy_pass http://app",
+	})
+
+	// add_header and deny of /api/ replace those of http, proxy_set_header of v1 those of http
+	v1 := locations[2]
+	assert.DeepEqual(t, describe(config.Effective(v1)), []string{
+		"root /srv <http",
+		"add_header X-Api <location",
+		"deny 10.1.0.0/16 <location",
+		"proxy_set_header X-Version",
+	})
+
+	// return only applies to the server itself
+	server := conf.FindDirectives("server")[0]
+	assert.Equal(t, describe(config.Effective(server))[7], "return 301")
+}
+
+func TestEffective_Includes(t *testing.T) {
+	t.Parallel()
+
+	dir, conf := parseIncludes(t)
+	listen := conf.FindDirectives("listen")[0]
+	server := listen.GetParent()
+	effective := config.Effective(server)
+	assert.Equal(t, len(effective), 3)
+	assert.Equal(t, effective[0].Directive, listen)
+	assert.Assert(t, effective[0].Include == nil)
+	protocols := effective[1]
+	assert.Equal(t, protocols.Directive.GetName(), "ssl_protocols")
+	assert.Equal(t, protocols.Include.IncludePath, "snippets/ssl.conf")
+	assert.Equal(t, protocols.File.FilePath, dir+"/snippets/ssl.conf")
+	assert.Equal(t, protocols.Block, server)
+	assert.Assert(t, !protocols.Inherited)
+}
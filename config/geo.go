@@ -19,6 +19,8 @@ type Geo struct {
 	ProxyRecursive bool     // Whether to use recursive proxy lookup
 	Comment        []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -29,6 +31,8 @@ type GeoEntry struct {
 	Value   string // Value to set for this network
 	Comment []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -383,7 +387,19 @@ func NewGeoEntry(directive IDirective, parent *Geo) (*GeoEntry, error) {
 		Value:                value,
 		Comment:              directive.GetComment(),
 		DefaultInlineComment: DefaultInlineComment{InlineComment: directive.GetInlineComment()},
+		DefaultTrivia:        DefaultTrivia{Trivia: TriviaOf(directive)},
+		DefaultRange:         DefaultRange{Range: RangeOf(directive)},
 	}
 
 	return entry, nil
 }
+
+// Clone returns a deep copy of the geo block, detached from its parent.
+func (g *Geo) Clone() *Geo {
+	return Clone(g).(*Geo)
+}
+
+// Clone returns a deep copy of the geo entry, detached from its parent.
+func (ge *GeoEntry) Clone() *GeoEntry {
+	return Clone(ge).(*GeoEntry)
+}
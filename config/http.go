@@ -10,6 +10,8 @@ type HTTP struct {
 	Directives []IDirective
 	Comment    []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -116,3 +118,8 @@ func (h *HTTP) GetBlock() IBlock {
 func (h *HTTP) GetCodeBlock() string {
 	return ""
 }
+
+// Clone returns a deep copy of the http block, detached from its parent.
+func (h *HTTP) Clone() *HTTP {
+	return Clone(h).(*HTTP)
+}
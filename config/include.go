@@ -77,6 +77,87 @@ func (c *Include) SetComment(comment []string) {
 	c.Comment = comment
 }
 
+// configOf returns the included config holding directive at its top level
+func (c *Include) configOf(directive IDirective) *Config {
+	for _, conf := range c.Configs {
+		if conf.Block == nil {
+			continue
+		}
+		for _, d := range conf.Block.Directives {
+			if d == directive {
+				return conf
+			}
+		}
+	}
+	return nil
+}
+
+// Origin tells which file a directive was parsed from and through which includes it was reached
+type Origin struct {
+	File     *Config    // the config holding the directive
+	Includes []*Include // the include directives leading to File, outermost first
+}
+
+// IncludedBy returns the include directive that pulled the config in, nil for the main file
+func (c *Config) IncludedBy() *Include {
+	if c.Block == nil {
+		return nil
+	}
+	include, _ := c.Parent.(*Include)
+	return include
+}
+
+// OriginOf returns the file and include chain of a directive of the config,
+// following the parent links set by the parser from the directive up.
+func (c *Config) OriginOf(directive IDirective) Origin {
+	origin := Origin{File: c}
+	found := false
+	for current := directive; current != nil; {
+		parent := current.GetParent()
+		if parent == nil || parent == current {
+			break
+		}
+		if include, ok := parent.(*Include); ok {
+			if conf := include.configOf(current); conf != nil {
+				if !found {
+					origin.File, found = conf, true
+				}
+				origin.Includes = append([]*Include{include}, origin.Includes...)
+			}
+		}
+		current = parent
+	}
+	return origin
+}
+
+// FlatDirective is a directive of a flattened config
+type FlatDirective struct {
+	Directive IDirective
+	Context   string       // the context of the directive with the includes inlined
+	Parents   []IDirective // the enclosing block directives, outermost first
+	Origin
+}
+
+// Flatten returns the directives of the config in document order as if the
+// included files were written in place of their include directives. Resolved
+// include directives are left out, each directive knows the file it comes from.
+func (c *Config) Flatten() []FlatDirective {
+	var flat []FlatDirective
+	_ = Walk(c, VisitorFuncs{EnterFunc: func(cur *Cursor) error {
+		if include, ok := cur.Directive().(*Include); ok && len(include.Configs) > 0 {
+			return nil
+		}
+		flat = append(flat, FlatDirective{
+			Directive: cur.Directive(),
+			Context:   cur.Context(),
+			Parents:   cur.Parents(),
+			Origin:    cur.Origin(),
+		})
+		return nil
+	}}, WalkIncludes())
+	return flat
+}
+
 // NewInclude initializes an Include from a directive.
 func NewInclude(dir IDirective) (*Include, error) {
 	directive, ok := dir.(*Directive)
@@ -97,3 +178,8 @@ func NewInclude(dir IDirective) (*Include, error) {
 	}
 	return include, nil
 }
+
+// Clone returns a deep copy of the include directive and the configs it includes, detached from its parent.
+func (c *Include) Clone() *Include {
+	return Clone(c).(*Include)
+}
@@ -0,0 +1,96 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+// parseIncludes parses nginx.conf including sites/*.conf, which includes snippets/ssl.conf
+func parseIncludes(t *testing.T) (string, *config.Config) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"nginx.conf":        "user nginx;\nhttp {\n    include sites/*.conf;\n}\n",
+		"sites/app.conf":    "server {\n    listen 80;\n    include snippets/ssl.conf;\n}\n",
+		"snippets/ssl.conf": "ssl_protocols TLSv1.3;\nuser root;\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NilError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	p, err := parser.NewParser(filepath.Join(dir, "nginx.conf"), parser.WithIncludeParsing())
+	assert.NilError(t, err)
+	conf, err := p.Parse()
+	assert.NilError(t, err)
+	return dir, conf
+}
+
+func TestInclude_Linkage(t *testing.T) {
+	t.Parallel()
+
+	dir, conf := parseIncludes(t)
+	sites := conf.FindDirectives("include")[0].(*config.Include)
+	app := sites.Configs[0]
+	assert.Equal(t, app.Parent, config.IDirective(sites))
+	assert.Equal(t, app.IncludedBy(), sites)
+	assert.Assert(t, conf.IncludedBy() == nil)
+
+	// top level directives of an included file hang below the include
+	server := app.GetDirectives()[0]
+	assert.Equal(t, server.GetParent(), config.IDirective(sites))
+
+	protocols := conf.FindDirectives("ssl_protocols")[0]
+	origin := conf.OriginOf(protocols)
+	assert.Equal(t, origin.File.FilePath, filepath.Join(dir, "snippets/ssl.conf"))
+	assert.Equal(t, len(origin.Includes), 2)
+	assert.Equal(t, origin.Includes[0], sites)
+	assert.Equal(t, origin.Includes[1].IncludePath, "snippets/ssl.conf")
+
+	listen := conf.FindDirectives("listen")[0]
+	assert.Equal(t, conf.OriginOf(listen).File, app)
+	user := conf.FindDirectives("user")[0]
+	assert.Equal(t, conf.OriginOf(user).File, conf)
+	assert.Equal(t, len(conf.OriginOf(user).Includes), 0)
+}
+
+func TestConfig_Flatten(t *testing.T) {
+	t.Parallel()
+
+	dir, conf := parseIncludes(t)
+	var got []string
+	for _, d := range conf.Flatten() {
+		file, _ := filepath.Rel(dir, d.File.FilePath)
+		got = append(got, d.Directive.GetName()+"@"+d.Context+"<"+file)
+	}
+	assert.DeepEqual(t, got, []string{
+		"user@main<nginx.conf",
+		"http@main<nginx.conf",
+		"server@http<sites/app.conf",
+		"listen@server<sites/app.conf",
+		"ssl_protocols@server<snippets/ssl.conf",
+		"user@server<snippets/ssl.conf",
+	})
+	last := conf.Flatten()[5]
+	assert.Equal(t, len(last.Parents), 2)
+	assert.Equal(t, last.Parents[1].GetName(), "server")
+}
+
+func TestContextValidator_IncludedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir, conf := parseIncludes(t)
+	errs := config.NewContextValidator().ValidateConfig(conf)
+	assert.Equal(t, len(errs), 1)
+	assert.Equal(t, errs[0].Error(), filepath.Join(dir, "snippets/ssl.conf")+":2: directive 'user' is not allowed in 'server' context: allowed in: main")
+
+	report := config.NewConfigValidator().ValidateConfig(conf)
+	issues := report.GetByCategory("Context")
+	assert.Equal(t, len(issues), 1)
+	assert.Equal(t, issues[0].File, filepath.Join(dir, "snippets/ssl.conf"))
+}
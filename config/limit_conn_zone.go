@@ -16,6 +16,8 @@ type LimitConnZone struct {
 	Sync     bool   // Whether sync is enabled
 	Comment  []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -219,3 +221,8 @@ func (lcz *LimitConnZone) GetRecommendedLimits() (map[string]int, error) {
 
 	return recommendations, nil
 }
+
+// Clone returns a deep copy of the limit_conn_zone directive, detached from its parent.
+func (lcz *LimitConnZone) Clone() *LimitConnZone {
+	return Clone(lcz).(*LimitConnZone)
+}
@@ -18,6 +18,8 @@ type LimitReqZone struct {
 	Sync     bool   // Whether sync is enabled
 	Comment  []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -270,3 +272,8 @@ func NewLimitReqZone(directive IDirective) (*LimitReqZone, error) {
 
 	return limitReqZone, nil
 }
+
+// Clone returns a deep copy of the limit_req_zone directive, detached from its parent.
+func (lrz *LimitReqZone) Clone() *LimitReqZone {
+	return Clone(lrz).(*LimitReqZone)
+}
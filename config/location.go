@@ -74,3 +74,8 @@ func (l *Location) GetDirectives() []IDirective {
 	}
 	return block.GetDirectives()
 }
+
+// Clone returns a deep copy of the location block, detached from its parent.
+func (l *Location) Clone() *Location {
+	return Clone(l).(*Location)
+}
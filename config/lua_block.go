@@ -10,6 +10,8 @@ type LuaBlock struct {
 	Name       string
 	Comment    []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	LuaCode    string
 	Parent     IDirective
 	Line       int
@@ -112,3 +114,8 @@ func (lb *LuaBlock) GetComment() []string {
 func (lb *LuaBlock) SetComment(comment []string) {
 	lb.Comment = comment
 }
+
+// Clone returns a deep copy of the lua block, detached from its parent.
+func (lb *LuaBlock) Clone() *LuaBlock {
+	return Clone(lb).(*LuaBlock)
+}
@@ -12,6 +12,8 @@ type Map struct {
 	Mappings       []*MapEntry
 	Comment        []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -22,6 +24,8 @@ type MapEntry struct {
 	Value   string // Value to map to
 	Comment []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -227,7 +231,19 @@ func NewMapEntry(directive IDirective) (*MapEntry, error) {
 		Value:                parameters[0].GetValue(),
 		Comment:              directive.GetComment(),
 		DefaultInlineComment: DefaultInlineComment{InlineComment: directive.GetInlineComment()},
+		DefaultTrivia:        DefaultTrivia{Trivia: TriviaOf(directive)},
+		DefaultRange:         DefaultRange{Range: RangeOf(directive)},
 	}
 
 	return entry, nil
 }
+
+// Clone returns a deep copy of the map block, detached from its parent.
+func (m *Map) Clone() *Map {
+	return Clone(m).(*Map)
+}
+
+// Clone returns a deep copy of the map entry, detached from its parent.
+func (me *MapEntry) Clone() *MapEntry {
+	return Clone(me).(*MapEntry)
+}
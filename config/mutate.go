@@ -0,0 +1,248 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InsertBefore, InsertAfter, Remove, ReplaceWith and MoveTo edit any IBlock through
+// GetDirectives and the directive setters of the wrappers. They find directives by
+// identity, write the new directive list back with setDirectives so that the typed
+// fields of the wrappers (HTTP.Servers, Upstream.UpstreamServers, Map.Mappings, ...)
+// stay in sync, and fix the parent and line of the directives they move around.
+//
+// Wrappers that split their directives in typed fields return them grouped from
+// GetDirectives, an upstream server inserted before `keepalive` still ends up
+// after the other directives of the upstream.
+
+// InsertBefore inserts directives before anchor in block
+func InsertBefore(block IBlock, anchor IDirective, directives ...IDirective) error {
+	return insertRelative(block, anchor, 0, directives)
+}
+
+// InsertAfter inserts directives after anchor in block
+func InsertAfter(block IBlock, anchor IDirective, directives ...IDirective) error {
+	return insertRelative(block, anchor, 1, directives)
+}
+
+// insertRelative inserts directives next to anchor, offset 0 inserts before it and 1 after it
+func insertRelative(block IBlock, anchor IDirective, offset int, directives []IDirective) error {
+	inner, _ := resolveBlock(block)
+	index := indexOf(inner.GetDirectives(), anchor)
+	if index < 0 {
+		return notInBlock(anchor)
+	}
+	return insertAt(block, index+offset, anchor.GetLine(), directives)
+}
+
+// insertAt inserts directives at index of block, appending them when index is out of range.
+// Directives without a line get the given line
+func insertAt(block IBlock, index, line int, directives []IDirective) error {
+	inner, owner := resolveBlock(block)
+	for _, d := range directives {
+		if d == nil {
+			return errors.New("cannot insert a nil directive")
+		}
+	}
+	current := inner.GetDirectives()
+	if index < 0 || index > len(current) {
+		index = len(current)
+	}
+	updated := make([]IDirective, 0, len(current)+len(directives))
+	updated = append(updated, current[:index]...)
+	updated = append(updated, directives...)
+	updated = append(updated, current[index:]...)
+	if err := setDirectives(owner, inner, updated); err != nil {
+		return err
+	}
+	for _, d := range directives {
+		adopt(d, owner, line)
+	}
+	return nil
+}
+
+// Remove removes directive from block and detaches it
+func Remove(block IBlock, directive IDirective) error {
+	inner, owner := resolveBlock(block)
+	current := inner.GetDirectives()
+	index := indexOf(current, directive)
+	if index < 0 {
+		return notInBlock(directive)
+	}
+	updated := make([]IDirective, 0, len(current)-1)
+	updated = append(updated, current[:index]...)
+	updated = append(updated, current[index+1:]...)
+	if err := setDirectives(owner, inner, updated); err != nil {
+		return err
+	}
+	directive.SetParent(nil)
+	return nil
+}
+
+// ReplaceWith puts replacement at the position of old in block
+func ReplaceWith(block IBlock, old, replacement IDirective) error {
+	if replacement == nil {
+		return errors.New("cannot replace with a nil directive")
+	}
+	inner, owner := resolveBlock(block)
+	current := inner.GetDirectives()
+	index := indexOf(current, old)
+	if index < 0 {
+		return notInBlock(old)
+	}
+	updated := append([]IDirective(nil), current...)
+	updated[index] = replacement
+	if err := setDirectives(owner, inner, updated); err != nil {
+		return err
+	}
+	if replacement != old {
+		old.SetParent(nil)
+	}
+	adopt(replacement, owner, old.GetLine())
+	return nil
+}
+
+// MoveTo moves directive from block to index of dest. The index is taken
+// after directive was removed, out of range indexes append it
+func MoveTo(block IBlock, directive IDirective, dest IBlock, index int) error {
+	if dest == nil {
+		return errors.New("cannot move to a nil block")
+	}
+	from, _ := resolveBlock(block)
+	to, _ := resolveBlock(dest)
+	current := from.GetDirectives()
+	position := indexOf(current, directive)
+	if position < 0 {
+		return notInBlock(directive)
+	}
+	if containsBlock(directive, to) {
+		return fmt.Errorf("cannot move %s into itself", directive.GetName())
+	}
+
+	if from == to {
+		remaining := make([]IDirective, 0, len(current))
+		remaining = append(remaining, current[:position]...)
+		remaining = append(remaining, current[position+1:]...)
+		if index < 0 || index > len(remaining) {
+			index = len(remaining)
+		}
+		updated := make([]IDirective, 0, len(current))
+		updated = append(updated, remaining[:index]...)
+		updated = append(updated, directive)
+		updated = append(updated, remaining[index:]...)
+		_, owner := resolveBlock(block)
+		return setDirectives(owner, from, updated)
+	}
+
+	// insert first, the destination may refuse the directive
+	if err := insertAt(dest, index, directive.GetLine(), []IDirective{directive}); err != nil {
+		return err
+	}
+	_, owner := resolveBlock(block)
+	updated := make([]IDirective, 0, len(current)-1)
+	updated = append(updated, current[:position]...)
+	updated = append(updated, current[position+1:]...)
+	return setDirectives(owner, from, updated)
+}
+
+// resolveBlock returns the block holding the directives of b and the directive owning it.
+// Wrappers embedding a *Block are resolved to it, the owner is nil for a Config
+func resolveBlock(b IBlock) (IBlock, IDirective) {
+	inner := b
+	switch t := b.(type) {
+	case *Config:
+		inner = t.Block
+	case *Stream:
+		inner = t.Block
+	case *StreamServer:
+		inner = t.Block
+	case *StreamUpstream:
+		inner = t.Block
+	}
+	if d, ok := b.(IDirective); ok && d.GetBlock() == inner {
+		return inner, d
+	}
+	if block, ok := inner.(*Block); ok {
+		if block.Parent != nil {
+			return inner, block.Parent
+		}
+		// blocks built by hand do not know their directive, their children may
+		for _, d := range block.Directives {
+			if parent := d.GetParent(); parent != nil && parent.GetBlock() == inner {
+				return inner, parent
+			}
+		}
+	}
+	return inner, nil
+}
+
+// indexOf returns the position of directive in directives, -1 if it is not there
+func indexOf(directives []IDirective, directive IDirective) int {
+	for i, d := range directives {
+		if d == directive {
+			return i
+		}
+	}
+	// geo settings are rebuilt by every call to Geo.GetDirectives
+	if entry, ok := directive.(*GeoEntry); ok {
+		for i, d := range directives {
+			if other, ok := d.(*GeoEntry); ok && other.Network == entry.Network && other.Value == entry.Value {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// adopt makes owner the parent of directive, directives without a line get line
+func adopt(directive IDirective, owner IDirective, line int) {
+	directive.SetParent(owner)
+	if directive.GetLine() == 0 {
+		directive.SetLine(line)
+	}
+	if block, ok := directive.GetBlock().(*Block); ok {
+		block.SetParent(directive)
+	}
+}
+
+// containsBlock returns true if block belongs to directive or one of its descendants
+func containsBlock(directive IDirective, block IBlock) bool {
+	found := func(b IBlock) bool {
+		if b == nil {
+			return false
+		}
+		inner, _ := resolveBlock(b)
+		return inner == block
+	}
+	if found(directive.GetBlock()) {
+		return true
+	}
+	if directive.GetBlock() == nil {
+		return false
+	}
+	contains := false
+	_ = Walk(directive.GetBlock(), VisitorFuncs{EnterFunc: func(c *Cursor) error {
+		if found(c.Directive().GetBlock()) {
+			contains = true
+			return SkipAll
+		}
+		if include, ok := c.Directive().(*Include); ok {
+			for _, conf := range include.Configs {
+				if found(conf) {
+					contains = true
+					return SkipAll
+				}
+			}
+		}
+		return nil
+	}}, WalkIncludes())
+	return contains
+}
+
+// notInBlock is the error returned when a directive is not found in a block
+func notInBlock(directive IDirective) error {
+	if directive == nil {
+		return errors.New("directive is nil")
+	}
+	return fmt.Errorf("directive %s is not in the block", directive.GetName())
+}
@@ -0,0 +1,155 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+const mutateConfig = `http {
+    upstream backend {
+        server 127.0.0.1:8080;
+        keepalive 8;
+    }
+    server {
+        listen 80;
+        location / {
+            root /var/www;
+        }
+    }
+    server {
+        listen 81;
+    }
+}
+`
+
+func directive(name string, values ...string) *config.Directive {
+	d := &config.Directive{Name: name}
+	for _, value := range values {
+		d.Parameters = append(d.Parameters, config.Parameter{Value: value})
+	}
+	return d
+}
+
+func TestBlock_InsertAndRemove(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(mutateConfig).Parse()
+	assert.NilError(t, err)
+	server := conf.FindDirectives("server")[0]
+	listen := server.GetBlock().FindDirectives("listen")[0]
+
+	before, after := directive("server_name", "example.com"), directive("gzip", "on")
+	assert.NilError(t, config.InsertBefore(server.GetBlock(), listen, before))
+	assert.NilError(t, config.InsertAfter(server.GetBlock(), listen, after))
+	assert.Equal(t, before.GetParent(), server)
+	assert.Equal(t, after.GetLine(), listen.GetLine())
+
+	location := conf.FindDirectives("location")[0]
+	assert.NilError(t, config.Remove(server.GetBlock(), location))
+	assert.Assert(t, location.GetParent() == nil)
+	assert.ErrorContains(t, config.Remove(server.GetBlock(), location), "directive location is not in the block")
+
+	assert.Equal(t, dumper.DumpDirective(server, dumper.IndentedStyle), `server {
+    server_name example.com;
+    listen 80;
+    gzip on;
+}`)
+}
+
+func TestBlock_WrapperState(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(mutateConfig).Parse()
+	assert.NilError(t, err)
+	upstream := conf.FindUpstreams()[0]
+	keepalive := upstream.FindDirectives("keepalive")[0]
+
+	server, err := config.NewUpstreamServer(directive("server", "127.0.0.1:8081"))
+	assert.NilError(t, err)
+	assert.NilError(t, config.InsertBefore(upstream, keepalive, server))
+	assert.Equal(t, len(upstream.UpstreamServers), 2)
+	assert.Equal(t, len(upstream.Directives), 1)
+	assert.Equal(t, server.GetParent(), config.IDirective(upstream))
+
+	assert.Equal(t, upstream.UpstreamServers[0], server)
+
+	assert.NilError(t, config.Remove(upstream, upstream.UpstreamServers[1]))
+	assert.Equal(t, len(upstream.UpstreamServers), 1)
+	assert.Equal(t, upstream.UpstreamServers[0].Address, "127.0.0.1:8081")
+
+	http := conf.FindDirectives("http")[0].(*config.HTTP)
+	first := http.Servers[0]
+	replacement, err := config.NewServer(&config.Directive{Name: "server", Block: &config.Block{}})
+	assert.NilError(t, err)
+	assert.NilError(t, config.ReplaceWith(http, first, replacement))
+	assert.Equal(t, len(http.Servers), 2)
+	assert.Equal(t, http.Servers[0], replacement)
+	assert.Equal(t, replacement.GetLine(), first.GetLine())
+	assert.Equal(t, replacement.GetParent(), config.IDirective(http))
+
+	// entry blocks refuse anything else and are left untouched
+	conf, err = parser.NewStringParser("http {\n    map $host $name {\n        default a;\n    }\n}\n").Parse()
+	assert.NilError(t, err)
+	m := conf.FindMaps()[0]
+	assert.ErrorContains(t, config.InsertAfter(m, m.Mappings[0], directive("gzip", "on")), "map block can only hold map entries")
+	assert.Equal(t, len(m.Mappings), 1)
+}
+
+func TestBlock_MoveTo(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(mutateConfig).Parse()
+	assert.NilError(t, err)
+	servers := conf.FindDirectives("server")
+	first, second := servers[0], servers[1]
+	location := conf.FindDirectives("location")[0]
+
+	assert.NilError(t, config.MoveTo(first.GetBlock(), location, second.GetBlock(), 0))
+	assert.Equal(t, location.GetParent(), second)
+	assert.Equal(t, len(first.GetBlock().GetDirectives()), 1)
+	assert.Equal(t, second.GetBlock().GetDirectives()[0], location)
+
+	// moving inside a block
+	listen := second.GetBlock().FindDirectives("listen")[0]
+	assert.NilError(t, config.MoveTo(second.GetBlock(), listen, second.GetBlock(), 0))
+	assert.Equal(t, second.GetBlock().GetDirectives()[0], listen)
+
+	// a directive cannot be moved below itself
+	http := conf.FindDirectives("http")[0]
+	assert.ErrorContains(t, config.MoveTo(http.GetBlock(), second, location.GetBlock(), -1), "cannot move server into itself")
+
+	// moving to the top level of the file
+	assert.NilError(t, config.MoveTo(second.GetBlock(), location, conf, -1))
+	assert.Equal(t, conf.GetDirectives()[1], location)
+	assert.Assert(t, location.GetParent() == nil)
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(mutateConfig).Parse()
+	assert.NilError(t, err)
+	http := conf.FindDirectives("http")[0].(*config.HTTP)
+	server := http.Servers[0]
+
+	clone := config.Clone(server)
+	assert.Assert(t, clone.GetParent() == nil)
+	assert.Equal(t, dumper.DumpDirective(clone, dumper.IndentedStyle), dumper.DumpDirective(server, dumper.IndentedStyle))
+
+	// the clone does not share anything with the original
+	location := clone.GetBlock().FindDirectives("location")[0]
+	assert.Equal(t, location.GetParent(), clone)
+	location.GetBlock().FindDirectives("root")[0].(*config.Directive).Parameters[0].Value = "/srv"
+	assert.Equal(t, server.FindDirectives("root")[0].GetParameters()[0].GetValue(), "/var/www")
+
+	assert.NilError(t, config.InsertAfter(http, server, clone))
+	assert.Equal(t, len(http.Servers), 3)
+	assert.Equal(t, clone.GetParent(), config.IDirective(http))
+
+	upstream := config.Clone(conf.FindUpstreams()[0]).(*config.Upstream)
+	assert.Equal(t, upstream.UpstreamServers[0].GetParent(), config.IDirective(upstream))
+}
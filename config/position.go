@@ -0,0 +1,87 @@
+package config
+
+import "fmt"
+
+// Position represents a point in a config file
+type Position struct {
+	File   string // empty if the config was parsed from a string
+	Line   int    // 1-based line number
+	Column int    // 1-based column, counted in characters
+	Offset int    // 0-based byte offset
+}
+
+// String returns the position as file:line:column
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// IsValid returns true if the position was set by the parser
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// Range represents a span of source text, End points right after the last character
+type Range struct {
+	Start Position
+	End   Position
+}
+
+// String returns the range as file:line:column-line:column
+func (r Range) String() string {
+	return fmt.Sprintf("%s-%d:%d", r.Start, r.End.Line, r.End.Column)
+}
+
+// IsValid returns true if the range was set by the parser
+func (r Range) IsValid() bool {
+	return r.Start.IsValid()
+}
+
+// Contains returns true if the position is inside the range
+func (r Range) Contains(p Position) bool {
+	return r.Start.Offset <= p.Offset && p.Offset < r.End.Offset
+}
+
+// DirectiveRange holds the source ranges of a directive and its parts
+type DirectiveRange struct {
+	Range            // the directive from its name up to the terminating ';' or '}'
+	Name       Range // the directive name
+	BlockStart Range // the opening '{', invalid if the directive has no block
+	BlockEnd   Range // the closing '}', invalid if the directive has no block
+}
+
+// File returns the name of the file the directive was parsed from
+func (dr *DirectiveRange) File() string {
+	return dr.Start.File
+}
+
+// RangeHolder represents a directive that knows where it was parsed from
+type RangeHolder interface {
+	GetRange() *DirectiveRange
+	SetRange(r *DirectiveRange)
+}
+
+// DefaultRange represents the default range holder
+type DefaultRange struct {
+	Range *DirectiveRange
+}
+
+// GetRange returns the source range, nil if the directive was not created by the parser
+func (d *DefaultRange) GetRange() *DirectiveRange {
+	return d.Range
+}
+
+// SetRange sets the source range
+func (d *DefaultRange) SetRange(r *DirectiveRange) {
+	d.Range = r
+}
+
+// RangeOf returns the source range of a directive, nil if it is unknown
+func RangeOf(d IDirective) *DirectiveRange {
+	if holder, ok := d.(RangeHolder); ok {
+		return holder.GetRange()
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the products a profile can describe
+const (
+	ProductNginx     = "nginx"
+	ProductPlus      = "nginx-plus"
+	ProductOpenResty = "openresty"
+	ProductTengine   = "tengine"
+)
+
+// Module is an nginx module whose directives are only there when the module is built in.
+// Directives of no module are those of the modules nginx builds by default.
+type Module struct {
+	// Directives are the directives of the module, a pattern like "ssl_*" matches
+	// all the directives starting with ssl_, the most specific pattern wins
+	Directives []string
+	Commercial bool // the module only exists in NGINX Plus
+}
+
+// Lifecycle tells in which nginx versions a directive or a parameter exists
+type Lifecycle struct {
+	Since      string // the version introducing it, empty when it has always been there
+	Deprecated string // the version deprecating it, it still works
+	Removed    string // the version removing it, or ignoring it
+	Note       string // what to use instead
+}
+
+// Profile is the nginx build a configuration targets: a product, the nginx version it is
+// based on and the modules built in. The parser and the validators use it to flag the
+// directives and parameters the build does not have, see LookupProfile.
+type Profile struct {
+	Name    string   // like "nginx-1.24", "nginx-plus-r30" or "openresty-1.21"
+	Product string   // ProductNginx, ProductPlus, ProductOpenResty or ProductTengine
+	Version string   // the nginx version the build is based on, like "1.24.0"
+	Modules []string // the modules built in besides the default nginx ones, see Modules
+}
+
+// ProfileIssue is a directive or a parameter a profile does not have, or has deprecated
+type ProfileIssue struct {
+	Directive string
+	Parameter string // empty when the issue is about the directive itself
+	Level     ValidationLevel
+	Message   string
+}
+
+// LookupProfile returns the profile of a named build with extra modules. Names are a product
+// and a version: "nginx-1.24", "nginx-1.24.0", "nginx-plus-r30", "openresty-1.21" or "tengine-3.0".
+// The modules each product ships with are included, see ProductModules.
+func LookupProfile(name string, modules ...string) (*Profile, error) {
+	p := &Profile{Name: name}
+	switch {
+	case strings.HasPrefix(name, ProductPlus+"-"):
+		release := strings.TrimPrefix(name, ProductPlus+"-")
+		version, ok := PlusReleases[release]
+		if !ok {
+			return nil, fmt.Errorf("unknown NGINX Plus release %q", release)
+		}
+		p.Product, p.Version = ProductPlus, version
+	case strings.HasPrefix(name, ProductOpenResty+"-"):
+		version := strings.TrimPrefix(name, ProductOpenResty+"-")
+		if parts := strings.Split(version, "."); len(parts) > 3 {
+			// 1.21.4.3 is the third OpenResty release of nginx 1.21.4
+			version = strings.Join(parts[:3], ".")
+		} else if base, ok := OpenRestyReleases[version]; ok {
+			version = base
+		}
+		p.Product, p.Version = ProductOpenResty, version
+	case strings.HasPrefix(name, ProductTengine+"-"):
+		release := strings.TrimPrefix(name, ProductTengine+"-")
+		version, ok := TengineReleases[release]
+		if !ok {
+			return nil, fmt.Errorf("unknown Tengine release %q", release)
+		}
+		p.Product, p.Version = ProductTengine, version
+	case strings.HasPrefix(name, ProductNginx+"-"):
+		p.Product, p.Version = ProductNginx, strings.TrimPrefix(name, ProductNginx+"-")
+	default:
+		return nil, fmt.Errorf("unknown profile %q, want nginx-<version>, nginx-plus-r<release>, openresty-<version> or tengine-<version>", name)
+	}
+	if _, err := parseVersion(p.Version); err != nil {
+		return nil, fmt.Errorf("profile %q: %v", name, err)
+	}
+
+	p.Modules = append(p.Modules, ProductModules[p.Product]...)
+	if p.Product == ProductPlus {
+		for moduleName, module := range Modules {
+			if module.Commercial {
+				p.Modules = append(p.Modules, moduleName)
+			}
+		}
+	}
+	for _, moduleName := range modules {
+		module, ok := Modules[moduleName]
+		if !ok {
+			return nil, fmt.Errorf("unknown module %q", moduleName)
+		}
+		if module.Commercial && p.Product != ProductPlus {
+			return nil, fmt.Errorf("module %q is only available in NGINX Plus", moduleName)
+		}
+		p.Modules = append(p.Modules, moduleName)
+	}
+	sort.Strings(p.Modules)
+	return p, nil
+}
+
+// HasModule tells whether the module is built in
+func (p *Profile) HasModule(name string) bool {
+	for _, module := range p.Modules {
+		if module == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Declares tells whether a module built in provides the directive, the parser accepts those
+// directives even when they are not known otherwise, like the directives of Tengine
+func (p *Profile) Declares(name string) bool {
+	module, ok := directiveModule(name)
+	return ok && p.HasModule(module)
+}
+
+// CheckDirective returns the issues of a directive with its parameters in the profile: directives
+// of modules that are not built in, directives or parameters that are too recent or removed, and
+// the deprecated ones
+func (p *Profile) CheckDirective(name string, params []string) []ProfileIssue {
+	var issues []ProfileIssue
+	if moduleName, ok := directiveModule(name); ok && !p.HasModule(moduleName) {
+		message := fmt.Sprintf("%s requires the %s module, %s is not built with it", name, moduleName, p.Name)
+		if Modules[moduleName].Commercial {
+			message = fmt.Sprintf("%s is only available in NGINX Plus (%s module)", name, moduleName)
+		}
+		return append(issues, ProfileIssue{Directive: name, Level: ValidationError, Message: message})
+	}
+	if issue, ok := p.checkLifecycle(name, DirectiveLifecycles[name]); ok {
+		issue.Directive = name
+		issues = append(issues, issue)
+	}
+	for _, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		lifecycle, ok := ParameterLifecycles[name][key]
+		if !ok {
+			continue
+		}
+		if issue, ok := p.checkLifecycle(fmt.Sprintf("parameter %s of %s", key, name), lifecycle); ok {
+			issue.Directive, issue.Parameter = name, key
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// checkLifecycle compares the version of the profile with the lifecycle of what
+func (p *Profile) checkLifecycle(what string, lifecycle Lifecycle) (ProfileIssue, bool) {
+	note := ""
+	if lifecycle.Note != "" {
+		note = ", " + lifecycle.Note
+	}
+	switch {
+	case lifecycle.Since != "" && compareVersions(p.Version, lifecycle.Since) < 0:
+		return ProfileIssue{Level: ValidationError,
+			Message: fmt.Sprintf("%s was introduced in nginx %s, %s is based on %s", what, lifecycle.Since, p.Name, p.Version)}, true
+	case lifecycle.Removed != "" && compareVersions(p.Version, lifecycle.Removed) >= 0:
+		return ProfileIssue{Level: ValidationError,
+			Message: fmt.Sprintf("%s was removed in nginx %s%s", what, lifecycle.Removed, note)}, true
+	case lifecycle.Deprecated != "" && compareVersions(p.Version, lifecycle.Deprecated) >= 0:
+		return ProfileIssue{Level: ValidationWarning,
+			Message: fmt.Sprintf("%s is deprecated since nginx %s%s", what, lifecycle.Deprecated, note)}, true
+	}
+	return ProfileIssue{}, false
+}
+
+// directiveModule returns the module providing a directive, exact names win over patterns
+// and longer patterns over shorter ones
+func directiveModule(name string) (string, bool) {
+	found, foundPattern := "", ""
+	for moduleName, module := range Modules {
+		for _, pattern := range module.Directives {
+			if pattern == name {
+				return moduleName, true
+			}
+			if matched, _ := path.Match(pattern, name); matched && len(pattern) > len(foundPattern) {
+				found, foundPattern = moduleName, pattern
+			}
+		}
+	}
+	return found, found != ""
+}
+
+// parseVersion splits a version like 1.25.3 into its numbers
+func parseVersion(version string) ([]int, error) {
+	var numbers []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+// compareVersions returns -1, 0 or 1 as a is older, the same as or newer than b,
+// missing numbers count as zero
+func compareVersions(a, b string) int {
+	va, _ := parseVersion(a)
+	vb, _ := parseVersion(b)
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
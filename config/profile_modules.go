@@ -0,0 +1,176 @@
+package config
+
+// Modules are the modules nginx does not build by default, by name like "http_ssl" for the
+// --with-http_ssl_module configure option. Modules of third party builds can be added to it,
+// a profile then lists them to make their directives available.
+var Modules = map[string]Module{
+	// nginx modules enabled with --with-<name>_module
+	"http_ssl":           {Directives: []string{"ssl", "ssl_*"}},
+	"http_v2":            {Directives: []string{"http2", "http2_*"}},
+	"http_v3":            {Directives: []string{"http3", "http3_*", "quic_*"}},
+	"http_realip":        {Directives: []string{"set_real_ip_from", "real_ip_header", "real_ip_recursive"}},
+	"http_addition":      {Directives: []string{"add_before_body", "add_after_body", "addition_types"}},
+	"http_sub":           {Directives: []string{"sub_filter", "sub_filter_*"}},
+	"http_dav":           {Directives: []string{"dav_methods", "dav_access", "create_full_put_path", "min_delete_depth"}},
+	"http_flv":           {Directives: []string{"flv"}},
+	"http_mp4":           {Directives: []string{"mp4", "mp4_*"}},
+	"http_gunzip":        {Directives: []string{"gunzip", "gunzip_buffers"}},
+	"http_gzip_static":   {Directives: []string{"gzip_static"}},
+	"http_auth_request":  {Directives: []string{"auth_request", "auth_request_set"}},
+	"http_random_index":  {Directives: []string{"random_index"}},
+	"http_secure_link":   {Directives: []string{"secure_link", "secure_link_*"}},
+	"http_slice":         {Directives: []string{"slice"}},
+	"http_stub_status":   {Directives: []string{"stub_status"}},
+	"http_xslt":          {Directives: []string{"xslt_*", "xml_entities"}},
+	"http_image_filter":  {Directives: []string{"image_filter", "image_filter_*"}},
+	"http_geoip":         {Directives: []string{"geoip_*"}},
+	"http_perl":          {Directives: []string{"perl", "perl_*"}},
+	"stream":             {Directives: []string{"stream"}},
+	"stream_ssl_preread": {Directives: []string{"ssl_preread"}},
+	"mail":               {Directives: []string{"mail", "imap_*", "pop3_*", "smtp_*", "starttls", "auth_http", "auth_http_*"}},
+	"google_perftools":   {Directives: []string{"google_perftools_profiles"}},
+	"http_degradation":   {Directives: []string{"degradation", "degrade"}},
+
+	// NGINX Plus modules
+	"http_internal_redirect": {Directives: []string{"internal_redirect"}, Commercial: true},
+	"http_auth_jwt":          {Directives: []string{"auth_jwt", "auth_jwt_*"}, Commercial: true},
+	"http_api":               {Directives: []string{"api"}, Commercial: true},
+	"http_keyval":            {Directives: []string{"keyval", "keyval_zone"}, Commercial: true},
+	"stream_zone_sync":       {Directives: []string{"zone_sync", "zone_sync_*"}, Commercial: true},
+	"http_upstream_hc":       {Directives: []string{"health_check", "health_check_timeout", "match"}, Commercial: true},
+	"http_session_log":       {Directives: []string{"session_log", "session_log_*"}, Commercial: true},
+	"http_status":            {Directives: []string{"status", "status_format", "status_zone"}, Commercial: true},
+	"http_f4f":               {Directives: []string{"f4f", "f4f_buffer_size"}, Commercial: true},
+	"http_hls":               {Directives: []string{"hls", "hls_*"}, Commercial: true},
+	"upstream_plus":          {Directives: []string{"sticky", "sticky_cookie_insert", "ntlm", "least_time", "queue", "state"}, Commercial: true},
+	"stream_mqtt":            {Directives: []string{"mqtt", "mqtt_*"}, Commercial: true},
+	"mgmt":                   {Directives: []string{"mgmt", "usage_report", "license_token"}, Commercial: true},
+
+	// OpenResty modules
+	"http_lua":     {Directives: []string{"lua_*", "*_by_lua", "*_by_lua_block", "*_by_lua_file"}},
+	"headers_more": {Directives: []string{"more_set_headers", "more_clear_headers", "more_set_input_headers", "more_clear_input_headers"}},
+	"echo":         {Directives: []string{"echo", "echo_*"}},
+	"set_misc":     {Directives: []string{"set_unescape_uri", "set_escape_uri", "set_quote_sql_str", "set_md5", "set_sha1", "set_encode_base64", "set_decode_base64", "set_if_empty", "set_secure_random_alphanum"}},
+
+	// Tengine modules
+	"upstream_check": {Directives: []string{"check", "check_http_send", "check_http_expect_alive", "check_keepalive_requests", "check_shm_size", "check_status"}},
+	"http_concat":    {Directives: []string{"concat", "concat_*"}},
+	"http_trim":      {Directives: []string{"trim", "trim_*"}},
+	"http_sysguard":  {Directives: []string{"sysguard", "sysguard_*"}},
+	"session_sticky": {Directives: []string{"session_sticky", "session_sticky_hide_cookie"}},
+}
+
+// ProductModules are the modules the official builds of each product come with,
+// the NGINX Plus builds also have all the commercial modules
+var ProductModules = map[string][]string{
+	ProductNginx: {},
+	ProductPlus: {
+		"http_ssl", "http_v2", "http_v3", "http_realip", "http_addition", "http_sub", "http_dav",
+		"http_flv", "http_mp4", "http_gunzip", "http_gzip_static", "http_auth_request",
+		"http_random_index", "http_secure_link", "http_slice", "http_stub_status", "stream",
+		"stream_ssl_preread", "mail",
+	},
+	ProductOpenResty: {
+		"http_ssl", "http_v2", "http_realip", "http_sub", "http_gzip_static", "http_stub_status",
+		"stream", "stream_ssl_preread", "http_lua", "headers_more", "echo", "set_misc",
+	},
+	ProductTengine: {
+		"http_ssl", "http_v2", "http_realip", "http_stub_status", "stream", "upstream_check",
+		"http_concat", "http_trim", "http_sysguard", "session_sticky",
+	},
+}
+
+// PlusReleases are the nginx versions the NGINX Plus releases are based on
+var PlusReleases = map[string]string{
+	"r25": "1.21.3",
+	"r26": "1.21.5",
+	"r27": "1.21.6",
+	"r28": "1.23.2",
+	"r29": "1.23.4",
+	"r30": "1.25.3",
+	"r31": "1.25.5",
+	"r32": "1.27.2",
+	"r33": "1.27.4",
+}
+
+// OpenRestyReleases are the nginx versions of the OpenResty release series
+var OpenRestyReleases = map[string]string{
+	"1.19": "1.19.9",
+	"1.21": "1.21.4",
+	"1.25": "1.25.3",
+	"1.27": "1.27.1",
+}
+
+// TengineReleases are the nginx versions the Tengine releases are based on
+var TengineReleases = map[string]string{
+	"2.3": "1.18.0",
+	"3.0": "1.24.0",
+	"3.1": "1.24.0",
+}
+
+// DirectiveLifecycles are the directives added to or dropped from nginx after 1.10
+var DirectiveLifecycles = map[string]Lifecycle{
+	"ssl":                             {Deprecated: "1.15.0", Removed: "1.25.1", Note: "use the ssl parameter of listen"},
+	"http2":                           {Since: "1.25.1"},
+	"http2_push":                      {Deprecated: "1.25.1", Note: "it is ignored"},
+	"http2_push_preload":              {Deprecated: "1.25.1", Note: "it is ignored"},
+	"http2_idle_timeout":              {Deprecated: "1.19.7", Note: "use keepalive_timeout"},
+	"http2_recv_timeout":              {Deprecated: "1.19.7", Note: "use client_header_timeout"},
+	"http2_max_requests":              {Deprecated: "1.19.7", Note: "use keepalive_requests"},
+	"http2_max_field_size":            {Deprecated: "1.19.7", Note: "use large_client_header_buffers"},
+	"http2_max_header_size":           {Deprecated: "1.19.7", Note: "use large_client_header_buffers"},
+	"http3":                           {Since: "1.25.0"},
+	"http3_hq":                        {Since: "1.25.0"},
+	"http3_max_concurrent_streams":    {Since: "1.25.0"},
+	"http3_stream_buffer_size":        {Since: "1.25.0"},
+	"quic_active_connection_id_limit": {Since: "1.25.0"},
+	"quic_bpf":                        {Since: "1.25.0"},
+	"quic_gso":                        {Since: "1.25.0"},
+	"quic_host_key":                   {Since: "1.25.0"},
+	"quic_retry":                      {Since: "1.25.0"},
+	"absolute_redirect":               {Since: "1.11.8"},
+	"worker_shutdown_timeout":         {Since: "1.11.11"},
+	"proxy_cache_background_update":   {Since: "1.11.10"},
+	"mirror":                          {Since: "1.13.4"},
+	"grpc_pass":                       {Since: "1.13.10"},
+	"subrequest_output_buffer_size":   {Since: "1.13.10"},
+	"random":                          {Since: "1.15.1"},
+	"ssl_early_data":                  {Since: "1.15.3"},
+	"proxy_socket_keepalive":          {Since: "1.15.6"},
+	"limit_req_dry_run":               {Since: "1.17.1"},
+	"limit_conn_dry_run":              {Since: "1.17.6"},
+	"auth_delay":                      {Since: "1.17.10"},
+	"ssl_ocsp":                        {Since: "1.19.0"},
+	"ssl_ocsp_cache":                  {Since: "1.19.0"},
+	"ssl_ocsp_responder":              {Since: "1.19.0"},
+	"ssl_conf_command":                {Since: "1.19.4"},
+	"ssl_reject_handshake":            {Since: "1.19.4"},
+	"proxy_ssl_conf_command":          {Since: "1.19.4"},
+	"grpc_ssl_conf_command":           {Since: "1.19.4"},
+	"uwsgi_ssl_conf_command":          {Since: "1.19.4"},
+	"keepalive_time":                  {Since: "1.19.10"},
+	"mp4_start_key_frame":             {Since: "1.21.4"},
+	"ssl_certificate_cache":           {Since: "1.27.4"},
+	"ssl_object_cache_inheritable":    {Since: "1.27.4"},
+}
+
+// ParameterLifecycles are the parameters added to or dropped from directives after 1.10,
+// by directive and parameter name, the name of a name=value parameter is the part before =
+var ParameterLifecycles = map[string]map[string]Lifecycle{
+	"listen": {
+		"http2": {Deprecated: "1.25.1", Note: "use the http2 directive"},
+		"quic":  {Since: "1.25.0"},
+		"spdy":  {Removed: "1.9.5", Note: "use http2"},
+	},
+	"server": {
+		"max_conns": {Since: "1.11.5"},
+	},
+	"proxy_cache_path": {
+		"manager_files":     {Since: "1.11.5"},
+		"manager_threshold": {Since: "1.11.5"},
+		"manager_sleep":     {Since: "1.11.5"},
+	},
+	"ssl_protocols": {
+		"TLSv1.3": {Since: "1.13.0"},
+	},
+}
@@ -0,0 +1,125 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+func TestLookupProfile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, product, version string
+	}{
+		{"nginx-1.24", config.ProductNginx, "1.24"},
+		{"nginx-1.25.3", config.ProductNginx, "1.25.3"},
+		{"nginx-plus-r30", config.ProductPlus, "1.25.3"},
+		{"openresty-1.21", config.ProductOpenResty, "1.21.4"},
+		{"openresty-1.25.3.1", config.ProductOpenResty, "1.25.3"},
+		{"tengine-3.0", config.ProductTengine, "1.24.0"},
+	}
+	for _, tt := range tests {
+		p, err := config.LookupProfile(tt.name)
+		assert.NilError(t, err, tt.name)
+		assert.Equal(t, p.Product, tt.product, tt.name)
+		assert.Equal(t, p.Version, tt.version, tt.name)
+	}
+
+	p, err := config.LookupProfile("nginx-1.24", "http_ssl")
+	assert.NilError(t, err)
+	assert.Assert(t, p.HasModule("http_ssl"))
+	assert.Assert(t, !p.HasModule("http_v2"))
+
+	for _, name := range []string{"apache-2.4", "nginx-plus-r1", "nginx-latest"} {
+		_, err = config.LookupProfile(name)
+		assert.Assert(t, err != nil, name)
+	}
+	_, err = config.LookupProfile("nginx-1.24", "http_auth_jwt")
+	assert.ErrorContains(t, err, "only available in NGINX Plus")
+	_, err = config.LookupProfile("nginx-1.24", "no_such_module")
+	assert.ErrorContains(t, err, "unknown module")
+}
+
+func TestProfile_CheckDirective(t *testing.T) {
+	t.Parallel()
+
+	oss, err := config.LookupProfile("nginx-1.24", "http_ssl", "http_v2")
+	assert.NilError(t, err)
+	plus, err := config.LookupProfile("nginx-plus-r30")
+	assert.NilError(t, err)
+
+	issues := oss.CheckDirective("auth_jwt", []string{"api"})
+	assert.Equal(t, len(issues), 1)
+	assert.Equal(t, issues[0].Level, config.ValidationError)
+	assert.Assert(t, len(plus.CheckDirective("auth_jwt", []string{"api"})) == 0)
+
+	// http2 on; came with 1.25.1, the http2 parameter of listen went away with it
+	issues = oss.CheckDirective("http2", []string{"on"})
+	assert.Equal(t, len(issues), 1)
+	assert.Equal(t, issues[0].Message, "http2 was introduced in nginx 1.25.1, nginx-1.24 is based on 1.24")
+	assert.Assert(t, len(oss.CheckDirective("listen", []string{"443", "ssl", "http2"})) == 0)
+	issues = plus.CheckDirective("listen", []string{"443", "ssl", "http2"})
+	assert.Equal(t, len(issues), 1)
+	assert.Equal(t, issues[0].Parameter, "http2")
+	assert.Equal(t, issues[0].Level, config.ValidationWarning)
+
+	// directives of modules the build lacks
+	issues = oss.CheckDirective("content_by_lua_block", nil)
+	assert.Equal(t, len(issues), 1)
+	assert.Equal(t, issues[0].Message, "content_by_lua_block requires the http_lua module, nginx-1.24 is not built with it")
+	assert.Assert(t, len(oss.CheckDirective("ssl_preread", []string{"on"})) == 1)
+	assert.Assert(t, len(oss.CheckDirective("proxy_pass", []string{"http://a"})) == 0)
+}
+
+func TestProfile_Parser(t *testing.T) {
+	t.Parallel()
+
+	source := `http {
+    upstream app {
+        server 127.0.0.1:8080;
+        check interval=3000 rise=2 fall=5;
+    }
+}`
+	_, err := parser.NewStringParser(source).Parse()
+	assert.ErrorContains(t, err, "unknown directive 'check'")
+
+	tengine, err := config.LookupProfile("tengine-3.0")
+	assert.NilError(t, err)
+	_, err = parser.NewStringParser(source, parser.WithProfile(tengine)).Parse()
+	assert.NilError(t, err)
+
+	oss, err := config.LookupProfile("nginx-1.24")
+	assert.NilError(t, err)
+	_, err = parser.NewStringParser("http {\n    auth_jwt api;\n}", parser.WithProfile(oss)).Parse()
+	assert.ErrorContains(t, err, "auth_jwt is only available in NGINX Plus")
+}
+
+func TestConfigValidator_Profile(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(`http {
+    server {
+        listen 443 ssl http2 quic;
+        ssl_certificate cert.pem;
+        ssl_certificate_key cert.key;
+        auth_jwt api;
+    }
+}`).Parse()
+	assert.NilError(t, err)
+
+	profile, err := config.LookupProfile("nginx-1.24", "http_ssl", "http_v2")
+	assert.NilError(t, err)
+	report := config.NewConfigValidatorForProfile(profile).ValidateConfig(conf)
+	var got []string
+	for _, issue := range report.GetByCategory("Profile") {
+		got = append(got, issue.Level.String()+" "+issue.Title+": "+issue.Description)
+	}
+	assert.DeepEqual(t, got, []string{
+		"ERROR Parameter not available: parameter quic of listen was introduced in nginx 1.25.0, nginx-1.24 is based on 1.24",
+		"ERROR Directive not available: auth_jwt is only available in NGINX Plus (http_auth_jwt module)",
+	})
+	assert.Equal(t, len(config.NewConfigValidator().ValidateConfig(conf).GetByCategory("Profile")), 0)
+}
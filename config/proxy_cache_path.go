@@ -36,6 +36,8 @@ type ProxyCachePath struct {
 	PurgerThreshold  string // Purger threshold time
 	Comment          []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -485,3 +487,8 @@ func NewProxyCachePath(directive IDirective) (*ProxyCachePath, error) {
 
 	return proxyCachePath, nil
 }
+
+// Clone returns a deep copy of the proxy_cache_path directive, detached from its parent.
+func (pcp *ProxyCachePath) Clone() *ProxyCachePath {
+	return Clone(pcp).(*ProxyCachePath)
+}
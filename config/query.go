@@ -0,0 +1,503 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// QueryResult is a directive matched by a query together with the directives enclosing it
+type QueryResult struct {
+	Directive IDirective
+	Parents   []IDirective // enclosing block directives, outermost first
+}
+
+// Parent returns the directive enclosing the result, nil at the top level
+func (r *QueryResult) Parent() IDirective {
+	if len(r.Parents) == 0 {
+		return nil
+	}
+	return r.Parents[len(r.Parents)-1]
+}
+
+// Path returns the names of the enclosing directives and the directive, like "http > server > listen"
+func (r *QueryResult) Path() string {
+	names := make([]string, 0, len(r.Parents)+1)
+	for _, p := range r.Parents {
+		names = append(names, p.GetName())
+	}
+	names = append(names, r.Directive.GetName())
+	return strings.Join(names, " > ")
+}
+
+// Selector is a compiled query, safe to reuse across configs
+type Selector struct {
+	query string
+	paths [][]queryStep // alternatives separated by ','
+}
+
+// axis tells how a step relates to the results of the previous one
+type axis int
+
+const (
+	axisDescendant axis = iota // "a b": b anywhere below a
+	axisChild                  // "a > b": b directly inside a
+	axisParent                 // "a < b": b directly enclosing a
+)
+
+// queryStep selects directives by name and predicates along an axis
+type queryStep struct {
+	axis       axis
+	name       string // "*" matches any directive
+	predicates []queryPredicate
+}
+
+// queryPredicate is a "[attr op value]" filter, op is empty for an existence test
+type queryPredicate struct {
+	attr  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+// Query selects the directives of conf matching a CSS-like query.
+//
+// Steps are directive names (or "*") separated by axes: whitespace selects
+// descendants, ">" children and "<" the enclosing directive. A leading ">"
+// anchors the query at the top level, otherwise the first step matches at any
+// depth. Alternatives are separated by ",". Each step takes predicates:
+//
+//	[attr]          attr is present
+//	[attr=v]        a value of attr equals v (!= none does)
+//	[attr^=v]       a value starts with v ($= ends with, *= contains)
+//	[attr~=re]      a value matches the regular expression re as a whole
+//
+// where attr is "param" (any parameter), "value" (all parameters joined by a
+// space), a 1-based parameter index, "match" or "modifier" of a location, or
+// otherwise the name of a child directive whose parameters are tested. Values
+// may be quoted. Included files are searched as if they were inlined, e.g.
+//
+//	http > server[server_name~=api.*] > location[match^=/v1] > proxy_pass
+func Query(conf *Config, query string) ([]*QueryResult, error) {
+	s, err := CompileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(conf), nil
+}
+
+// MustCompileQuery is like CompileQuery but panics if the query is invalid
+func MustCompileQuery(query string) *Selector {
+	s, err := CompileQuery(query)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// CompileQuery parses a query, see Query for the syntax
+func CompileQuery(query string) (*Selector, error) {
+	qp := &queryParser{input: query}
+	paths, err := qp.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Selector{query: query, paths: paths}, nil
+}
+
+// String returns the query the selector was compiled from
+func (s *Selector) String() string {
+	return s.query
+}
+
+// Select returns the directives of conf matching the selector in document order
+func (s *Selector) Select(conf *Config) []*QueryResult {
+	if conf == nil {
+		return nil
+	}
+	return s.SelectIn(conf.Block)
+}
+
+// SelectIn returns the directives below block matching the selector in document order
+func (s *Selector) SelectIn(block IBlock) []*QueryResult {
+	var results []*QueryResult
+	seen := map[IDirective]bool{}
+	for _, path := range s.paths {
+		for _, r := range selectPath(block, path) {
+			if !seen[r.Directive] {
+				seen[r.Directive] = true
+				results = append(results, r)
+			}
+		}
+	}
+	if len(s.paths) > 1 {
+		results = documentOrder(block, results)
+	}
+	return results
+}
+
+// selectPath applies the steps of one alternative starting from the root block
+func selectPath(root IBlock, path []queryStep) []*QueryResult {
+	// the root is a result without a directive, its children are the top level directives
+	current := []*QueryResult{{}}
+	for _, step := range path {
+		var next []*QueryResult
+		seen := map[IDirective]bool{}
+		add := func(r *QueryResult) {
+			if !seen[r.Directive] && step.matches(r, root) {
+				seen[r.Directive] = true
+				next = append(next, r)
+			}
+		}
+		for _, r := range current {
+			switch step.axis {
+			case axisChild:
+				for _, c := range queryChildren(r, root) {
+					add(c)
+				}
+			case axisDescendant:
+				queryDescendants(r, root, add)
+			case axisParent:
+				if len(r.Parents) > 0 {
+					add(&QueryResult{Directive: r.Parent(), Parents: r.Parents[:len(r.Parents)-1]})
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// queryChildren returns the directives directly inside a result, looking through includes
+func queryChildren(r *QueryResult, root IBlock) []*QueryResult {
+	var block IBlock
+	var parents []IDirective
+	if r.Directive == nil {
+		block = root
+	} else {
+		block = r.Directive.GetBlock()
+		parents = make([]IDirective, len(r.Parents), len(r.Parents)+1)
+		copy(parents, r.Parents)
+		parents = append(parents, r.Directive)
+	}
+	var children []*QueryResult
+	var collect func(directives []IDirective)
+	collect = func(directives []IDirective) {
+		for _, d := range directives {
+			children = append(children, &QueryResult{Directive: d, Parents: parents})
+			if include, ok := d.(*Include); ok {
+				for _, c := range include.Configs {
+					collect(c.GetDirectives())
+				}
+			}
+		}
+	}
+	if block != nil {
+		collect(block.GetDirectives())
+	}
+	return children
+}
+
+// queryDescendants calls fn for every directive below a result in document order
+func queryDescendants(r *QueryResult, root IBlock, fn func(*QueryResult)) {
+	for _, c := range queryChildren(r, root) {
+		fn(c)
+		queryDescendants(c, root, fn)
+	}
+}
+
+// documentOrder sorts the results of several alternatives back into document order
+func documentOrder(root IBlock, results []*QueryResult) []*QueryResult {
+	index := map[IDirective]int{}
+	queryDescendants(&QueryResult{}, root, func(r *QueryResult) {
+		if _, ok := index[r.Directive]; !ok {
+			index[r.Directive] = len(index)
+		}
+	})
+	sorted := make([]*QueryResult, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return index[sorted[i].Directive] < index[sorted[j].Directive]
+	})
+	return sorted
+}
+
+// matches reports whether a candidate satisfies the name and predicates of the step
+func (s *queryStep) matches(r *QueryResult, root IBlock) bool {
+	if r.Directive == nil || (s.name != "*" && r.Directive.GetName() != s.name) {
+		return false
+	}
+	for _, p := range s.predicates {
+		if !p.matches(r, root) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches tests the predicate against the values of its attribute
+func (p *queryPredicate) matches(r *QueryResult, root IBlock) bool {
+	values, present := p.values(r, root)
+	if p.op == "" {
+		return present
+	}
+	if p.op == "!=" {
+		for _, v := range values {
+			if v == p.value {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if p.test(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryPredicate) test(v string) bool {
+	switch p.op {
+	case "=":
+		return v == p.value
+	case "^=":
+		return strings.HasPrefix(v, p.value)
+	case "$=":
+		return strings.HasSuffix(v, p.value)
+	case "*=":
+		return strings.Contains(v, p.value)
+	case "~=":
+		return p.re.MatchString(v)
+	}
+	return false
+}
+
+// values returns the values a predicate attribute takes for a directive and whether it is present
+func (p *queryPredicate) values(r *QueryResult, root IBlock) ([]string, bool) {
+	d := r.Directive
+	switch p.attr {
+	case "param":
+		values := parameterValues(d)
+		return values, len(values) > 0
+	case "value":
+		values := parameterValues(d)
+		return []string{strings.Join(values, " ")}, len(values) > 0
+	case "match":
+		if l, ok := d.(*Location); ok {
+			return []string{unquote(l.Match)}, true
+		}
+		return nil, false
+	case "modifier":
+		if l, ok := d.(*Location); ok && l.Modifier != "" {
+			return []string{l.Modifier}, true
+		}
+		return nil, false
+	}
+	if i, err := strconv.Atoi(p.attr); err == nil {
+		values := parameterValues(d)
+		if i < 1 || i > len(values) {
+			return nil, false
+		}
+		return []string{values[i-1]}, true
+	}
+
+	// the name of a child directive
+	var values []string
+	present := false
+	for _, c := range queryChildren(r, root) {
+		if c.Directive.GetName() == p.attr {
+			present = true
+			values = append(values, parameterValues(c.Directive)...)
+		}
+	}
+	return values, present
+}
+
+// parameterValues returns the parameter values of a directive without their quotes
+func parameterValues(d IDirective) []string {
+	params := d.GetParameters()
+	values := make([]string, 0, len(params))
+	for _, p := range params {
+		values = append(values, unquote(p.GetValue()))
+	}
+	return values
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// queryParser is a small recursive descent parser for the query syntax
+type queryParser struct {
+	input string
+	pos   int
+}
+
+func (qp *queryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid query %q at offset %d: %s", qp.input, qp.pos, fmt.Sprintf(format, args...))
+}
+
+func (qp *queryParser) parse() ([][]queryStep, error) {
+	var paths [][]queryStep
+	for {
+		path, err := qp.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		qp.skipSpace()
+		if qp.pos == len(qp.input) {
+			return paths, nil
+		}
+		if qp.input[qp.pos] != ',' {
+			return nil, qp.errorf("unexpected %q", qp.input[qp.pos])
+		}
+		qp.pos++
+	}
+}
+
+// parsePath parses the steps of one alternative
+func (qp *queryParser) parsePath() ([]queryStep, error) {
+	var path []queryStep
+	for {
+		hadSpace := qp.skipSpace()
+		if qp.pos == len(qp.input) || qp.input[qp.pos] == ',' {
+			if len(path) == 0 {
+				return nil, qp.errorf("expected a directive name")
+			}
+			return path, nil
+		}
+
+		ax := axisDescendant
+		switch qp.input[qp.pos] {
+		case '>':
+			ax = axisChild
+			qp.pos++
+			qp.skipSpace()
+		case '<':
+			if len(path) == 0 {
+				return nil, qp.errorf("'<' needs a step before it")
+			}
+			ax = axisParent
+			qp.pos++
+			qp.skipSpace()
+		default:
+			if len(path) > 0 && !hadSpace {
+				return nil, qp.errorf("unexpected %q", qp.input[qp.pos])
+			}
+		}
+
+		step, err := qp.parseStep(ax)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, step)
+	}
+}
+
+// parseStep parses a directive name and its predicates
+func (qp *queryParser) parseStep(ax axis) (queryStep, error) {
+	step := queryStep{axis: ax}
+	start := qp.pos
+	for qp.pos < len(qp.input) && isQueryNameChar(qp.input[qp.pos]) {
+		qp.pos++
+	}
+	step.name = qp.input[start:qp.pos]
+	if step.name == "" {
+		if qp.pos < len(qp.input) && qp.input[qp.pos] == '*' {
+			qp.pos++
+			step.name = "*"
+		} else {
+			return step, qp.errorf("expected a directive name")
+		}
+	}
+
+	for qp.pos < len(qp.input) && qp.input[qp.pos] == '[' {
+		qp.pos++
+		p, err := qp.parsePredicate()
+		if err != nil {
+			return step, err
+		}
+		step.predicates = append(step.predicates, p)
+	}
+	return step, nil
+}
+
+// parsePredicate parses the inside of "[...]" including the closing bracket
+func (qp *queryParser) parsePredicate() (queryPredicate, error) {
+	var p queryPredicate
+	qp.skipSpace()
+	start := qp.pos
+	for qp.pos < len(qp.input) && isQueryNameChar(qp.input[qp.pos]) {
+		qp.pos++
+	}
+	p.attr = qp.input[start:qp.pos]
+	if p.attr == "" {
+		return p, qp.errorf("expected an attribute name")
+	}
+	qp.skipSpace()
+	if qp.pos < len(qp.input) && qp.input[qp.pos] == ']' {
+		qp.pos++
+		return p, nil
+	}
+
+	for _, op := range []string{"=", "!=", "^=", "$=", "*=", "~="} {
+		if strings.HasPrefix(qp.input[qp.pos:], op) {
+			p.op = op
+			qp.pos += len(op)
+			break
+		}
+	}
+	if p.op == "" {
+		return p, qp.errorf("expected an operator or ']'")
+	}
+	qp.skipSpace()
+
+	if qp.pos < len(qp.input) && (qp.input[qp.pos] == '"' || qp.input[qp.pos] == '\'') {
+		quote := qp.input[qp.pos]
+		end := strings.IndexByte(qp.input[qp.pos+1:], quote)
+		if end < 0 {
+			return p, qp.errorf("unclosed quote")
+		}
+		p.value = qp.input[qp.pos+1 : qp.pos+1+end]
+		qp.pos += end + 2
+		qp.skipSpace()
+	} else {
+		start := qp.pos
+		for qp.pos < len(qp.input) && qp.input[qp.pos] != ']' {
+			qp.pos++
+		}
+		p.value = strings.TrimSpace(qp.input[start:qp.pos])
+	}
+	if qp.pos == len(qp.input) || qp.input[qp.pos] != ']' {
+		return p, qp.errorf("expected ']'")
+	}
+	qp.pos++
+
+	if p.op == "~=" {
+		re, err := regexp.Compile("^(?:" + p.value + ")$")
+		if err != nil {
+			return p, qp.errorf("%v", err)
+		}
+		p.re = re
+	}
+	return p, nil
+}
+
+func (qp *queryParser) skipSpace() bool {
+	start := qp.pos
+	for qp.pos < len(qp.input) && (qp.input[qp.pos] == ' ' || qp.input[qp.pos] == '\t' || qp.input[qp.pos] == '\n') {
+		qp.pos++
+	}
+	return qp.pos > start
+}
+
+func isQueryNameChar(c byte) bool {
+	return c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,169 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+const queryConfig = `
+http {
+	upstream backend {
+		server 127.0.0.1:8080;
+	}
+	server {
+		listen 80;
+		server_name example.com;
+		location / {
+			root /var/www;
+		}
+	}
+	server {
+		listen 443 ssl;
+		server_name api.example.com;
+		location /v1/users {
+			proxy_pass http://backend;
+		}
+		location ~ ^/v2 {
+			proxy_pass http://backend2;
+			location /v2/nested {
+				proxy_pass http://nested;
+			}
+		}
+		location = "/v1/exact" {
+			return 200;
+		}
+	}
+}
+`
+
+func queryValues(t *testing.T, conf *config.Config, query string) []string {
+	t.Helper()
+	results, err := config.Query(conf, query)
+	assert.NilError(t, err)
+	values := []string{}
+	for _, r := range results {
+		values = append(values, r.Path()+" "+queryParams(r.Directive))
+	}
+	return values
+}
+
+func queryParams(d config.IDirective) string {
+	s := ""
+	for i, p := range d.GetParameters() {
+		if i > 0 {
+			s += " "
+		}
+		s += p.GetValue()
+	}
+	return s
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(queryConfig).Parse()
+	assert.NilError(t, err)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"http > server[server_name~=api.*] > location[match^=/v1] > proxy_pass", []string{
+			"http > server > location > proxy_pass http://backend",
+		}},
+		{"server proxy_pass", []string{
+			"http > server > location > proxy_pass http://backend",
+			"http > server > location > proxy_pass http://backend2",
+			"http > server > location > location > proxy_pass http://nested",
+		}},
+		{"server > location > proxy_pass", []string{
+			"http > server > location > proxy_pass http://backend",
+			"http > server > location > proxy_pass http://backend2",
+		}},
+		{"> listen", []string{}},
+		{"listen[param=ssl]", []string{"http > server > listen 443 ssl"}},
+		{"listen[1=80]", []string{"http > server > listen 80"}},
+		{"listen[2]", []string{"http > server > listen 443 ssl"}},
+		{"listen[value='443 ssl']", []string{"http > server > listen 443 ssl"}},
+		{"location[modifier=~]", []string{"http > server > location ~ ^/v2"}},
+		{"location[match=/v1/exact]", []string{`http > server > location = "/v1/exact"`}},
+		{"location[match$=users]", []string{"http > server > location /v1/users"}},
+		{"server[server_name!=example.com] > listen", []string{"http > server > listen 443 ssl"}},
+		{"server[root]", []string{}},
+		{"location[root]", []string{"http > server > location /"}},
+		{"proxy_pass[1*=nested] < location < *", []string{"http > server > location ~ ^/v2"}},
+		{"return < location < server > listen", []string{"http > server > listen 443 ssl"}},
+		{"upstream > server, listen[1=80]", []string{
+			"http > upstream > server 127.0.0.1:8080",
+			"http > server > listen 80",
+		}},
+	}
+	for _, tt := range tests {
+		assert.DeepEqual(t, queryValues(t, conf, tt.query), tt.want)
+	}
+}
+
+func TestQuery_ParentLinks(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(queryConfig).Parse()
+	assert.NilError(t, err)
+
+	results, err := config.Query(conf, "location[match=/v2/nested] > proxy_pass")
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	r := results[0]
+	assert.Equal(t, len(r.Parents), 4)
+	assert.Equal(t, r.Parent().(*config.Location).Match, "/v2/nested")
+	assert.Equal(t, r.Parents[2].(*config.Location).Match, "^/v2")
+	_, ok := r.Parents[1].(*config.Server)
+	assert.Assert(t, ok)
+	// the results are the directives of the tree, not copies
+	assert.Equal(t, r.Directive.GetParent(), r.Parent())
+}
+
+func TestQuery_Include(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	included := filepath.Join(dir, "api.conf")
+	assert.NilError(t, os.WriteFile(included, []byte("server {\n    server_name api.example.com;\n    location /v1 { proxy_pass http://api; }\n}\n"), 0o644))
+
+	conf, err := parser.NewStringParser("http {\n    include "+included+";\n}\n", parser.WithIncludeParsing()).Parse()
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, queryValues(t, conf, "http > server[server_name~=api.*] > location[match^=/v1] > proxy_pass"), []string{
+		"http > server > location > proxy_pass http://api",
+	})
+	assert.DeepEqual(t, queryValues(t, conf, "http > include"), []string{"http > include " + included})
+}
+
+func TestCompileQuery_Errors(t *testing.T) {
+	t.Parallel()
+
+	for _, query := range []string{
+		"",
+		"http >",
+		"< server",
+		"server[",
+		"server[name",
+		"server[name=",
+		"server[name=\"x]",
+		"server[name~=(]",
+		"server[=x]",
+		"server[name%x]",
+		"server,",
+		"server)",
+	} {
+		_, err := config.CompileQuery(query)
+		assert.Assert(t, err != nil, "query %q should not compile", query)
+	}
+
+	s := config.MustCompileQuery("server  >  listen")
+	assert.Equal(t, s.String(), "server  >  listen")
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// Unlimited is the MaxArgs of the directives taking any number of arguments
+const Unlimited = -1
+
+// DirectiveSchema describes how a directive is written: where it is allowed, its arguments,
+// whether it opens a block and how it is inherited. The validators and the parser read the
+// schemas of the registry, see RegisterDirective.
+type DirectiveSchema struct {
+	Name     string
+	Contexts []string // the contexts the directive is allowed in, like "http" or "location"; empty for all
+	MinArgs  int
+	MaxArgs  int // Unlimited for no limit
+	// ArgTypes are the types of the arguments by position, the last one applies to the arguments
+	// after it. Empty accepts any argument. An argument holding a variable is always accepted,
+	// a number is accepted as a size or a time.
+	ArgTypes []ParameterType
+	// Keywords are values accepted as any argument whatever its type, like "auto" or "off"
+	Keywords    []string
+	Block       bool            // the directive opens a block
+	Repeatable  bool            // the directive may be given more than once in a block
+	Default     string          // the value nginx uses when the directive is not given, empty for none
+	Inheritance InheritanceRule // how the directive passes to inner blocks, kept in InheritanceRules
+}
+
+// AllowedIn tells whether the directive may be given in a context
+func (s DirectiveSchema) AllowedIn(context string) bool {
+	if len(s.Contexts) == 0 {
+		return true
+	}
+	for _, c := range s.Contexts {
+		if c == context {
+			return true
+		}
+	}
+	return false
+}
+
+// Accepts tells whether the i-th argument of the directive may have the value
+func (s DirectiveSchema) Accepts(i int, value string) bool {
+	if len(s.ArgTypes) == 0 || strings.Contains(value, "$") {
+		return true
+	}
+	value = strings.Trim(value, `"'`)
+	for _, keyword := range s.Keywords {
+		if strings.EqualFold(value, keyword) {
+			return true
+		}
+	}
+	if i >= len(s.ArgTypes) {
+		i = len(s.ArgTypes) - 1
+	}
+	detected := DetectParameterType(value)
+	switch s.ArgTypes[i] {
+	case ParameterTypeNumber:
+		return detected == ParameterTypeNumber
+	case ParameterTypeSize:
+		return detected == ParameterTypeSize || detected == ParameterTypeNumber
+	case ParameterTypeTime:
+		// 5m is detected as a size, for nginx it is five minutes here
+		return detected == ParameterTypeTime || detected == ParameterTypeNumber || detected == ParameterTypeSize
+	case ParameterTypeBoolean:
+		return strings.EqualFold(value, "on") || strings.EqualFold(value, "off")
+	}
+	return true
+}
+
+// directiveSchemas holds the registered schemas by directive name, a directive may have one
+// schema per context like server, a block in http and a leaf directive in upstream
+var directiveSchemas = map[string][]DirectiveSchema{}
+
+// RegisterDirective adds the schema of a directive to the registry, typically for a third party
+// module. It replaces the schemas of the directive allowed in one of the same contexts. The
+// directive becomes known to the parser and its rules are used by the validators created after.
+func RegisterDirective(schema DirectiveSchema) {
+	if schema.Name == "" {
+		panic("config: RegisterDirective with an empty directive name")
+	}
+	var kept []DirectiveSchema
+	for _, existing := range directiveSchemas[schema.Name] {
+		if !sharesContext(existing, schema) {
+			kept = append(kept, existing)
+		}
+	}
+	directiveSchemas[schema.Name] = append(kept, schema)
+	knownDirectives[schema.Name] = true
+	if schema.Inheritance != (InheritanceRule{}) {
+		InheritanceRules[schema.Name] = schema.Inheritance
+	}
+}
+
+// sharesContext tells whether two schemas are allowed in a common context
+func sharesContext(a, b DirectiveSchema) bool {
+	if len(a.Contexts) == 0 || len(b.Contexts) == 0 {
+		return true
+	}
+	for _, c := range b.Contexts {
+		if a.AllowedIn(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupDirective returns the schema of a directive in a context, or its first schema when the
+// context is empty. It returns false when no schema allows the directive there.
+func LookupDirective(name, context string) (DirectiveSchema, bool) {
+	for _, schema := range directiveSchemas[name] {
+		if context == "" || schema.AllowedIn(context) {
+			schema.Inheritance = InheritanceRules[name]
+			return schema, true
+		}
+	}
+	return DirectiveSchema{}, false
+}
+
+// DirectiveSchemas returns all the schemas of a directive
+func DirectiveSchemas(name string) []DirectiveSchema {
+	schemas := make([]DirectiveSchema, len(directiveSchemas[name]))
+	for i, schema := range directiveSchemas[name] {
+		schema.Inheritance = InheritanceRules[name]
+		schemas[i] = schema
+	}
+	return schemas
+}
+
+// IsKnownDirective tells whether a directive is one of nginx and its common modules or a registered one
+func IsKnownDirective(name string) bool {
+	return knownDirectives[name]
+}
+
+// KnownDirectives returns the names of the known directives, sorted
+func KnownDirectives() []string {
+	names := make([]string, 0, len(knownDirectives))
+	for name := range knownDirectives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
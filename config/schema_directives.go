@@ -0,0 +1,159 @@
+package config
+
+// the contexts shared by many directives
+var (
+	mainContext         = []string{"main"}
+	eventsContext       = []string{"events"}
+	httpContext         = []string{"http"}
+	serverContext       = []string{"server"}
+	upstreamContext     = []string{"upstream"}
+	locationContexts    = []string{"location", "if"}
+	httpServerLocation  = []string{"http", "server", "location"}
+	httpServerLocIf     = []string{"http", "server", "location", "if"}
+	httpServerLocExcept = []string{"http", "server", "location", "limit_except"}
+)
+
+// builtinSchemas are the schemas of the core nginx directives, registered at init
+var builtinSchemas = []DirectiveSchema{
+	// Main context only
+	{Name: "user", Contexts: mainContext, MinArgs: 1, MaxArgs: 2, Default: "nobody nobody"},
+	{Name: "worker_processes", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeNumber}, Keywords: []string{"auto"}, Default: "1"},
+	{Name: "worker_rlimit_nofile", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeNumber}},
+	{Name: "pid", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypePath}, Default: "logs/nginx.pid"},
+	{Name: "daemon", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "on"},
+	{Name: "master_process", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "on"},
+	{Name: "timer_resolution", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}},
+	{Name: "worker_priority", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeNumber}, Default: "0"},
+	{Name: "worker_cpu_affinity", Contexts: mainContext, MinArgs: 1, MaxArgs: Unlimited},
+	{Name: "worker_shutdown_timeout", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}},
+	{Name: "load_module", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypePath}, Repeatable: true},
+	{Name: "pcre_jit", Contexts: mainContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "off"},
+	{Name: "ssl_engine", Contexts: mainContext, MinArgs: 1, MaxArgs: 1},
+	{Name: "thread_pool", Contexts: mainContext, MinArgs: 2, MaxArgs: 3, Repeatable: true, Default: "default threads=32 max_queue=65536"},
+	{Name: "events", Contexts: mainContext, Block: true},
+	{Name: "http", Contexts: mainContext, Block: true},
+	{Name: "stream", Contexts: mainContext, Block: true},
+
+	// Events context only
+	{Name: "worker_connections", Contexts: eventsContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeNumber}, Default: "512"},
+	{Name: "use", Contexts: eventsContext, MinArgs: 1, MaxArgs: 1},
+	{Name: "multi_accept", Contexts: eventsContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "off"},
+	{Name: "accept_mutex", Contexts: eventsContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "off"},
+	{Name: "accept_mutex_delay", Contexts: eventsContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}, Default: "500ms"},
+	{Name: "worker_aio_requests", Contexts: eventsContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeNumber}, Default: "32"},
+
+	// HTTP context only
+	{Name: "server", Contexts: httpContext, Block: true, Repeatable: true},
+	{Name: "upstream", Contexts: httpContext, MinArgs: 1, MaxArgs: 1, Block: true, Repeatable: true},
+	{Name: "map", Contexts: httpContext, MinArgs: 2, MaxArgs: 2, Block: true, Repeatable: true},
+	{Name: "geo", Contexts: httpContext, MinArgs: 1, MaxArgs: 2, Block: true, Repeatable: true},
+	{Name: "split_clients", Contexts: httpContext, MinArgs: 2, MaxArgs: 2, Block: true, Repeatable: true},
+	{Name: "limit_req_zone", Contexts: httpContext, MinArgs: 3, MaxArgs: 4, Repeatable: true},
+	{Name: "limit_conn_zone", Contexts: httpContext, MinArgs: 2, MaxArgs: 2, Repeatable: true},
+	{Name: "proxy_cache_path", Contexts: httpContext, MinArgs: 2, MaxArgs: Unlimited, Repeatable: true},
+	{Name: "fastcgi_cache_path", Contexts: httpContext, MinArgs: 2, MaxArgs: Unlimited, Repeatable: true},
+	{Name: "uwsgi_cache_path", Contexts: httpContext, MinArgs: 2, MaxArgs: Unlimited, Repeatable: true},
+	{Name: "scgi_cache_path", Contexts: httpContext, MinArgs: 2, MaxArgs: Unlimited, Repeatable: true},
+	{Name: "types_hash_bucket_size", Contexts: httpContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeSize}, Default: "64"},
+	{Name: "types_hash_max_size", Contexts: httpContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeSize}, Default: "1024"},
+	{Name: "variables_hash_bucket_size", Contexts: httpContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeSize}, Default: "64"},
+	{Name: "variables_hash_max_size", Contexts: httpContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeSize}, Default: "1024"},
+	{Name: "server_tokens", Contexts: httpContext, MinArgs: 1, MaxArgs: 1, Default: "on"},
+
+	// Server context only
+	{Name: "listen", Contexts: serverContext, MinArgs: 1, MaxArgs: Unlimited, Repeatable: true, Default: "*:80"},
+	{Name: "server_name", Contexts: serverContext, MinArgs: 1, MaxArgs: Unlimited, Repeatable: true, Default: `""`},
+	{Name: "location", Contexts: serverContext, MinArgs: 1, MaxArgs: 2, Block: true, Repeatable: true},
+	{Name: "ssl_certificate", Contexts: serverContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypePath}, Repeatable: true},
+	{Name: "ssl_certificate_key", Contexts: serverContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypePath}, Repeatable: true},
+	{Name: "ssl_protocols", Contexts: serverContext, MinArgs: 1, MaxArgs: Unlimited, Default: "TLSv1.2 TLSv1.3"},
+	{Name: "ssl_ciphers", Contexts: serverContext, MinArgs: 1, MaxArgs: 1, Default: "HIGH:!aNULL:!MD5"},
+	{Name: "ssl_prefer_server_ciphers", Contexts: serverContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "off"},
+	{Name: "ssl_session_cache", Contexts: serverContext, MinArgs: 1, MaxArgs: 2, Default: "none"},
+	{Name: "ssl_session_timeout", Contexts: serverContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}, Default: "5m"},
+
+	// Location and IF context only
+	{Name: "alias", Contexts: []string{"location"}, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypePath}},
+	{Name: "proxy_pass", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1},
+	{Name: "fastcgi_pass", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1},
+	{Name: "uwsgi_pass", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1},
+	{Name: "scgi_pass", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1},
+	{Name: "memcached_pass", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1},
+	{Name: "break", Contexts: locationContexts, Repeatable: true},
+	{Name: "set", Contexts: locationContexts, MinArgs: 2, MaxArgs: 2, Repeatable: true},
+	{Name: "internal", Contexts: []string{"location"}, Repeatable: true},
+	{Name: "limit_except", Contexts: []string{"location"}, MinArgs: 1, MaxArgs: Unlimited, Block: true},
+	{Name: "proxy_cache", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1, Default: "off"},
+	{Name: "proxy_cache_valid", Contexts: locationContexts, MinArgs: 1, MaxArgs: Unlimited, Repeatable: true},
+	{Name: "proxy_cache_key", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1, Default: "$scheme$proxy_host$request_uri"},
+	{Name: "fastcgi_cache", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1, Default: "off"},
+	{Name: "uwsgi_cache", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1, Default: "off"},
+	{Name: "scgi_cache", Contexts: locationContexts, MinArgs: 1, MaxArgs: 1, Default: "off"},
+
+	// Multiple contexts (http, server, location, if)
+	{Name: "root", Contexts: httpServerLocIf, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypePath}, Default: "html"},
+	{Name: "index", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: Unlimited, Repeatable: true, Default: "index.html"},
+	{Name: "try_files", Contexts: []string{"server", "location"}, MinArgs: 2, MaxArgs: Unlimited},
+	{Name: "return", Contexts: []string{"server", "location", "if"}, MinArgs: 1, MaxArgs: 2, Repeatable: true},
+	{Name: "rewrite", Contexts: []string{"server", "location", "if"}, MinArgs: 2, MaxArgs: 3, Repeatable: true},
+	{Name: "error_page", Contexts: httpServerLocIf, MinArgs: 2, MaxArgs: Unlimited, Repeatable: true},
+	{Name: "access_log", Contexts: httpServerLocIf, MinArgs: 1, MaxArgs: Unlimited, Repeatable: true, Default: "logs/access.log combined"},
+	{Name: "error_log", Contexts: []string{"main", "http", "server", "location"}, MinArgs: 1, MaxArgs: 2, Repeatable: true, Default: "logs/error.log error"},
+	{Name: "add_header", Contexts: httpServerLocIf, MinArgs: 2, MaxArgs: 3, Repeatable: true},
+	{Name: "expires", Contexts: httpServerLocIf, MinArgs: 1, MaxArgs: 2, Default: "off"},
+	{Name: "deny", Contexts: httpServerLocExcept, MinArgs: 1, MaxArgs: 1, Repeatable: true},
+	{Name: "allow", Contexts: httpServerLocExcept, MinArgs: 1, MaxArgs: 1, Repeatable: true},
+	{Name: "auth_basic", Contexts: httpServerLocExcept, MinArgs: 1, MaxArgs: 1, Default: "off"},
+	{Name: "auth_basic_user_file", Contexts: httpServerLocExcept, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypePath}},
+	{Name: "autoindex", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "off"},
+	{Name: "gzip", Contexts: httpServerLocIf, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "off"},
+	{Name: "gzip_types", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: Unlimited, Default: "text/html"},
+	{Name: "limit_req", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 3, Repeatable: true},
+	{Name: "limit_conn", Contexts: httpServerLocation, MinArgs: 2, MaxArgs: 2, ArgTypes: []ParameterType{ParameterTypeString, ParameterTypeNumber}, Repeatable: true},
+
+	// HTTP and server context
+	{Name: "client_max_body_size", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeSize}, Default: "1m"},
+	{Name: "client_body_timeout", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}, Default: "60s"},
+	{Name: "client_header_timeout", Contexts: []string{"http", "server"}, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}, Default: "60s"},
+	{Name: "keepalive_timeout", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 2, ArgTypes: []ParameterType{ParameterTypeTime}, Default: "75s"},
+	{Name: "send_timeout", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}, Default: "60s"},
+	{Name: "sendfile", Contexts: httpServerLocIf, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "off"},
+	{Name: "tcp_nodelay", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "on"},
+	{Name: "tcp_nopush", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "off"},
+
+	// Proxy directives
+	{Name: "proxy_set_header", Contexts: httpServerLocIf, MinArgs: 2, MaxArgs: 2, Repeatable: true},
+	{Name: "proxy_connect_timeout", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}, Default: "60s"},
+	{Name: "proxy_read_timeout", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}, Default: "60s"},
+	{Name: "proxy_send_timeout", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeTime}, Default: "60s"},
+	{Name: "proxy_buffering", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeBoolean}, Default: "on"},
+	{Name: "proxy_buffer_size", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeSize}, Default: "4k"},
+	{Name: "proxy_buffers", Contexts: httpServerLocation, MinArgs: 2, MaxArgs: 2, ArgTypes: []ParameterType{ParameterTypeNumber, ParameterTypeSize}, Default: "8 4k"},
+	{Name: "proxy_busy_buffers_size", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeSize}, Default: "8k"},
+
+	// Upstream context directives
+	{Name: "server", Contexts: []string{"upstream", "stream_upstream"}, MinArgs: 1, MaxArgs: Unlimited, Repeatable: true},
+	{Name: "hash", Contexts: upstreamContext, MinArgs: 1, MaxArgs: 2},
+	{Name: "ip_hash", Contexts: upstreamContext},
+	{Name: "least_conn", Contexts: upstreamContext},
+	{Name: "least_time", Contexts: upstreamContext, MinArgs: 1, MaxArgs: 2},
+	{Name: "random", Contexts: upstreamContext, MaxArgs: 2},
+	{Name: "keepalive", Contexts: upstreamContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeNumber}},
+	{Name: "keepalive_requests", Contexts: upstreamContext, MinArgs: 1, MaxArgs: 1, ArgTypes: []ParameterType{ParameterTypeNumber}, Default: "1000"},
+	{Name: "ntlm", Contexts: upstreamContext},
+	{Name: "sticky", Contexts: upstreamContext, MinArgs: 1, MaxArgs: Unlimited},
+	{Name: "zone", Contexts: upstreamContext, MinArgs: 1, MaxArgs: 2},
+
+	// Can appear in main and other contexts
+	{Name: "include", Contexts: []string{"main", "events", "http", "server", "location", "upstream", "map", "geo", "split_clients"}, MinArgs: 1, MaxArgs: 1, Repeatable: true},
+
+	// Special directives
+	{Name: "if", Contexts: []string{"server", "location"}, MinArgs: 1, MaxArgs: Unlimited, Block: true, Repeatable: true},
+	{Name: "default_type", Contexts: httpServerLocation, MinArgs: 1, MaxArgs: 1, Default: "text/plain"},
+}
+
+func init() {
+	for _, schema := range builtinSchemas {
+		RegisterDirective(schema)
+	}
+}
@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+func TestLookupDirective(t *testing.T) {
+	t.Parallel()
+
+	schema, ok := config.LookupDirective("worker_connections", "events")
+	assert.Assert(t, ok)
+	assert.Equal(t, schema.MinArgs, 1)
+	assert.Equal(t, schema.MaxArgs, 1)
+	assert.Equal(t, schema.Default, "512")
+	assert.Assert(t, schema.Accepts(0, "1024"))
+	assert.Assert(t, !schema.Accepts(0, "many"))
+	assert.Assert(t, schema.Accepts(0, "$connections"))
+
+	// server is a block in http and an upstream member in upstream
+	server, ok := config.LookupDirective("server", "http")
+	assert.Assert(t, ok && server.Block)
+	server, ok = config.LookupDirective("server", "upstream")
+	assert.Assert(t, ok && !server.Block && server.Repeatable)
+	_, ok = config.LookupDirective("server", "location")
+	assert.Assert(t, !ok)
+
+	proxyPass, _ := config.LookupDirective("proxy_pass", "location")
+	assert.Assert(t, proxyPass.Inheritance.NotInherited)
+
+	assert.Assert(t, config.IsKnownDirective("proxy_set_header"))
+	assert.Assert(t, !config.IsKnownDirective("not_a_directive"))
+}
+
+// TestRegisterDirective is not parallel, it changes the registry the other tests read
+func TestRegisterDirective(t *testing.T) {
+	config.RegisterDirective(config.DirectiveSchema{
+		Name:     "schema_test_vts",
+		Contexts: []string{"http", "server"},
+		MinArgs:  1,
+		MaxArgs:  1,
+		ArgTypes: []config.ParameterType{config.ParameterTypeBoolean},
+	})
+	assert.Assert(t, config.IsKnownDirective("schema_test_vts"))
+	assert.DeepEqual(t, config.NewContextValidator().GetAllowedContexts("schema_test_vts"), []string{"http", "server"})
+
+	// the parser accepts the registered directive without WithCustomDirectives
+	conf, err := parser.NewStringParser(`http {
+    schema_test_vts maybe;
+    server {
+        location / {
+            schema_test_vts on;
+        }
+    }
+}`).Parse()
+	assert.NilError(t, err)
+
+	report := config.NewConfigValidator().ValidateConfig(conf)
+	var titles []string
+	for _, issue := range report.Issues {
+		if issue.Directive == "schema_test_vts" {
+			titles = append(titles, issue.Title)
+		}
+	}
+	assert.DeepEqual(t, titles, []string{"Invalid directive context", "Invalid parameter type"})
+}
+
+func TestConfigValidator_Schema(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(`worker_processes auto;
+worker_processes 2;
+events {
+    worker_connections 1024 2048;
+}
+http {
+    server {
+        listen 80;
+        location / {
+            root;
+            set $a 1;
+            set $b 2;
+        }
+        location /x;
+    }
+}`).Parse()
+	assert.NilError(t, err)
+
+	report := config.NewConfigValidator().ValidateConfig(conf)
+	var got []string
+	for _, issue := range report.Issues {
+		if issue.Category == "Parameter" || issue.Category == "Structure" {
+			got = append(got, issue.Directive+": "+issue.Title)
+		}
+	}
+	assert.DeepEqual(t, got, []string{
+		"worker_processes: Duplicate directive",
+		"worker_connections: Too many parameters",
+		"root: Missing parameters",
+		"location: Missing block",
+	})
+}
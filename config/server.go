@@ -9,6 +9,8 @@ type Server struct {
 	Block   IBlock
 	Comment []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -98,3 +100,8 @@ func (s *Server) GetDirectives() []IDirective {
 	}
 	return block.GetDirectives()
 }
+
+// Clone returns a deep copy of the server block, detached from its parent.
+func (s *Server) Clone() *Server {
+	return Clone(s).(*Server)
+}
@@ -14,6 +14,8 @@ type SplitClients struct {
 	Entries        []*SplitClientsEntry
 	Comment        []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -24,6 +26,8 @@ type SplitClientsEntry struct {
 	Value      string // Value to set for this percentage
 	Comment    []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -332,7 +336,19 @@ func NewSplitClientsEntry(directive IDirective) (*SplitClientsEntry, error) {
 		Value:                parameters[0].GetValue(),
 		Comment:              directive.GetComment(),
 		DefaultInlineComment: DefaultInlineComment{InlineComment: directive.GetInlineComment()},
+		DefaultTrivia:        DefaultTrivia{Trivia: TriviaOf(directive)},
+		DefaultRange:         DefaultRange{Range: RangeOf(directive)},
 	}
 
 	return entry, nil
 }
+
+// Clone returns a deep copy of the split_clients block, detached from its parent.
+func (sc *SplitClients) Clone() *SplitClients {
+	return Clone(sc).(*SplitClients)
+}
+
+// Clone returns a deep copy of the split_clients entry, detached from its parent.
+func (sce *SplitClientsEntry) Clone() *SplitClientsEntry {
+	return Clone(sce).(*SplitClientsEntry)
+}
@@ -1,5 +1,10 @@
 package config
 
+import (
+	"strconv"
+	"strings"
+)
+
 // IBlock represents any directive block
 // IBlock 用于表示 Nginx 配置文件中的块，它包含指令和子块的内容。
 type IBlock interface {
@@ -115,6 +120,8 @@ type Parameter struct {
 	Value             string
 	Type              ParameterType // parameter type
 	RelativeLineIndex int           // relative line index to the directive
+	Leading           string        // whitespace before the parameter, kept when parsed with trivia
+	Range             Range         // where the parameter was found in the source
 }
 
 // String returns the value of the parameter
@@ -142,6 +149,11 @@ func (p *Parameter) GetRelativeLineIndex() int {
 	return p.RelativeLineIndex
 }
 
+// GetRange returns where the parameter was found in the source
+func (p *Parameter) GetRange() Range {
+	return p.Range
+}
+
 // GetType returns the type of the parameter
 func (p *Parameter) GetType() ParameterType {
 	return p.Type
@@ -199,3 +211,104 @@ func (p *Parameter) IsQuoted() bool {
 
 // InlineComment represents an inline comment
 type InlineComment Parameter
+
+// Trivia keeps the parts of the source text that carry no meaning for nginx
+// (indentation, blank lines, comment placement and quoting) so that a
+// directive which has not been modified can be written back byte-for-byte.
+type Trivia struct {
+	Offset  int    // byte offset of the statement, including its leading comments
+	Leading string // whitespace between the previous statement and this one
+	Text    string // the whole statement as written, including its block
+	Header  string // block directives only: the text up to and including '{'
+	Closing string // block directives only: the text after the last child up to and including '}'
+
+	header string // fingerprint of the directive header when parsed
+	tree   string // fingerprint of the whole directive when parsed
+}
+
+// Snapshot records the current state of the directive so that later edits can be detected.
+func (t *Trivia) Snapshot(d IDirective) {
+	t.header = fingerprint(d, false)
+	t.tree = fingerprint(d, true)
+}
+
+// Unchanged returns true if neither the directive nor any of its children changed since the snapshot
+func (t *Trivia) Unchanged(d IDirective) bool {
+	return t.tree != "" && t.tree == fingerprint(d, true)
+}
+
+// HeaderUnchanged returns true if the name, parameters and comments of the directive did not change since the snapshot
+func (t *Trivia) HeaderUnchanged(d IDirective) bool {
+	return t.header != "" && t.header == fingerprint(d, false)
+}
+
+// fingerprint builds a string that changes whenever anything the dumper writes for d changes
+func fingerprint(d IDirective, withBlock bool) string {
+	var buf strings.Builder
+	writeFingerprint(&buf, d, withBlock)
+	return buf.String()
+}
+
+func writeFingerprint(buf *strings.Builder, d IDirective, withBlock bool) {
+	for _, comment := range d.GetComment() {
+		writeField(buf, '#', comment)
+	}
+	writeField(buf, 'n', d.GetName())
+	for _, parameter := range d.GetParameters() {
+		writeField(buf, 'p', parameter.GetValue())
+	}
+	for _, comment := range d.GetInlineComment() {
+		writeField(buf, 'i', comment.Value)
+	}
+	block := d.GetBlock()
+	if block == nil {
+		buf.WriteByte(';')
+		return
+	}
+	buf.WriteByte('{')
+	if withBlock {
+		writeField(buf, 'c', block.GetCodeBlock())
+		for _, child := range block.GetDirectives() {
+			writeFingerprint(buf, child, true)
+		}
+	}
+	buf.WriteByte('}')
+}
+
+// writeField writes a kind and a length-prefixed value, so that values holding spaces or
+// separators cannot make different statements look the same
+func writeField(buf *strings.Builder, kind byte, value string) {
+	buf.WriteByte(kind)
+	buf.WriteString(strconv.Itoa(len(value)))
+	buf.WriteByte(':')
+	buf.WriteString(value)
+}
+
+// TriviaHolder represents a directive that remembers the source text it was parsed from
+type TriviaHolder interface {
+	GetTrivia() *Trivia
+	SetTrivia(trivia *Trivia)
+}
+
+// DefaultTrivia represents the default trivia holder
+type DefaultTrivia struct {
+	Trivia *Trivia
+}
+
+// GetTrivia returns the trivia, nil if the directive was not parsed with trivia
+func (d *DefaultTrivia) GetTrivia() *Trivia {
+	return d.Trivia
+}
+
+// SetTrivia sets the trivia
+func (d *DefaultTrivia) SetTrivia(trivia *Trivia) {
+	d.Trivia = trivia
+}
+
+// TriviaOf returns the trivia of a directive if it has any
+func TriviaOf(d IDirective) *Trivia {
+	if holder, ok := d.(TriviaHolder); ok {
+		return holder.GetTrivia()
+	}
+	return nil
+}
@@ -10,6 +10,8 @@ type Stream struct {
 	*Block
 	Comment []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -144,3 +146,8 @@ func (s *Stream) AddUpstream(upstream *StreamUpstream) {
 func (s *Stream) AddServer(server *StreamServer) {
 	s.Block.AddDirective(server)
 }
+
+// Clone returns a deep copy of the stream block, detached from its parent.
+func (s *Stream) Clone() *Stream {
+	return Clone(s).(*Stream)
+}
@@ -9,6 +9,8 @@ type StreamServer struct {
 	*Block
 	Comment []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -231,3 +233,8 @@ func (ss *StreamServer) SetDirectiveWithParams(directiveName string, values []st
 	}
 	ss.Block.AddDirective(directive)
 }
+
+// Clone returns a deep copy of the stream server block, detached from its parent.
+func (ss *StreamServer) Clone() *StreamServer {
+	return Clone(ss).(*StreamServer)
+}
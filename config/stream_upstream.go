@@ -12,6 +12,8 @@ type StreamUpstream struct {
 	UpstreamName string
 	Servers      []*StreamUpstreamServer
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -299,3 +301,13 @@ func (sus *StreamUpstreamServer) GetMaxFails() string {
 func (sus *StreamUpstreamServer) GetFailTimeout() string {
 	return sus.Parameters["fail_timeout"]
 }
+
+// Clone returns a deep copy of the stream upstream block, detached from its parent.
+func (su *StreamUpstream) Clone() *StreamUpstream {
+	return Clone(su).(*StreamUpstream)
+}
+
+// Clone returns a deep copy of the stream upstream server, detached from its parent.
+func (sus *StreamUpstreamServer) Clone() *StreamUpstreamServer {
+	return Clone(sus).(*StreamUpstreamServer)
+}
@@ -12,6 +12,8 @@ type Upstream struct {
 	Directives []IDirective
 	Comment    []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -107,6 +109,7 @@ func NewUpstream(directive IDirective) (*Upstream, error) {
 
 // AddServer adds a server to the upstream.
 func (us *Upstream) AddServer(server *UpstreamServer) {
+	server.SetParent(us)
 	us.UpstreamServers = append(us.UpstreamServers, server)
 }
 
@@ -129,3 +132,8 @@ func (us *Upstream) FindDirectives(directiveName string) []IDirective {
 
 	return directives
 }
+
+// Clone returns a deep copy of the upstream block, detached from its parent.
+func (us *Upstream) Clone() *Upstream {
+	return Clone(us).(*Upstream)
+}
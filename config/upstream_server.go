@@ -13,6 +13,8 @@ type UpstreamServer struct {
 	Parameters map[string]string
 	Comment    []string
 	DefaultInlineComment
+	DefaultTrivia
+	DefaultRange
 	Parent IDirective
 	Line   int
 }
@@ -121,6 +123,13 @@ func NewUpstreamServer(directive IDirective) (*UpstreamServer, error) {
 
 	uss.Comment = directive.GetComment()
 	uss.InlineComment = directive.GetInlineComment()
+	uss.Trivia = TriviaOf(directive)
+	uss.Range = RangeOf(directive)
 
 	return uss, nil
 }
+
+// Clone returns a deep copy of the upstream server, detached from its parent.
+func (uss *UpstreamServer) Clone() *UpstreamServer {
+	return Clone(uss).(*UpstreamServer)
+}
@@ -0,0 +1,359 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// VariableKind tells how a variable gets its value
+type VariableKind string
+
+const (
+	// VariableSet is a variable assigned by set or a similar directive like auth_request_set or js_set
+	VariableSet VariableKind = "set"
+	// VariableMap is the target variable of a map block
+	VariableMap VariableKind = "map"
+	// VariableGeo is the target variable of a geo block
+	VariableGeo VariableKind = "geo"
+	// VariableSplitClients is the target variable of a split_clients block
+	VariableSplitClients VariableKind = "split_clients"
+	// VariableCapture is a named capture of a regex in location, server_name, if, rewrite or a map entry
+	VariableCapture VariableKind = "capture"
+)
+
+// assignDirectives are the directives assigning the variable of their first parameter
+var assignDirectives = map[string]bool{
+	"set":               true,
+	"auth_request_set":  true,
+	"js_set":            true,
+	"perl_set":          true,
+	"set_by_lua":        true,
+	"set_by_lua_block":  true,
+	"set_by_lua_file":   true,
+	"set_escape_uri":    true,
+	"set_unescape_uri":  true,
+	"set_md5":           true,
+	"set_secure_random": true,
+}
+
+// builtinVariables are the variables defined by nginx and its bundled modules
+var builtinVariables = map[string]bool{}
+
+// builtinPrefixes are the families of variables named after a header, cookie or argument
+var builtinPrefixes = []string{"arg_", "cookie_", "http_", "sent_http_", "sent_trailer_", "upstream_http_", "upstream_trailer_", "upstream_cookie_", "jwt_claim_", "jwt_header_"}
+
+func init() {
+	for _, name := range strings.Fields(`
+		args binary_remote_addr body_bytes_sent bytes_received bytes_sent connection connection_requests
+		connection_time connections_active connections_reading connections_waiting connections_writing
+		content_length content_type document_root document_uri fastcgi_path_info fastcgi_script_name
+		gzip_ratio host hostname https invalid_referer is_args limit_conn_status limit_rate
+		limit_req_status msec nginx_version pid pipe proxy_add_x_forwarded_for proxy_host proxy_port
+		proxy_protocol_addr proxy_protocol_port proxy_protocol_server_addr proxy_protocol_server_port
+		query_string realip_remote_addr realip_remote_port realpath_root remote_addr remote_port
+		remote_user request request_body request_body_file request_completion request_filename
+		request_id request_length request_method request_time request_uri scheme secure_link
+		secure_link_expires server_addr server_name server_port server_protocol session_time
+		slice_range ssl_alpn_protocol ssl_cipher ssl_ciphers ssl_client_cert ssl_client_escaped_cert
+		ssl_client_fingerprint ssl_client_i_dn ssl_client_i_dn_legacy ssl_client_raw_cert
+		ssl_client_s_dn ssl_client_s_dn_legacy ssl_client_serial ssl_client_v_end ssl_client_v_remain
+		ssl_client_v_start ssl_client_verify ssl_curve ssl_curves ssl_early_data ssl_preread_alpn_protocols
+		ssl_preread_protocol ssl_preread_server_name ssl_protocol ssl_server_name ssl_session_id
+		ssl_session_reused status tcpinfo_rtt tcpinfo_rttvar tcpinfo_snd_cwnd tcpinfo_rcv_space
+		time_iso8601 time_local uid_got uid_reset uid_set upstream_addr upstream_bytes_received
+		upstream_bytes_sent upstream_cache_status upstream_connect_time upstream_first_byte_time
+		upstream_header_time upstream_queue_time upstream_response_length upstream_response_time
+		upstream_session_time upstream_status uri date_gmt date_local fastcgi_script_name
+		geoip_country_code geoip_country_code3 geoip_country_name geoip_city geoip_region
+		geoip_latitude geoip_longitude geoip_org memcached_key modern_browser ancient_browser msie
+		realip_remote_addr spdy http2 http3 quic server_https`) {
+		builtinVariables[name] = true
+	}
+}
+
+// IsBuiltinVariable returns true if nginx defines the variable, name is given without '$'
+func IsBuiltinVariable(name string) bool {
+	name = strings.ToLower(name)
+	if builtinVariables[name] || isNumericCapture(name) {
+		return true
+	}
+	for _, prefix := range builtinPrefixes {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNumericCapture returns true for the numbered captures of the last matched regex, $1 to $9
+func isNumericCapture(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	variableRe     = regexp.MustCompile(`\$(?:\{([A-Za-z0-9_]+)\}|([A-Za-z0-9_]+))`)
+	namedCaptureRe = regexp.MustCompile(`\(\?(?:P?<([A-Za-z_][A-Za-z0-9_]*)>|'([A-Za-z_][A-Za-z0-9_]*)')`)
+)
+
+// VariableNames returns the variables referenced in a parameter value, lower cased and without '$'
+func VariableNames(value string) []string {
+	var names []string
+	for _, m := range variableRe.FindAllStringSubmatch(value, -1) {
+		name := m[1]
+		if name == "" {
+			name = m[2]
+		}
+		names = append(names, strings.ToLower(name))
+	}
+	return names
+}
+
+// captureNames returns the named captures of a regex
+func captureNames(regex string) []string {
+	var names []string
+	for _, m := range namedCaptureRe.FindAllStringSubmatch(regex, -1) {
+		name := m[1]
+		if name == "" {
+			name = m[2]
+		}
+		names = append(names, strings.ToLower(name))
+	}
+	return names
+}
+
+// VariableDefinition is a place giving a value to a variable
+type VariableDefinition struct {
+	Name      string // lower cased, without '$'
+	Kind      VariableKind
+	Directive IDirective // set, map, geo, split_clients, location, server_name, if, rewrite or a map entry
+	Sources   []string   // the variables the value is computed from
+	Context   string
+	File      *Config // the file holding the directive, nil for a tree that was not parsed from a file
+}
+
+// VariableUse is a directive reading a variable
+type VariableUse struct {
+	Name      string // lower cased, without '$'
+	Directive IDirective
+	Defines   string // the variable whose value is computed from this one, empty for other directives
+	Context   string
+	File      *Config
+}
+
+// VariableAnalysis maps the variables of a config to their definitions and uses
+type VariableAnalysis struct {
+	Definitions map[string][]*VariableDefinition // by variable name
+	Uses        map[string][]*VariableUse        // by variable name
+}
+
+// AnalyzeVariables finds where the variables of a config, a block or a directive are
+// defined and used, following includes
+func AnalyzeVariables(root IBlock) *VariableAnalysis {
+	a := &VariableAnalysis{
+		Definitions: make(map[string][]*VariableDefinition),
+		Uses:        make(map[string][]*VariableUse),
+	}
+	_ = Walk(root, VisitorFuncs{EnterFunc: a.visit}, WalkIncludes())
+	return a
+}
+
+// visit records the definitions and uses of a directive
+func (a *VariableAnalysis) visit(c *Cursor) error {
+	d := c.Directive()
+	switch d := d.(type) {
+	case *Map:
+		target := strings.ToLower(strings.TrimPrefix(d.MappedVariable, "$"))
+		sources := a.use(c, d, d.Variable, target)
+		for _, entry := range d.Mappings {
+			for _, name := range captureNames(regexPattern(entry.Pattern)) {
+				a.define(c, entry, name, VariableCapture, nil)
+			}
+			sources = append(sources, a.use(c, entry, entry.Value, target)...)
+		}
+		a.define(c, d, target, VariableMap, sources)
+		return SkipChildren
+	case *Geo:
+		target := strings.ToLower(strings.TrimPrefix(d.Variable, "$"))
+		sources := []string{"remote_addr"}
+		if d.SourceAddress != "" {
+			sources = a.use(c, d, d.SourceAddress, target)
+		}
+		a.define(c, d, target, VariableGeo, sources)
+		return SkipChildren
+	case *SplitClients:
+		target := strings.ToLower(strings.TrimPrefix(d.MappedVariable, "$"))
+		a.define(c, d, target, VariableSplitClients, a.use(c, d, d.Variable, target))
+		return SkipChildren
+	case *Location:
+		if strings.HasPrefix(d.Modifier, "~") {
+			for _, name := range captureNames(d.Match) {
+				a.define(c, d, name, VariableCapture, nil)
+			}
+			return nil
+		}
+	}
+
+	params := d.GetParameters()
+	name := d.GetName()
+	switch {
+	case assignDirectives[name] && len(params) > 0:
+		target := strings.ToLower(strings.TrimPrefix(params[0].Value, "$"))
+		var sources []string
+		for _, param := range params[1:] {
+			sources = append(sources, a.use(c, d, param.Value, target)...)
+		}
+		a.define(c, d, target, VariableSet, sources)
+		return nil
+	case name == "server_name":
+		for _, param := range params {
+			if strings.HasPrefix(param.Value, "~") {
+				for _, capture := range captureNames(param.Value) {
+					a.define(c, d, capture, VariableCapture, nil)
+				}
+			}
+		}
+		return nil
+	case name == "rewrite" && len(params) > 0:
+		for _, capture := range captureNames(params[0].Value) {
+			a.define(c, d, capture, VariableCapture, nil)
+		}
+		params = params[1:]
+	case name == "if":
+		for i, param := range params {
+			if isRegexOperator(param.Value) && i+1 < len(params) {
+				for _, capture := range captureNames(params[i+1].Value) {
+					a.define(c, d, capture, VariableCapture, nil)
+				}
+			}
+		}
+		// a regex of if cannot reference variables, and its '$' is an anchor
+		kept := make([]Parameter, 0, len(params))
+		for i, param := range params {
+			if i == 0 || !isRegexOperator(params[i-1].Value) {
+				kept = append(kept, param)
+			}
+		}
+		params = kept
+	}
+	for _, param := range params {
+		a.use(c, d, param.Value, "")
+	}
+	return nil
+}
+
+// isRegexOperator returns true for the regex match operators of if
+func isRegexOperator(op string) bool {
+	switch op {
+	case "~", "~*", "!~", "!~*":
+		return true
+	}
+	return false
+}
+
+// regexPattern returns the regex of a map pattern, empty if the pattern is not a regex
+func regexPattern(pattern string) string {
+	if strings.HasPrefix(pattern, "~") {
+		return pattern
+	}
+	return ""
+}
+
+// define records a definition of name
+func (a *VariableAnalysis) define(c *Cursor, d IDirective, name string, kind VariableKind, sources []string) {
+	if name == "" {
+		return
+	}
+	a.Definitions[name] = append(a.Definitions[name], &VariableDefinition{
+		Name:      name,
+		Kind:      kind,
+		Directive: d,
+		Sources:   sources,
+		Context:   c.Context(),
+		File:      c.File(),
+	})
+}
+
+// use records the variables referenced by value and returns their names
+func (a *VariableAnalysis) use(c *Cursor, d IDirective, value, defines string) []string {
+	names := VariableNames(value)
+	for _, name := range names {
+		a.Uses[name] = append(a.Uses[name], &VariableUse{
+			Name:      name,
+			Directive: d,
+			Defines:   defines,
+			Context:   c.Context(),
+			File:      c.File(),
+		})
+	}
+	return names
+}
+
+// Undefined returns the uses of variables that are neither defined in the config nor built into nginx
+func (a *VariableAnalysis) Undefined() []*VariableUse {
+	var undefined []*VariableUse
+	for _, name := range sortedKeys(a.Uses) {
+		if len(a.Definitions[name]) == 0 && !IsBuiltinVariable(name) {
+			undefined = append(undefined, a.Uses[name]...)
+		}
+	}
+	return undefined
+}
+
+// Unused returns the definitions of variables that are never used
+func (a *VariableAnalysis) Unused() []*VariableDefinition {
+	var unused []*VariableDefinition
+	for _, name := range sortedKeys(a.Definitions) {
+		if len(a.Uses[name]) == 0 {
+			unused = append(unused, a.Definitions[name]...)
+		}
+	}
+	return unused
+}
+
+// VariableFlow is a variable with the directives reading it and the variables computed from it
+type VariableFlow struct {
+	Name    string
+	Uses    []*VariableUse  // the directives reading the variable, other than definitions
+	Derived []*VariableFlow // the variables whose definition reads this one, like the target of a map
+}
+
+// Flow returns how the value of a variable spreads through the config, for example from
+// the source variable of a map to the directives reading its target variable.
+// A variable that takes part in a cycle is listed once.
+func (a *VariableAnalysis) Flow(name string) *VariableFlow {
+	return a.flow(strings.ToLower(strings.TrimPrefix(name, "$")), map[string]bool{})
+}
+
+func (a *VariableAnalysis) flow(name string, seen map[string]bool) *VariableFlow {
+	seen[name] = true
+	f := &VariableFlow{Name: name}
+	for _, use := range a.Uses[name] {
+		if use.Defines == "" {
+			f.Uses = append(f.Uses, use)
+			continue
+		}
+		if !seen[use.Defines] {
+			f.Derived = append(f.Derived, a.flow(use.Defines, seen))
+		}
+	}
+	return f
+}
+
+// sortedKeys returns the keys of a map sorted
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
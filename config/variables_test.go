@@ -0,0 +1,92 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+func TestAnalyzeVariables(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(`http {
+    map $http_host $backend {
+        ~^(?<tenant>[a-z]+)\.example\.com$ $tenant;
+        default fallback;
+    }
+    map $backend $pool {
+        default $backend;
+    }
+    geo $client_net {
+        default external;
+        10.0.0.0/8 internal;
+    }
+    split_clients "${remote_addr}salt" $variant {
+        50% a;
+        * b;
+    }
+    server {
+        server_name ~^(?<sub>\w+)\.example\.com$;
+        set $unused_flag 1;
+        location ~ ^/api/(?<version>v[0-9]+)/ {
+            proxy_pass http://$pool/$version/$1;
+            add_header X-Variant $variant;
+            if ($request_uri ~* "^/old(?P<rest>.*)$") {
+                return 301 /new$rest;
+            }
+        }
+        location / {
+            return 200 "$missing ${Client_Net}";
+        }
+    }
+}`).Parse()
+	assert.NilError(t, err)
+	a := config.AnalyzeVariables(conf)
+
+	backend := a.Definitions["backend"]
+	assert.Equal(t, len(backend), 1)
+	assert.Equal(t, backend[0].Kind, config.VariableMap)
+	assert.DeepEqual(t, backend[0].Sources, []string{"http_host", "tenant"})
+	assert.Equal(t, backend[0].Context, "http")
+	assert.Equal(t, a.Definitions["tenant"][0].Kind, config.VariableCapture)
+	assert.Equal(t, a.Definitions["client_net"][0].Kind, config.VariableGeo)
+	assert.DeepEqual(t, a.Definitions["variant"][0].Sources, []string{"remote_addr"})
+	assert.Equal(t, a.Definitions["version"][0].Directive.GetName(), "location")
+	assert.Equal(t, a.Definitions["rest"][0].Directive.GetName(), "if")
+	assert.Equal(t, a.Definitions["unused_flag"][0].Kind, config.VariableSet)
+
+	undefined := a.Undefined()
+	assert.Equal(t, len(undefined), 1)
+	assert.Equal(t, undefined[0].Name, "missing")
+	assert.Equal(t, undefined[0].Context, "location")
+
+	var unused []string
+	for _, d := range a.Unused() {
+		unused = append(unused, d.Name)
+	}
+	assert.DeepEqual(t, unused, []string{"sub", "unused_flag"})
+
+	// $http_host feeds $backend, which feeds $pool, which proxy_pass reads
+	flow := a.Flow("$http_host")
+	assert.Equal(t, len(flow.Uses), 0)
+	assert.Equal(t, len(flow.Derived), 1)
+	assert.Equal(t, flow.Derived[0].Name, "backend")
+	pool := flow.Derived[0].Derived[0]
+	assert.Equal(t, pool.Name, "pool")
+	assert.Equal(t, len(pool.Uses), 1)
+	assert.Equal(t, pool.Uses[0].Directive.GetName(), "proxy_pass")
+}
+
+func TestIsBuiltinVariable(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"remote_addr", "Request_URI", "http_x_forwarded_for", "cookie_session", "arg_page", "1"} {
+		assert.Assert(t, config.IsBuiltinVariable(name), name)
+	}
+	for _, name := range []string{"backend", "http_", "cookie"} {
+		assert.Assert(t, !config.IsBuiltinVariable(name), name)
+	}
+	assert.DeepEqual(t, config.VariableNames(`"$scheme://${Host}$request_uri"`), []string{"scheme", "host", "request_uri"})
+}
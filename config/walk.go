@@ -0,0 +1,346 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// SkipChildren is returned by Visitor.Enter to skip the children of the directive
+	SkipChildren = errors.New("skip children")
+	// SkipAll is returned by a Visitor to stop the walk without an error
+	SkipAll = errors.New("skip all")
+)
+
+// Visitor receives the directives visited by Walk
+type Visitor interface {
+	// Enter is called before the children of a directive are visited.
+	// Returning SkipChildren skips them, any other error stops the walk
+	Enter(c *Cursor) error
+	// Leave is called after the children of a directive were visited
+	Leave(c *Cursor) error
+}
+
+// VisitorFuncs adapts functions to a Visitor, a nil function does nothing
+type VisitorFuncs struct {
+	EnterFunc func(c *Cursor) error
+	LeaveFunc func(c *Cursor) error
+}
+
+// Enter calls EnterFunc
+func (v VisitorFuncs) Enter(c *Cursor) error {
+	if v.EnterFunc == nil {
+		return nil
+	}
+	return v.EnterFunc(c)
+}
+
+// Leave calls LeaveFunc
+func (v VisitorFuncs) Leave(c *Cursor) error {
+	if v.LeaveFunc == nil {
+		return nil
+	}
+	return v.LeaveFunc(c)
+}
+
+// WalkOption configures Walk
+type WalkOption func(*walker)
+
+// WalkIncludes makes Walk descend into the resolved Configs of include directives,
+// their directives are visited as children of the include
+func WalkIncludes() WalkOption {
+	return func(w *walker) {
+		w.includes = true
+	}
+}
+
+// WalkInContext sets the context of the walked block, "main" by default
+func WalkInContext(context string) WalkOption {
+	return func(w *walker) {
+		w.context = context
+	}
+}
+
+// Cursor describes the directive being visited and allows replacing or deleting it
+type Cursor struct {
+	directive IDirective
+	owner     IDirective // directive holding the block, the include for the top level of included files
+	block     IBlock
+	index     int
+	contexts  []string
+	parents   []IDirective
+	origin    Origin
+	deleted   bool
+	replaced  bool
+}
+
+// Directive returns the visited directive, or its replacement
+func (c *Cursor) Directive() IDirective {
+	return c.directive
+}
+
+// Parent returns the directive enclosing the visited one, nil at the top level
+func (c *Cursor) Parent() IDirective {
+	if len(c.parents) == 0 {
+		return nil
+	}
+	return c.parents[len(c.parents)-1]
+}
+
+// Parents returns the enclosing block directives, outermost first
+func (c *Cursor) Parents() []IDirective {
+	return append([]IDirective(nil), c.parents...)
+}
+
+// Block returns the block holding the visited directive
+func (c *Cursor) Block() IBlock {
+	return c.block
+}
+
+// Index returns the position of the visited directive in its block before any change
+func (c *Cursor) Index() int {
+	return c.index
+}
+
+// Context returns the context the directive appears in, like "http", "server" or "stream_upstream"
+func (c *Cursor) Context() string {
+	return c.contexts[len(c.contexts)-1]
+}
+
+// Contexts returns the context stack, outermost first, starting with "main"
+func (c *Cursor) Contexts() []string {
+	return append([]string(nil), c.contexts...)
+}
+
+// Include returns the include directive through which the directive was reached, if any
+func (c *Cursor) Include() *Include {
+	if len(c.origin.Includes) == 0 {
+		return nil
+	}
+	return c.origin.Includes[len(c.origin.Includes)-1]
+}
+
+// File returns the config the directive was parsed from, nil when the walk did not start at a Config
+func (c *Cursor) File() *Config {
+	return c.origin.File
+}
+
+// Origin returns the file of the directive and the include chain leading to it
+func (c *Cursor) Origin() Origin {
+	return Origin{File: c.origin.File, Includes: append([]*Include(nil), c.origin.Includes...)}
+}
+
+// Replace replaces the visited directive in its block. When called from Enter
+// the children of the replacement are visited instead
+func (c *Cursor) Replace(directive IDirective) {
+	if directive == nil {
+		c.Delete()
+		return
+	}
+	if c.owner != nil {
+		directive.SetParent(c.owner)
+	}
+	c.directive = directive
+	c.replaced = true
+}
+
+// Delete removes the visited directive from its block. When called from Enter
+// its children are not visited and Leave is not called
+func (c *Cursor) Delete() {
+	c.deleted = true
+}
+
+// Walk visits the directives of node depth-first in document order, calling
+// Enter before and Leave after the children of each directive. Directives can
+// be replaced or deleted through the Cursor, the blocks are updated once all
+// their directives were visited. Walk returns the first error of the Visitor
+// other than SkipChildren and SkipAll.
+func Walk(node IBlock, v Visitor, opts ...WalkOption) error {
+	w := &walker{visitor: v, context: "main"}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if node == nil {
+		return nil
+	}
+	var origin Origin
+	if conf, ok := node.(*Config); ok {
+		origin.File = conf
+	}
+	_, owner := resolveBlock(node)
+	err := w.walkBlock(owner, node, []string{w.context}, nil, origin)
+	if err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+type walker struct {
+	visitor  Visitor
+	includes bool
+	context  string
+}
+
+// walkBlock visits the directives of block, owner is the directive holding it
+func (w *walker) walkBlock(owner IDirective, block IBlock, contexts []string, parents []IDirective, origin Origin) error {
+	directives := block.GetDirectives()
+	kept := make([]IDirective, 0, len(directives))
+	changed := false
+
+	var err error
+	for i, d := range directives {
+		if err != nil {
+			// the walk was stopped, keep the remaining directives
+			kept = append(kept, d)
+			continue
+		}
+		c := &Cursor{directive: d, owner: owner, block: block, index: i, contexts: contexts, parents: parents, origin: origin}
+		err = w.walkDirective(c)
+		if c.replaced || c.deleted {
+			changed = true
+		}
+		if !c.deleted {
+			kept = append(kept, c.directive)
+		}
+	}
+
+	if changed {
+		if serr := setDirectives(owner, block, kept); serr != nil {
+			return serr
+		}
+	}
+	return err
+}
+
+// walkDirective calls the visitor around the children of the cursor directive
+func (w *walker) walkDirective(c *Cursor) error {
+	err := w.visitor.Enter(c)
+	if c.deleted || (err != nil && err != SkipChildren) {
+		return err
+	}
+	if err != SkipChildren {
+		d := c.directive
+		parents := append(append([]IDirective(nil), c.parents...), d)
+		if block := d.GetBlock(); block != nil {
+			contexts := append(append([]string(nil), c.contexts...), blockContext(c.Context(), d.GetName()))
+			if err := w.walkBlock(d, block, contexts, parents, c.origin); err != nil {
+				return err
+			}
+		}
+		if include, ok := d.(*Include); ok && w.includes {
+			for _, conf := range include.Configs {
+				origin := Origin{File: conf, Includes: append(append([]*Include(nil), c.origin.Includes...), include)}
+				if err := w.walkBlock(include, conf, c.contexts, c.parents, origin); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := w.visitor.Leave(c); err != SkipChildren {
+		return err
+	}
+	return nil
+}
+
+// blockContext returns the context of the block of a directive found in context
+func blockContext(context, name string) string {
+	switch {
+	case name == "server" && context == "stream":
+		return "stream_server"
+	case name == "upstream" && context == "stream":
+		return "stream_upstream"
+	}
+	return name
+}
+
+// setDirectives stores the directives of a block, keeping the typed fields of the wrappers in sync
+func setDirectives(owner IDirective, block IBlock, directives []IDirective) error {
+	switch b := block.(type) {
+	case *Config:
+		b.Block.Directives = directives
+	case *Block:
+		b.Directives = directives
+		if su, ok := owner.(*StreamUpstream); ok && su.Block == b {
+			su.Servers = nil
+			for _, d := range directives {
+				if server, ok := d.(*StreamUpstreamServer); ok {
+					su.Servers = append(su.Servers, server)
+				}
+			}
+		}
+	case *HTTP:
+		b.Servers, b.Directives = []*Server{}, []IDirective{}
+		for _, d := range directives {
+			if server, ok := d.(*Server); ok {
+				b.Servers = append(b.Servers, server)
+				continue
+			}
+			b.Directives = append(b.Directives, d)
+		}
+	case *Upstream:
+		b.UpstreamServers, b.Directives = nil, nil
+		for _, d := range directives {
+			if server, ok := d.(*UpstreamServer); ok {
+				b.UpstreamServers = append(b.UpstreamServers, server)
+				continue
+			}
+			b.Directives = append(b.Directives, d)
+		}
+	case *LuaBlock:
+		b.Directives = directives
+	case *Map:
+		mappings := make([]*MapEntry, 0, len(directives))
+		for _, d := range directives {
+			entry, ok := d.(*MapEntry)
+			if !ok {
+				return fmt.Errorf("map block can only hold map entries, not %T", d)
+			}
+			mappings = append(mappings, entry)
+		}
+		b.Mappings = mappings
+	case *SplitClients:
+		entries := make([]*SplitClientsEntry, 0, len(directives))
+		for _, d := range directives {
+			entry, ok := d.(*SplitClientsEntry)
+			if !ok {
+				return fmt.Errorf("split_clients block can only hold split_clients entries, not %T", d)
+			}
+			entries = append(entries, entry)
+		}
+		b.Entries = entries
+	case *Geo:
+		return b.setEntries(directives)
+	default:
+		return fmt.Errorf("cannot change the directives of %T", block)
+	}
+	return nil
+}
+
+// setEntries rebuilds the geo settings and entries from the directives returned by GetDirectives
+func (g *Geo) setEntries(directives []IDirective) error {
+	for _, d := range directives {
+		if _, ok := d.(*GeoEntry); !ok {
+			return fmt.Errorf("geo block can only hold geo entries, not %T", d)
+		}
+	}
+	g.DefaultValue, g.Ranges, g.ProxyRecursive = "", false, false
+	g.Delete, g.Proxy, g.Entries = nil, nil, nil
+	for _, d := range directives {
+		entry := d.(*GeoEntry)
+		switch entry.Network {
+		case "default":
+			g.DefaultValue = entry.Value
+		case "ranges":
+			g.Ranges = true
+		case "proxy_recursive":
+			g.ProxyRecursive = true
+		case "delete":
+			g.Delete = append(g.Delete, entry.Value)
+		case "proxy":
+			g.Proxy = append(g.Proxy, entry.Value)
+		default:
+			g.Entries = append(g.Entries, entry)
+		}
+	}
+	return nil
+}
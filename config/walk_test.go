@@ -0,0 +1,286 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+const walkConfig = `user nginx;
+http {
+	upstream backend {
+		server 127.0.0.1:8080;
+		keepalive 8;
+	}
+	server {
+		listen 80;
+		location / {
+			proxy_pass http://backend;
+		}
+	}
+}
+stream {
+	upstream tcp {
+		server 10.0.0.1:53;
+	}
+	server {
+		listen 53;
+	}
+}
+`
+
+func TestWalk_Order(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(walkConfig).Parse()
+	assert.NilError(t, err)
+
+	var events []string
+	err = config.Walk(conf, config.VisitorFuncs{
+		EnterFunc: func(c *config.Cursor) error {
+			events = append(events, "+"+c.Directive().GetName()+"@"+strings.Join(c.Contexts(), "/"))
+			return nil
+		},
+		LeaveFunc: func(c *config.Cursor) error {
+			events = append(events, "-"+c.Directive().GetName())
+			return nil
+		},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, events, []string{
+		"+user@main", "-user",
+		"+http@main",
+		"+upstream@main/http",
+		"+keepalive@main/http/upstream", "-keepalive",
+		"+server@main/http/upstream", "-server",
+		"-upstream",
+		"+server@main/http",
+		"+listen@main/http/server", "-listen",
+		"+location@main/http/server",
+		"+proxy_pass@main/http/server/location", "-proxy_pass",
+		"-location",
+		"-server",
+		"-http",
+		"+stream@main",
+		"+upstream@main/stream",
+		"+server@main/stream/stream_upstream", "-server",
+		"-upstream",
+		"+server@main/stream",
+		"+listen@main/stream/stream_server", "-listen",
+		"-server",
+		"-stream",
+	})
+}
+
+func TestWalk_SkipAndStop(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(walkConfig).Parse()
+	assert.NilError(t, err)
+
+	var names []string
+	err = config.Walk(conf, config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		names = append(names, c.Directive().GetName())
+		if c.Directive().GetName() == "upstream" {
+			return config.SkipChildren
+		}
+		if c.Directive().GetName() == "location" {
+			return config.SkipAll
+		}
+		return nil
+	}})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names, []string{"user", "http", "upstream", "server", "listen", "location"})
+
+	boom := errors.New("boom")
+	err = config.Walk(conf, config.VisitorFuncs{LeaveFunc: func(c *config.Cursor) error {
+		if c.Directive().GetName() == "listen" {
+			return boom
+		}
+		return nil
+	}})
+	assert.Equal(t, err, boom)
+}
+
+func TestWalk_ReplaceAndDelete(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(walkConfig).Parse()
+	assert.NilError(t, err)
+
+	err = config.Walk(conf, config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		switch c.Directive().GetName() {
+		case "keepalive", "user":
+			c.Delete()
+		case "listen":
+			if c.Context() == "server" {
+				c.Replace(&config.Directive{Name: "listen", Parameters: []config.Parameter{{Value: "8080"}}})
+			}
+		case "location":
+			// the children of the replacement are walked
+			c.Replace(&config.Directive{
+				Name:       "location",
+				Parameters: []config.Parameter{{Value: "/api"}},
+				Block: &config.Block{Directives: []config.IDirective{
+					&config.Directive{Name: "return", Parameters: []config.Parameter{{Value: "204"}}},
+					&config.Directive{Name: "deny", Parameters: []config.Parameter{{Value: "all"}}},
+				}},
+			})
+		case "deny":
+			c.Delete()
+		}
+		return nil
+	}})
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(conf.GetDirectives()), 2)
+	assert.Equal(t, dumper.DumpDirective(conf.FindDirectives("http")[0], dumper.IndentedStyle), `http {
+    upstream backend {
+        server 127.0.0.1:8080;
+    }
+    server {
+        listen 8080;
+        location /api {
+            return 204;
+        }
+    }
+}`)
+
+	// the typed wrappers are kept in sync
+	upstream := conf.FindDirectives("upstream")[0].(*config.Upstream)
+	assert.Equal(t, len(upstream.Directives), 0)
+	assert.Equal(t, len(upstream.UpstreamServers), 1)
+	listen := conf.FindDirectives("listen")[0]
+	assert.Equal(t, listen.GetParent().GetName(), "server")
+}
+
+func TestWalk_ReplaceHTTPServer(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser("http {\n    gzip on;\n    server {\n        listen 80;\n    }\n}\n").Parse()
+	assert.NilError(t, err)
+	http := conf.FindDirectives("http")[0].(*config.HTTP)
+
+	err = config.Walk(conf, config.VisitorFuncs{LeaveFunc: func(c *config.Cursor) error {
+		if c.Directive().GetName() == "gzip" {
+			server, err := config.NewServer(&config.Directive{Name: "server", Block: &config.Block{}})
+			assert.NilError(t, err)
+			c.Replace(server)
+		}
+		return nil
+	}})
+	assert.NilError(t, err)
+	assert.Equal(t, len(http.Servers), 2)
+	assert.Equal(t, len(http.Directives), 0)
+	assert.Equal(t, http.Servers[0].GetParent(), config.IDirective(http))
+}
+
+func TestWalk_Includes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	included := filepath.Join(dir, "site.conf")
+	assert.NilError(t, os.WriteFile(included, []byte("server {\n    listen 80;\n}\n"), 0o644))
+
+	conf, err := parser.NewStringParser("http {\n    include "+included+";\n}\n", parser.WithIncludeParsing()).Parse()
+	assert.NilError(t, err)
+
+	var names []string
+	visit := config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		name := c.Directive().GetName() + "@" + c.Context()
+		if c.Include() != nil {
+			name += "<" + filepath.Base(c.Include().IncludePath)
+		}
+		names = append(names, name)
+		return nil
+	}}
+
+	assert.NilError(t, config.Walk(conf, visit))
+	assert.DeepEqual(t, names, []string{"http@main", "include@http"})
+
+	names = nil
+	assert.NilError(t, config.Walk(conf, visit, config.WalkIncludes()))
+	assert.DeepEqual(t, names, []string{"http@main", "include@http", "server@http<site.conf", "listen@server<site.conf"})
+
+	// deleting inside an included file changes that file only
+	assert.NilError(t, config.Walk(conf, config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		if c.Directive().GetName() == "server" {
+			c.Delete()
+		}
+		return nil
+	}}, config.WalkIncludes()))
+	include := conf.FindDirectives("include")[0].(*config.Include)
+	assert.Equal(t, len(include.Configs[0].GetDirectives()), 0)
+	assert.Equal(t, len(conf.FindDirectives("include")), 1)
+}
+
+func TestWalk_InContext(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(walkConfig).Parse()
+	assert.NilError(t, err)
+	location := conf.FindDirectives("location")[0]
+
+	var contexts []string
+	assert.NilError(t, config.Walk(location.GetBlock(), config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		contexts = append(contexts, c.Context())
+		return nil
+	}}, config.WalkInContext("location")))
+	assert.DeepEqual(t, contexts, []string{"location"})
+}
+
+func TestWalk_EntryBlocks(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser(`http {
+	map $host $backend {
+		default a;
+		example.com b;
+	}
+	geo $country {
+		default ZZ;
+		proxy 10.0.0.1;
+		127.0.0.0/8 LO;
+	}
+}`).Parse()
+	assert.NilError(t, err)
+
+	err = config.Walk(conf, config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		switch d := c.Directive().(type) {
+		case *config.MapEntry:
+			if d.Pattern == "default" {
+				c.Delete()
+			}
+		case *config.GeoEntry:
+			if d.Network == "proxy" {
+				c.Delete()
+			}
+		}
+		return nil
+	}})
+	assert.NilError(t, err)
+
+	m := conf.FindMaps()[0]
+	assert.Equal(t, len(m.Mappings), 1)
+	assert.Equal(t, m.Mappings[0].Pattern, "example.com")
+	g := conf.FindGeos()[0]
+	assert.Equal(t, g.DefaultValue, "ZZ")
+	assert.Equal(t, len(g.Proxy), 0)
+	assert.Equal(t, len(g.Entries), 1)
+
+	// only entries fit in a map block
+	err = config.Walk(conf, config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		if _, ok := c.Directive().(*config.MapEntry); ok {
+			c.Replace(&config.Directive{Name: "gzip"})
+		}
+		return nil
+	}})
+	assert.ErrorContains(t, err, "map block can only hold map entries")
+}
@@ -0,0 +1,32 @@
+package dumper
+
+import (
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// DumpConflict converts a merge conflict to a string. With Style.ConflictMarkers both sides are
+// written between comment markers like those of git, a side that removed the directive is
+// empty; otherwise only the directive in effect is written.
+func DumpConflict(c *config.Conflict, style *Style) string {
+	if c == nil {
+		return ""
+	}
+	if !style.ConflictMarkers {
+		return DumpDirective(c.IDirective, style)
+	}
+
+	indent := strings.Repeat(" ", style.StartIndent)
+	var buf strings.Builder
+	buf.WriteString(indent + "# <<<<<<< ours " + c.Path + "\n")
+	if c.Ours != nil {
+		buf.WriteString(DumpDirective(c.Ours, style) + "\n")
+	}
+	buf.WriteString(indent + "# =======\n")
+	if c.Theirs != nil {
+		buf.WriteString(DumpDirective(c.Theirs, style) + "\n")
+	}
+	buf.WriteString(indent + "# >>>>>>> theirs")
+	return buf.String()
+}
@@ -0,0 +1,31 @@
+package dumper_test
+
+import (
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"gotest.tools/v3/assert"
+)
+
+func TestDumpConflict(t *testing.T) {
+	t.Parallel()
+	ours := &config.Directive{Name: "root", Parameters: []config.Parameter{{Value: "/a"}}}
+	theirs := &config.Directive{Name: "root", Parameters: []config.Parameter{{Value: "/b"}}}
+	conflict := config.NewConflict("server/root", ours, theirs)
+
+	// without markers the directive of ours is in effect
+	assert.Equal(t, dumper.DumpConflict(conflict, dumper.IndentedStyle), "root /a;")
+
+	style := dumper.NewStyle()
+	style.ConflictMarkers = true
+	assert.Equal(t, dumper.DumpConflict(conflict, style),
+		"# <<<<<<< ours server/root\nroot /a;\n# =======\nroot /b;\n# >>>>>>> theirs")
+
+	// a side that removed the directive is empty
+	conflict = config.NewConflict("server/root", nil, theirs)
+	assert.Equal(t, dumper.DumpConflict(conflict, dumper.IndentedStyle), "root /b;")
+	assert.Equal(t, dumper.DumpConflict(conflict, style),
+		"# <<<<<<< ours server/root\n# =======\nroot /b;\n# >>>>>>> theirs")
+	assert.Equal(t, dumper.DumpConflict(nil, style), "")
+}
@@ -2,9 +2,8 @@ package dumper
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
@@ -53,6 +52,8 @@ type Style struct {
 	StartIndent       int
 	Indent            int
 	Debug             bool
+	Lossless          bool // write unmodified directives exactly as they were parsed
+	ConflictMarkers   bool // write both sides of merge conflicts between comment markers
 }
 
 // NewStyle create new style
@@ -73,6 +74,8 @@ func (s *Style) Iterate() *Style {
 		SpaceBeforeBlocks: s.SpaceBeforeBlocks,
 		StartIndent:       s.StartIndent + s.Indent,
 		Indent:            s.Indent,
+		Lossless:          s.Lossless,
+		ConflictMarkers:   s.ConflictMarkers,
 	}
 	return newStyle
 }
@@ -84,6 +87,9 @@ func DumpDirective(d config.IDirective, style *Style) string {
 	}
 
 	// Handle special directive types
+	if conflict, ok := d.(*config.Conflict); ok {
+		return DumpConflict(conflict, style)
+	}
 	if mapBlock, ok := d.(*config.Map); ok {
 		return DumpMap(mapBlock, style)
 	}
@@ -199,6 +205,9 @@ func DumpBlock(b config.IBlock, style *Style) string {
 
 // DumpConfig dump whole config
 func DumpConfig(c *config.Config, style *Style) string {
+	if style.Lossless {
+		return dumpConfigLossless(c, style)
+	}
 	return DumpBlock(c.Block, style)
 }
 
@@ -211,41 +220,30 @@ func DumpInclude(i *config.Include, style *Style) map[string]string {
 	return mp
 }
 
-// WriteConfig writes config
+// WriteConfig writes the config to its file, and with writeInclude the files of its
+// include directives. Only the files that changed since they were parsed or last
+// written are touched, see ChangeSet and WriteChanges.
 func WriteConfig(c *config.Config, style *Style, writeInclude bool) error {
-	if writeInclude {
-		includes := c.FindDirectives("include")
-		for _, include := range includes {
-			i, ok := include.(*config.Include)
-			if !ok {
-				panic("bug in FindDirective")
-			}
-
-			// no config parsed
-			if len(i.Configs) == 0 {
-				continue
-			}
-
-			mp := DumpInclude(i, style)
-			for path, config := range mp {
-				// create parent directories, if not exit
-				dir, _ := filepath.Split(path)
-				err := os.MkdirAll(dir, 0755)
-				if err != nil {
-					return err
-				}
-				err = os.WriteFile(path, []byte(config), 0644)
-				if err != nil {
-					return err
-				}
-			}
+	for _, cfg := range changedConfigs(nil, c, writeInclude) {
+		if err := writeFileAtomic(cfg.FilePath, []byte(DumpConfig(cfg, style))); err != nil {
+			return err
 		}
+		cfg.Snapshot()
 	}
-	// create parent directories, if not exit
-	dir, _ := filepath.Split(c.FilePath)
-	err := os.MkdirAll(dir, 0755)
-	if err != nil {
-		return err
+	return nil
+}
+
+// WriteConfigFS is WriteConfig for a config read from a file system, see parser.WithFS.
+// The files are written to fsys, absolute paths from its root.
+func WriteConfigFS(fsys WritableFS, c *config.Config, style *Style, writeInclude bool) error {
+	for _, cfg := range changedConfigs(fsys, c, writeInclude) {
+		if cfg.FilePath == "" {
+			return errors.New("config has no file path")
+		}
+		if err := fsys.WriteFile(config.FSName(cfg.FilePath), []byte(DumpConfig(cfg, style))); err != nil {
+			return err
+		}
+		cfg.Snapshot()
 	}
-	return os.WriteFile(c.FilePath, []byte(DumpConfig(c, style)), 0644)
+	return nil
 }
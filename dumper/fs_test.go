@@ -0,0 +1,72 @@
+package dumper_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+// mapFS is an in-memory dumper.WritableFS
+type mapFS struct {
+	fstest.MapFS
+}
+
+func (m mapFS) WriteFile(name string, data []byte) error {
+	m.MapFS[name] = &fstest.MapFile{Data: data, Mode: 0o644}
+	return nil
+}
+
+func TestWriteConfigFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := mapFS{fstest.MapFS{
+		"nginx.conf":     {Data: []byte("http {\n    include conf.d/*.conf;\n}\n")},
+		"conf.d/a.conf":  {Data: []byte("server {\n    listen 80;\n}\n")},
+		"conf.d/b.conf":  {Data: []byte("server {\n    listen 81;\n}\n")},
+		"unrelated.conf": {Data: []byte("gzip on;\n")},
+	}}
+	p, err := parser.NewParser("nginx.conf", parser.WithFS(fsys), parser.WithIncludeParsing())
+	assert.NilError(t, err)
+	conf, err := p.Parse()
+	assert.NilError(t, err)
+
+	conf.FindDirectives("listen")[1].(*config.Directive).Parameters[0].Value = "8081"
+	changes, err := dumper.ChangeSetFS(fsys, conf, dumper.IndentedStyle, true)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes, map[string]string{"conf.d/b.conf": "server {\n    listen 8081;\n}"})
+
+	assert.NilError(t, dumper.WriteConfigFS(fsys, conf, dumper.IndentedStyle, true))
+	assert.Equal(t, string(fsys.MapFS["conf.d/b.conf"].Data), "server {\n    listen 8081;\n}")
+	assert.Equal(t, string(fsys.MapFS["conf.d/a.conf"].Data), "server {\n    listen 80;\n}\n")
+	changes, err = dumper.ChangeSetFS(fsys, conf, dumper.IndentedStyle, true)
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+
+	// a new config is written from the root of the file system
+	conf.FilePath = "/sites/new.conf"
+	assert.NilError(t, dumper.WriteConfigFS(fsys, conf, dumper.IndentedStyle, false))
+	assert.Assert(t, fsys.MapFS["sites/new.conf"] != nil)
+}
+
+func TestDirFS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fsys := dumper.DirFS(dir)
+	assert.NilError(t, dumper.WriteChangesFS(fsys, map[string]string{"/conf.d/a.conf": "gzip on;"}))
+	content, err := os.ReadFile(filepath.Join(dir, "conf.d", "a.conf"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "gzip on;")
+
+	p, err := parser.NewParser("conf.d/a.conf", parser.WithFS(fsys))
+	assert.NilError(t, err)
+	_, err = p.Parse()
+	assert.NilError(t, err)
+	assert.ErrorContains(t, fsys.WriteFile("../escape.conf", nil), "invalid argument")
+}
@@ -0,0 +1,170 @@
+package dumper
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// LosslessStyle writes directives that were not modified exactly as they were parsed,
+// the config must be parsed with parser.WithPreserveTrivia
+var LosslessStyle = &Style{
+	Lossless: true,
+	Indent:   4,
+}
+
+// dumpConfigLossless writes untouched regions of the config byte-for-byte and
+// re-renders only the directives that changed since they were parsed
+func dumpConfigLossless(c *config.Config, style *Style) string {
+	var buf bytes.Buffer
+	dumpBlockLossless(&buf, c.Block, "", style)
+	buf.WriteString(c.Trailing)
+	return buf.String()
+}
+
+// dumpBlockLossless writes the children of a block, each one preceded by its leading whitespace
+func dumpBlockLossless(buf *bytes.Buffer, b config.IBlock, fallbackIndent string, style *Style) {
+	directives := sourceOrder(b)
+	indent := childIndent(directives, fallbackIndent)
+	for _, directive := range directives {
+		if trivia := config.TriviaOf(directive); trivia != nil {
+			buf.WriteString(trivia.Leading)
+		} else {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString(indent)
+		}
+		dumpDirectiveLossless(buf, directive, indent, style)
+	}
+}
+
+// dumpDirectiveLossless writes a directive whose leading whitespace is already written
+func dumpDirectiveLossless(buf *bytes.Buffer, d config.IDirective, indent string, style *Style) {
+	trivia := config.TriviaOf(d)
+	if trivia != nil && trivia.Unchanged(d) {
+		buf.WriteString(trivia.Text)
+		return
+	}
+
+	block := d.GetBlock()
+	if trivia == nil || trivia.Header == "" || block == nil || block.GetCodeBlock() != "" {
+		if trivia != nil && block == nil && hasParameterTrivia(d) {
+			buf.WriteString(dumpStatement(d, indent) + ";")
+			writeInlineComments(buf, d)
+			return
+		}
+		buf.WriteString(reindent(DumpDirective(d, &Style{Indent: style.Indent, ConflictMarkers: style.ConflictMarkers}), indent))
+		return
+	}
+
+	if trivia.HeaderUnchanged(d) {
+		buf.WriteString(trivia.Header)
+	} else {
+		buf.WriteString(dumpStatement(d, indent) + " {")
+		writeInlineComments(buf, d)
+	}
+	dumpBlockLossless(buf, block, indent+strings.Repeat(" ", style.Indent), style)
+	buf.WriteString(trivia.Closing)
+}
+
+// dumpStatement renders the comments, name and parameters of a modified directive,
+// using the original whitespace in front of the parameters when it is known
+func dumpStatement(d config.IDirective, indent string) string {
+	var buf bytes.Buffer
+	for _, comment := range d.GetComment() {
+		buf.WriteString(comment + "\n" + indent)
+	}
+	buf.WriteString(d.GetName())
+	for _, parameter := range d.GetParameters() {
+		if parameter.Leading != "" {
+			buf.WriteString(parameter.Leading)
+		} else {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(parameter.GetValue())
+	}
+	return buf.String()
+}
+
+// writeInlineComments writes the inline comments of a modified directive after the
+// whitespace they had when parsed, a single space for comments added later
+func writeInlineComments(buf *bytes.Buffer, d config.IDirective) {
+	for _, comment := range d.GetInlineComment() {
+		if comment.Leading != "" {
+			buf.WriteString(comment.Leading)
+		} else {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(comment.Value)
+	}
+}
+
+// hasParameterTrivia reports whether the parameters of a directive still carry their original whitespace
+func hasParameterTrivia(d config.IDirective) bool {
+	for _, parameter := range d.GetParameters() {
+		if parameter.Leading != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceOrder returns the children of a block; http and upstream keep servers
+// in their own slice, so their children are put back in the order they were written
+func sourceOrder(b config.IBlock) []config.IDirective {
+	directives := b.GetDirectives()
+	switch b.(type) {
+	case *config.HTTP, *config.Upstream:
+	default:
+		return directives
+	}
+
+	// directives added after parsing stay behind the directive that precedes them
+	keys := make([]int, len(directives))
+	key := -1
+	for i, directive := range directives {
+		if trivia := config.TriviaOf(directive); trivia != nil {
+			key = trivia.Offset
+		}
+		keys[i] = key
+	}
+	ordered := make([]config.IDirective, len(directives))
+	copy(ordered, directives)
+	index := make(map[config.IDirective]int, len(directives))
+	for i, directive := range directives {
+		index[directive] = i
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return keys[index[ordered[i]]] < keys[index[ordered[j]]]
+	})
+	return ordered
+}
+
+// childIndent guesses the indentation of a block's children from the first one that kept its trivia
+func childIndent(directives []config.IDirective, fallback string) string {
+	for _, directive := range directives {
+		trivia := config.TriviaOf(directive)
+		if trivia == nil {
+			continue
+		}
+		leading := trivia.Leading
+		if i := strings.LastIndexAny(leading, "\r\n"); i >= 0 {
+			return leading[i+1:]
+		}
+	}
+	return fallback
+}
+
+// reindent indents every line but the first one, which follows the indentation already written
+func reindent(s, indent string) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,126 @@
+package dumper_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+const losslessConfig = `# top comment
+user  nginx;   # inline
+worker_processes	4;
+
+http {
+	include       mime.types;
+	upstream backend {
+		server 10.0.0.1:80   weight=5;
+		keepalive 8;
+		server 10.0.0.2:80;
+	}
+
+	map $http_host $name {
+		default   0;
+		example.com 1;
+	}
+
+	server {
+		listen 80;
+		server_name 'example.com'  "www.example.com";
+		# proxy stuff
+		location / {
+			proxy_pass http://backend;
+			add_header X-A "a b";
+		}
+		content_by_lua_block {
+			ngx.say("hi")
+		}
+	}
+	sendfile on;
+	# dangling
+}
+# end
+`
+
+func parseLossless(t *testing.T, s string) *config.Config {
+	t.Helper()
+	conf, err := parser.NewStringParser(s, parser.WithPreserveTrivia()).Parse()
+	assert.NilError(t, err)
+	return conf
+}
+
+func TestLossless_RoundTrip(t *testing.T) {
+	t.Parallel()
+	conf := parseLossless(t, losslessConfig)
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.LosslessStyle), losslessConfig)
+}
+
+func TestLossless_ModifiedDirective(t *testing.T) {
+	t.Parallel()
+	conf := parseLossless(t, losslessConfig)
+
+	serverName := conf.FindDirectives("server_name")[0].(*config.Directive)
+	serverName.Parameters[0].SetValue("'api.example.com'")
+
+	want := strings.Replace(losslessConfig, "'example.com'", "'api.example.com'", 1)
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.LosslessStyle), want)
+}
+
+func TestLossless_ModifiedInlineComment(t *testing.T) {
+	t.Parallel()
+	conf := parseLossless(t, "user  nginx;   # inline\nlisten 80;\t# port\n")
+
+	// the whitespace in front of inline comments survives a change of the directive
+	user := conf.FindDirectives("user")[0].(*config.Directive)
+	user.Parameters[0].SetValue("www")
+	listen := conf.FindDirectives("listen")[0].(*config.Directive)
+	listen.Parameters[0].SetValue("81")
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.LosslessStyle), "user  www;   # inline\nlisten 81;\t# port\n")
+}
+
+func TestLossless_SplitParameter(t *testing.T) {
+	t.Parallel()
+	conf := parseLossless(t, losslessConfig)
+
+	// the same words split into other parameters are a change
+	addHeader := conf.FindDirectives("add_header")[0].(*config.Directive)
+	assert.Assert(t, addHeader.GetTrivia().Unchanged(addHeader))
+	addHeader.Parameters = []config.Parameter{{Value: "X-A \"a"}, {Value: "b\""}}
+	assert.Assert(t, !addHeader.GetTrivia().Unchanged(addHeader))
+	assert.Assert(t, !addHeader.GetTrivia().HeaderUnchanged(addHeader))
+}
+
+func TestLossless_AddedDirective(t *testing.T) {
+	t.Parallel()
+	conf := parseLossless(t, losslessConfig)
+
+	location := conf.FindLocationsByPattern("/")[0]
+	location.GetBlock().(*config.Block).AddDirective(&config.Directive{
+		Name:       "proxy_read_timeout",
+		Parameters: []config.Parameter{{Value: "30s"}},
+	})
+
+	want := strings.Replace(losslessConfig, "\"a b\";\n", "\"a b\";\n\t\t\tproxy_read_timeout 30s;\n", 1)
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.LosslessStyle), want)
+}
+
+func TestLossless_RemovedDirective(t *testing.T) {
+	t.Parallel()
+	conf := parseLossless(t, losslessConfig)
+
+	upstream := conf.FindUpstreamByName("backend")
+	upstream.UpstreamServers = upstream.UpstreamServers[:1]
+
+	want := strings.Replace(losslessConfig, "\n\t\tserver 10.0.0.2:80;", "", 1)
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.LosslessStyle), want)
+}
+
+func TestLossless_WithoutTrivia(t *testing.T) {
+	t.Parallel()
+	conf, err := parser.NewStringParser("user  nginx;\nworker_processes 4;").Parse()
+	assert.NilError(t, err)
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.LosslessStyle), "user nginx;\nworker_processes 4;")
+}
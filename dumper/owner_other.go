@@ -0,0 +1,10 @@
+//go:build !unix
+
+package dumper
+
+import "os"
+
+// keepOwner does nothing, files have no unix owner on this platform
+func keepOwner(path string, info os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package dumper
+
+import (
+	"os"
+	"syscall"
+)
+
+// keepOwner gives the file at path the owner and group of info, when they differ from its own
+func keepOwner(path string, info os.FileInfo) error {
+	want, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if have, ok := current.Sys().(*syscall.Stat_t); ok && have.Uid == want.Uid && have.Gid == want.Gid {
+		return nil
+	}
+	return os.Chown(path, int(want.Uid), int(want.Gid))
+}
@@ -0,0 +1,187 @@
+package dumper
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// WritableFS is a file system configs can be written back to, like one created by DirFS.
+// Names are slash separated like in io/fs.
+type WritableFS interface {
+	fs.FS
+	// WriteFile creates or replaces the named file, creating its missing directories
+	WriteFile(name string, data []byte) error
+}
+
+// DirFS returns a WritableFS for the directory tree rooted at dir. Files are replaced
+// atomically like with WriteChanges, keeping their mode and owner.
+func DirFS(dir string) WritableFS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d dirFS) WriteFile(name string, data []byte) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	return writeFileAtomic(filepath.Join(d.dir, filepath.FromSlash(name)), data)
+}
+
+// ChangeSet returns the content of every file that WriteConfig would write, keyed by path.
+// A file is part of it when its config was modified since it was parsed or last written,
+// or when it does not exist yet. With includes the files resolved by include directives
+// are considered too.
+func ChangeSet(c *config.Config, style *Style, includes bool) (map[string]string, error) {
+	return changeSet(nil, c, style, includes)
+}
+
+// ChangeSetFS is ChangeSet for a config read from fsys, see parser.WithFS. The paths of
+// the change set are the config file paths, existing files are looked up in fsys.
+func ChangeSetFS(fsys fs.FS, c *config.Config, style *Style, includes bool) (map[string]string, error) {
+	return changeSet(fsys, c, style, includes)
+}
+
+func changeSet(fsys fs.FS, c *config.Config, style *Style, includes bool) (map[string]string, error) {
+	changes := make(map[string]string)
+	for _, cfg := range changedConfigs(fsys, c, includes) {
+		if cfg.FilePath == "" {
+			return nil, errors.New("config has no file path")
+		}
+		changes[cfg.FilePath] = DumpConfig(cfg, style)
+	}
+	return changes, nil
+}
+
+// WriteChanges writes a change set returned by ChangeSet. Each file is written to a
+// temporary file in the same directory and renamed over the original, which keeps its
+// mode and owner. New files get 0644 and their missing directories 0755.
+func WriteChanges(changes map[string]string) error {
+	for path, content := range changes {
+		if err := writeFileAtomic(path, []byte(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteChangesFS writes a change set returned by ChangeSetFS to fsys.
+// Absolute paths are written from the root of fsys.
+func WriteChangesFS(fsys WritableFS, changes map[string]string) error {
+	for path, content := range changes {
+		if err := fsys.WriteFile(config.FSName(path), []byte(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changedConfigs returns c and, with includes, the configs of its include directives,
+// keeping those that need to be written
+func changedConfigs(fsys fs.FS, c *config.Config, includes bool) []*config.Config {
+	configs := []*config.Config{c}
+	if includes {
+		seen := map[*config.Config]bool{c: true}
+		for _, directive := range c.FindDirectives("include") {
+			include, ok := directive.(*config.Include)
+			if !ok {
+				continue
+			}
+			for _, cfg := range include.Configs {
+				if !seen[cfg] {
+					seen[cfg] = true
+					configs = append(configs, cfg)
+				}
+			}
+		}
+	}
+
+	changed := configs[:0]
+	for _, cfg := range configs {
+		if cfg.Modified() || !exists(fsys, cfg.FilePath) {
+			changed = append(changed, cfg)
+		}
+	}
+	return changed
+}
+
+// exists returns true if something can be found at path, in fsys when it is not nil
+func exists(fsys fs.FS, path string) bool {
+	var err error
+	if fsys == nil {
+		_, err = os.Stat(path)
+	} else {
+		_, err = fs.Stat(fsys, config.FSName(path))
+	}
+	return err == nil
+}
+
+// writeFileAtomic replaces the file at path with data through a temporary file,
+// keeping the mode and owner of the file it replaces. Symlinks are followed.
+func writeFileAtomic(path string, data []byte) error {
+	if path == "" {
+		return errors.New("config has no file path")
+	}
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	mode := os.FileMode(0644)
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", path)
+		}
+		mode = info.Mode().Perm()
+	case os.IsNotExist(err):
+		info = nil
+	default:
+		return err
+	}
+
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	// create parent directories, if not exit
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return err
+	}
+	// the temporary file is gone once renamed, removing it again only matters on errors
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if info != nil {
+		if err := keepOwner(tmp.Name(), info); err != nil {
+			return fmt.Errorf("cannot keep the owner of %s: %w", path, err)
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
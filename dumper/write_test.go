@@ -0,0 +1,114 @@
+package dumper_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+	"gotest.tools/v3/assert"
+)
+
+// writeFiles creates nginx.conf including conf.d/a.conf and conf.d/b.conf in a temporary directory
+func writeFiles(t *testing.T) (dir string, conf *config.Config) {
+	t.Helper()
+	dir = t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(dir, "conf.d"), 0o755))
+	main := filepath.Join(dir, "nginx.conf")
+	assert.NilError(t, os.WriteFile(main, []byte("http {\n    include "+filepath.Join(dir, "conf.d", "*.conf")+";\n}\n"), 0o600))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "conf.d", "a.conf"), []byte("server {\n    listen 80;\n}\n"), 0o640))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "conf.d", "b.conf"), []byte("server {\n    listen 81;\n}\n"), 0o644))
+
+	p, err := parser.NewParser(main, parser.WithIncludeParsing())
+	assert.NilError(t, err)
+	conf, err = p.Parse()
+	assert.NilError(t, err)
+	return dir, conf
+}
+
+func TestChangeSet(t *testing.T) {
+	t.Parallel()
+
+	dir, conf := writeFiles(t)
+	changes, err := dumper.ChangeSet(conf, dumper.IndentedStyle, true)
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+
+	listen := conf.FindDirectives("listen")[1].(*config.Directive)
+	listen.Parameters[0].Value = "8081"
+
+	changes, err = dumper.ChangeSet(conf, dumper.IndentedStyle, true)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes, map[string]string{
+		filepath.Join(dir, "conf.d", "b.conf"): "server {\n    listen 8081;\n}",
+	})
+
+	// without includes only the main file is looked at
+	changes, err = dumper.ChangeSet(conf, dumper.IndentedStyle, false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+}
+
+func TestWriteConfig_OnlyChangedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir, conf := writeFiles(t)
+	a, b := filepath.Join(dir, "conf.d", "a.conf"), filepath.Join(dir, "conf.d", "b.conf")
+	old := time.Now().Add(-time.Hour)
+	assert.NilError(t, os.Chtimes(a, old, old))
+
+	conf.FindDirectives("listen")[1].(*config.Directive).Parameters[0].Value = "8081"
+	assert.NilError(t, dumper.WriteConfig(conf, dumper.IndentedStyle, true))
+
+	info, err := os.Stat(a)
+	assert.NilError(t, err)
+	assert.Assert(t, info.ModTime().Equal(old), "a.conf was rewritten")
+
+	content, err := os.ReadFile(b)
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "server {\n    listen 8081;\n}")
+
+	// the mode of the replaced files is kept
+	conf.FindDirectives("listen")[0].(*config.Directive).Parameters[0].Value = "8080"
+	conf.FindDirectives("http")[0].(*config.HTTP).Directives = append(conf.FindDirectives("http")[0].(*config.HTTP).Directives,
+		&config.Directive{Name: "gzip", Parameters: []config.Parameter{{Value: "on"}}})
+	assert.NilError(t, dumper.WriteConfig(conf, dumper.IndentedStyle, true))
+	for path, mode := range map[string]os.FileMode{a: 0o640, filepath.Join(dir, "nginx.conf"): 0o600} {
+		info, err := os.Stat(path)
+		assert.NilError(t, err)
+		assert.Equal(t, info.Mode().Perm(), mode, path)
+	}
+
+	// everything was written, nothing is left to do
+	changes, err := dumper.ChangeSet(conf, dumper.IndentedStyle, true)
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+	entries, err := os.ReadDir(filepath.Join(dir, "conf.d"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 2, "temporary files were left behind")
+}
+
+func TestWriteChanges_NewFile(t *testing.T) {
+	t.Parallel()
+
+	conf, err := parser.NewStringParser("user nginx;\n").Parse()
+	assert.NilError(t, err)
+	_, err = dumper.ChangeSet(conf, dumper.IndentedStyle, false)
+	assert.ErrorContains(t, err, "config has no file path")
+
+	// a config moved to a new path is written there
+	conf.FilePath = filepath.Join(t.TempDir(), "sites", "new.conf")
+	changes, err := dumper.ChangeSet(conf, dumper.IndentedStyle, false)
+	assert.NilError(t, err)
+	assert.NilError(t, dumper.WriteChanges(changes))
+
+	info, err := os.Stat(conf.FilePath)
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0o644))
+	content, err := os.ReadFile(conf.FilePath)
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "user nginx;")
+}
@@ -18,9 +18,9 @@ type EnhancedParser struct {
 	errors         *ErrorCollection
 }
 
-// NewEnhancedParser creates a new enhanced parser
-func NewEnhancedParser(filename string) (*EnhancedParser, error) {
-	originalParser, err := parser.NewParser(filename)
+// NewEnhancedParser creates a new enhanced parser, pass parser.WithErrorRecovery to collect every syntax error
+func NewEnhancedParser(filename string, opts ...parser.Option) (*EnhancedParser, error) {
+	originalParser, err := parser.NewParser(filename, opts...)
 	if err != nil {
 		return nil, NewFileError(fmt.Sprintf("failed to create parser for file '%s'", filename)).
 			WithFile(filename).
@@ -44,8 +44,8 @@ func NewEnhancedParser(filename string) (*EnhancedParser, error) {
 }
 
 // NewEnhancedStringParser creates a new enhanced parser from string content
-func NewEnhancedStringParser(content string) *EnhancedParser {
-	originalParser := parser.NewStringParser(content)
+func NewEnhancedStringParser(content string, opts ...parser.Option) *EnhancedParser {
+	originalParser := parser.NewStringParser(content, opts...)
 
 	return &EnhancedParser{
 		originalParser: originalParser,
@@ -55,10 +55,20 @@ func NewEnhancedStringParser(content string) *EnhancedParser {
 	}
 }
 
-// Parse parses the configuration with enhanced error reporting
+// Parse parses the configuration with enhanced error reporting.
+// With parser.WithErrorRecovery the partial configuration is returned
+// together with an ErrorCollection holding every syntax error
 func (ep *EnhancedParser) Parse() (*config.Config, error) {
 	conf, err := ep.originalParser.Parse()
 
+	if syntaxErrors, ok := err.(parser.ErrorList); ok {
+		for _, syntaxErr := range syntaxErrors {
+			ep.errors.Add(ep.enhanceSyntaxError(syntaxErr))
+		}
+		ep.validateConfiguration(conf)
+		return conf, ep.errors
+	}
+
 	if err != nil {
 		// Try to enhance the original error
 		enhancedErr := ep.enhanceError(err)
@@ -102,6 +112,11 @@ func (ep *EnhancedParser) enhanceError(originalErr error) error {
 		fmt.Sscanf(errStr, "%*s line %d", &lineNum)
 	}
 
+	var column int
+	if syntaxErr, ok := originalErr.(*parser.SyntaxError); ok {
+		lineNum, column = syntaxErr.Pos.Line, syntaxErr.Pos.Column
+	}
+
 	var enhancedErr *ParseError
 
 	// Categorize the error based on its content
@@ -136,6 +151,57 @@ func (ep *EnhancedParser) enhanceError(originalErr error) error {
 			enhancedErr.WithContext(context)
 		}
 	}
+	if column > 0 {
+		enhancedErr.WithColumn(column)
+	}
+
+	return enhancedErr
+}
+
+// enhanceSyntaxError turns one of the errors collected by a recovering parser into a ParseError
+func (ep *EnhancedParser) enhanceSyntaxError(syntaxErr *parser.SyntaxError) *ParseError {
+	var enhancedErr *ParseError
+
+	switch {
+	case strings.HasPrefix(syntaxErr.Message, "unknown directive"):
+		enhancedErr = NewUnknownDirectiveError(syntaxErr.Directive)
+
+	case syntaxErr.Directive == "include":
+		enhancedErr = NewFileError(syntaxErr.Message).
+			WithDirective(syntaxErr.Directive).
+			WithSuggestion("Check that the included files exist and are readable")
+
+	case strings.Contains(syntaxErr.Message, "unexpected eof"),
+		strings.Contains(syntaxErr.Message, "unexpected end of file"):
+		enhancedErr = NewSyntaxError(syntaxErr.Message).
+			WithSuggestion("Check for unclosed blocks, quotes or missing closing braces")
+
+	case strings.Contains(syntaxErr.Message, "unexpected token"):
+		enhancedErr = NewSyntaxError(syntaxErr.Message).
+			WithSuggestion("Check for missing semicolons, braces, or quotes")
+
+	default:
+		enhancedErr = NewSyntaxError(syntaxErr.Message)
+	}
+	if syntaxErr.Directive != "" {
+		enhancedErr.WithDirective(syntaxErr.Directive)
+	}
+
+	file := syntaxErr.Pos.File
+	if file == "" {
+		file = ep.filename
+	}
+	enhancedErr.WithFile(file).
+		WithLine(syntaxErr.Pos.Line).
+		WithColumn(syntaxErr.Pos.Column).
+		WithInnerError(syntaxErr)
+
+	// the source of included files is not at hand
+	if file == ep.filename {
+		if context := ep.getLineContext(syntaxErr.Pos.Line); context != "" {
+			enhancedErr.WithContext(context)
+		}
+	}
 
 	return enhancedErr
 }
@@ -6,6 +6,7 @@ toolchain go1.22.8
 
 require (
 	github.com/imega/luaformatter v0.0.0-20211025140405-86b0a68d6bef
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	gopkg.in/yaml.v2 v2.2.8
 	gotest.tools/v3 v3.5.1
 )
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// dumpHeader starts every file in the output of nginx -T
+const dumpHeader = "# configuration file "
+
+// Dump is a config tree read from the output of nginx -T
+type Dump struct {
+	Root  *config.Config            // the main file, the first one of the dump
+	Files map[string]*config.Config // every file of the dump by path, Root included
+	Order []string                  // the paths in the order of the dump
+}
+
+// ParseDump parses the output of nginx -T, which prints every configuration file after a
+// "# configuration file /path:" header. The files are parsed like they would be from disk
+// with WithIncludeParsing: the include directives hold the configs of the dumped files they
+// match, and those are the configs of Files. Lines before the first header, like the
+// "syntax is ok" messages of nginx, are skipped.
+func ParseDump(r io.Reader, opts ...Option) (*Dump, error) {
+	files, order, err := splitDump(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(order) == 0 {
+		return nil, errors.New("no configuration file found in the nginx -T output")
+	}
+
+	// the dumped files are read from memory, where includes find each other as on disk
+	fsys := make(memFS, len(files))
+	for path, content := range files {
+		fsys[config.FSName(path)] = content
+	}
+	opts = append(opts, WithFS(fsys), WithIncludeParsing())
+
+	dump := &Dump{Files: make(map[string]*config.Config, len(order)), Order: order}
+	var parseErr error
+	for _, path := range order {
+		if dump.Files[path] != nil {
+			continue
+		}
+		// the main file pulls in the others, a file no include matches is parsed on its own
+		c, err := parseDumpFile(path, opts)
+		if c == nil {
+			return nil, err
+		}
+		if err != nil && parseErr == nil {
+			parseErr = err
+		}
+		if dump.Root == nil {
+			dump.Root = c
+		}
+		dump.add(c)
+	}
+	return dump, parseErr
+}
+
+// parseDumpFile parses one file of a dump, the config comes with an ErrorList when recovering from errors
+func parseDumpFile(path string, opts []Option) (*config.Config, error) {
+	p, err := NewParser(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return p.Parse()
+}
+
+// add adds c and the configs of its includes to the files of the dump.
+// A file included several times keeps the config of its first include.
+func (d *Dump) add(c *config.Config) {
+	if _, ok := d.Files[c.FilePath]; !ok {
+		d.Files[c.FilePath] = c
+	}
+	for _, directive := range c.FindDirectives("include") {
+		include, ok := directive.(*config.Include)
+		if !ok {
+			continue
+		}
+		for _, cfg := range include.Configs {
+			if _, ok := d.Files[cfg.FilePath]; !ok {
+				d.Files[cfg.FilePath] = cfg
+			}
+		}
+	}
+}
+
+// splitDump splits the output of nginx -T into the content of each file, keyed by path
+func splitDump(r io.Reader) (map[string]string, []string, error) {
+	files := make(map[string]string)
+	var order []string
+	var current *strings.Builder
+	flush := func() {
+		if current == nil {
+			return
+		}
+		// nginx ends every file with an empty line
+		files[order[len(order)-1]] = strings.TrimSuffix(current.String(), "\n")
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			trimmed := strings.TrimRight(line, "\r\n")
+			if strings.HasPrefix(trimmed, dumpHeader) && strings.HasSuffix(trimmed, ":") {
+				flush()
+				path := strings.TrimSuffix(strings.TrimPrefix(trimmed, dumpHeader), ":")
+				order = append(order, path)
+				current = &strings.Builder{}
+			} else if current != nil {
+				current.WriteString(line)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	flush()
+	return files, order, nil
+}
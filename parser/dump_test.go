@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lefeck/gonginx/config"
+	"gotest.tools/v3/assert"
+)
+
+const nginxDump = `nginx: the configuration file /etc/nginx/nginx.conf syntax is ok
+nginx: configuration file /etc/nginx/nginx.conf test is successful
+# configuration file /etc/nginx/nginx.conf:
+user nginx;
+http {
+    include mime.types;
+    include /etc/nginx/conf.d/*.conf;
+}
+
+# configuration file /etc/nginx/mime.types:
+types {
+    text/html html;
+}
+
+# configuration file /etc/nginx/conf.d/default.conf:
+server {
+    listen 80;
+    include snippets/common.conf;
+}
+
+# configuration file /etc/nginx/snippets/common.conf:
+gzip on;
+
+# configuration file /etc/nginx/orphan.conf:
+server_tokens off;
+
+`
+
+func TestParseDump(t *testing.T) {
+	t.Parallel()
+
+	dump, err := ParseDump(strings.NewReader(nginxDump))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, dump.Order, []string{
+		"/etc/nginx/nginx.conf",
+		"/etc/nginx/mime.types",
+		"/etc/nginx/conf.d/default.conf",
+		"/etc/nginx/snippets/common.conf",
+		"/etc/nginx/orphan.conf",
+	})
+	assert.Equal(t, len(dump.Files), 5)
+	assert.Equal(t, dump.Root, dump.Files["/etc/nginx/nginx.conf"])
+
+	includes := dump.Root.FindDirectives("include")
+	assert.Equal(t, len(includes), 3)
+	sites := includes[1].(*config.Include)
+	assert.Equal(t, sites.Configs[0], dump.Files["/etc/nginx/conf.d/default.conf"])
+	assert.Equal(t, sites.Configs[0].IncludedBy(), sites)
+	// relative includes resolve against the directory of the main file, like nginx does
+	common := includes[2].(*config.Include)
+	assert.Equal(t, common.Configs[0], dump.Files["/etc/nginx/snippets/common.conf"])
+
+	// line numbers are those of each file
+	listen := dump.Root.FindDirectives("listen")[0]
+	assert.Equal(t, listen.GetLine(), 2)
+	assert.Equal(t, len(dump.Files["/etc/nginx/orphan.conf"].FindDirectives("server_tokens")), 1)
+
+	_, err = ParseDump(strings.NewReader("nginx: configuration file test failed\n"))
+	assert.ErrorContains(t, err, "no configuration file found")
+	_, err = ParseDump(strings.NewReader("# configuration file /etc/nginx/nginx.conf:\nhttp {\n"))
+	assert.ErrorContains(t, err, "unexpected eof")
+}
+
+func TestMemFS(t *testing.T) {
+	t.Parallel()
+	fsys := memFS{
+		"etc/nginx/nginx.conf":       "include conf.d/*.conf;\n",
+		"etc/nginx/conf.d/a.conf":    "gzip on;\n",
+		"etc/nginx/conf.d/b.conf":    "",
+		"etc/nginx/sites/x/www.conf": "root /srv;\n",
+	}
+	assert.NilError(t, fstest.TestFS(fsys, "etc/nginx/nginx.conf", "etc/nginx/conf.d/a.conf", "etc/nginx/sites/x/www.conf"))
+}
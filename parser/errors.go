@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// SyntaxError is an error found at a known position of the source
+type SyntaxError struct {
+	Message   string
+	Directive string // the directive being parsed, empty if the error is not inside a directive
+	Pos       config.Position
+}
+
+// Error returns the message followed by the position, the way the parser always reported them
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s on line %d, column %d", e.Message, e.Pos.Line, e.Pos.Column)
+}
+
+// ErrorList is returned by Parse when parsing WithErrorRecovery found errors
+type ErrorList []*SyntaxError
+
+// Error returns every error on its own line
+func (l ErrorList) Error() string {
+	messages := make([]string, len(l))
+	for i, err := range l {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// sort orders the errors of a single file by position
+func (l ErrorList) sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].Pos.Offset < l[j].Pos.Offset
+	})
+}
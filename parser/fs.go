@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// WithFS reads the config and its included files from fsys instead of the operating system,
+// for example an embed.FS, an fstest.MapFS or the content of a tarball. Paths are slash
+// separated like in io/fs, absolute paths and include globs are resolved from the root of fsys.
+func WithFS(fsys fs.FS) Option {
+	return func(p *Parser) {
+		p.opts.fsys = fsys
+	}
+}
+
+// openFile opens a config file from fsys, or from the operating system when fsys is nil
+func openFile(fsys fs.FS, name string) (io.ReadCloser, error) {
+	if fsys == nil {
+		return os.Open(name)
+	}
+	return fsys.Open(config.FSName(name))
+}
+
+// joinPath resolves an include path against the directory of the main file
+func joinPath(fsys fs.FS, root, name string) string {
+	if fsys == nil {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(root, name)
+	}
+	if path.IsAbs(name) {
+		return name
+	}
+	return path.Join(root, name)
+}
+
+// globFiles returns the files matching pattern. In fsys the matches of an absolute
+// pattern are made absolute again, so that they read like the include that found them.
+func globFiles(fsys fs.FS, pattern string) ([]string, error) {
+	if fsys == nil {
+		return filepath.Glob(pattern)
+	}
+	matches, err := fs.Glob(fsys, config.FSName(pattern))
+	if err != nil || !path.IsAbs(pattern) {
+		return matches, err
+	}
+	for i, match := range matches {
+		matches[i] = "/" + match
+	}
+	return matches, nil
+}
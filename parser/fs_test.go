@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/lefeck/gonginx/config"
+	"gotest.tools/v3/assert"
+)
+
+func TestParser_WithFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"etc/nginx/nginx.conf":         {Data: []byte("http {\n    include conf.d/*.conf;\n    include /etc/nginx/snippets/gzip.conf;\n}\n")},
+		"etc/nginx/conf.d/a.conf":      {Data: []byte("server {\n    listen 80;\n}\n")},
+		"etc/nginx/conf.d/b.conf":      {Data: []byte("server {\n    listen 81;\n}\n")},
+		"etc/nginx/snippets/gzip.conf": {Data: []byte("gzip on;\n")},
+		"etc/nginx/snippets/old.conf":  {Data: []byte("gzip off;\n")},
+	}
+	p, err := NewParser("/etc/nginx/nginx.conf", WithFS(fsys), WithIncludeParsing())
+	assert.NilError(t, err)
+	conf, err := p.Parse()
+	assert.NilError(t, err)
+	assert.Equal(t, conf.FilePath, "/etc/nginx/nginx.conf")
+
+	includes := conf.FindDirectives("include")
+	sites := includes[0].(*config.Include)
+	assert.Equal(t, len(sites.Configs), 2)
+	assert.Equal(t, sites.Configs[1].FilePath, "/etc/nginx/conf.d/b.conf")
+	assert.Equal(t, len(conf.FindDirectives("listen")), 2)
+	gzip := includes[1].(*config.Include)
+	assert.Equal(t, gzip.Configs[0].FilePath, "/etc/nginx/snippets/gzip.conf")
+
+	unused, err := p.IncludeGraph().UnusedFiles("/etc/nginx", "*.conf")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, unused, []string{"/etc/nginx/snippets/old.conf"})
+
+	// relative paths work the same, and nothing is read from the disk
+	p, err = NewParser("etc/nginx/conf.d/a.conf", WithFS(fsys))
+	assert.NilError(t, err)
+	_, err = p.Parse()
+	assert.NilError(t, err)
+	_, err = NewParser("etc/nginx/missing.conf", WithFS(fsys))
+	assert.ErrorContains(t, err, "file does not exist")
+}
@@ -0,0 +1,257 @@
+package parser
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// includeState is what the parsers of a config and of its included files learn about the includes
+type includeState struct {
+	matches map[*config.Include][]string // files matched by each include
+	cycles  []IncludeCycle
+}
+
+func newIncludeState() *includeState {
+	return &includeState{matches: make(map[*config.Include][]string)}
+}
+
+// fileStack returns the files being parsed, from the main file to the one of this parser
+func (p *Parser) fileStack() []string {
+	if p.includeStack != nil {
+		return append([]string(nil), p.includeStack...)
+	}
+	if p.lexer.file == "" {
+		return nil
+	}
+	return []string{filepath.Clean(p.lexer.file)}
+}
+
+// includeCycle returns the files of the cycle made by including path from the last file of stack, nil if there is none
+func includeCycle(stack []string, path string) []string {
+	path = filepath.Clean(path)
+	for i, file := range stack {
+		if samePath(file, path) {
+			return append(append([]string(nil), stack[i:]...), path)
+		}
+	}
+	return nil
+}
+
+// samePath returns true if both paths name the same file
+func samePath(a, b string) bool {
+	if a == b {
+		return true
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// IncludeCycle is a chain of files including each other
+type IncludeCycle struct {
+	Path    []string        // the files of the cycle, the first and the last one are the same
+	Include *config.Include // the include directive closing the cycle, it was not followed
+}
+
+// IncludeEdge is an include directive and the files it matched
+type IncludeEdge struct {
+	From    string // the file holding the include directive
+	Line    int
+	Pattern string          // the include path as written, possibly a glob
+	Context string          // the context of the include directive, like "http" or "server"
+	Targets []string        // the files matched by Pattern, in glob order
+	Include *config.Include // the include directive
+}
+
+// IncludeNode is a file of the include graph
+type IncludeNode struct {
+	Path       string
+	Config     *config.Config // nil when the file could not be parsed or closes a cycle
+	Includes   []*IncludeEdge // the include directives of the file
+	IncludedBy []*IncludeEdge // the include directives matching the file
+}
+
+// FanIn returns the number of include directives matching the file
+func (n *IncludeNode) FanIn() int {
+	return len(n.IncludedBy)
+}
+
+// Contexts returns the distinct contexts the file is included from, sorted
+func (n *IncludeNode) Contexts() []string {
+	seen := map[string]bool{}
+	var contexts []string
+	for _, edge := range n.IncludedBy {
+		if !seen[edge.Context] {
+			seen[edge.Context] = true
+			contexts = append(contexts, edge.Context)
+		}
+	}
+	sort.Strings(contexts)
+	return contexts
+}
+
+// IncludeGraph describes how the files of a config include each other
+type IncludeGraph struct {
+	Root   string                  // the main file
+	Nodes  map[string]*IncludeNode // the main file and every file matched by an include, by path
+	Edges  []*IncludeEdge          // the include directives in document order
+	Cycles []IncludeCycle
+
+	fsys fs.FS // the file system the files were read from, see WithFS
+}
+
+// IncludeGraph returns the include graph of the config returned by Parse.
+// It is nil before Parse and when the parser does not follow includes, see WithIncludeParsing.
+func (p *Parser) IncludeGraph() *IncludeGraph {
+	if p.config == nil || !p.opts.parseInclude {
+		return nil
+	}
+	g := &IncludeGraph{
+		Root:   p.config.FilePath,
+		Nodes:  make(map[string]*IncludeNode),
+		Cycles: append([]IncludeCycle(nil), p.includes.cycles...),
+		fsys:   p.opts.fsys,
+	}
+	g.node(p.config.FilePath).Config = p.config
+
+	_ = config.Walk(p.config, config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+		include, ok := c.Directive().(*config.Include)
+		if !ok {
+			return nil
+		}
+		edge := &IncludeEdge{
+			From:    c.File().FilePath,
+			Line:    include.GetLine(),
+			Pattern: include.IncludePath,
+			Context: c.Context(),
+			Targets: p.includes.matches[include],
+			Include: include,
+		}
+		g.Edges = append(g.Edges, edge)
+		g.node(edge.From).Includes = append(g.node(edge.From).Includes, edge)
+		for _, target := range edge.Targets {
+			node := g.node(target)
+			node.IncludedBy = append(node.IncludedBy, edge)
+		}
+		for _, conf := range include.Configs {
+			g.node(conf.FilePath).Config = conf
+		}
+		return nil
+	}}, config.WalkIncludes())
+	return g
+}
+
+// node returns the node of a file, adding it when needed
+func (g *IncludeGraph) node(path string) *IncludeNode {
+	node, ok := g.Nodes[path]
+	if !ok {
+		node = &IncludeNode{Path: path}
+		g.Nodes[path] = node
+	}
+	return node
+}
+
+// UnmatchedGlobs returns the include directives that matched no file
+func (g *IncludeGraph) UnmatchedGlobs() []*IncludeEdge {
+	var edges []*IncludeEdge
+	for _, edge := range g.Edges {
+		if len(edge.Targets) == 0 {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// MultiContextFiles returns the files included from more than one context, sorted by path
+func (g *IncludeGraph) MultiContextFiles() []*IncludeNode {
+	var nodes []*IncludeNode
+	for _, node := range g.Nodes {
+		if len(node.Contexts()) > 1 {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Path < nodes[j].Path })
+	return nodes
+}
+
+// UnusedFiles returns the files below dir that are neither the main file nor
+// included, sorted. When pattern is not empty only the files whose name matches
+// it are considered, for example "*.conf". When the config was read with WithFS
+// dir is looked up in that file system.
+func (g *IncludeGraph) UnusedFiles(dir, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if g.fsys != nil {
+		return g.unusedFilesFS(dir, pattern)
+	}
+	used := make(map[string]bool, len(g.Nodes))
+	for path := range g.Nodes {
+		if abs, err := filepath.Abs(path); err == nil {
+			used[abs] = true
+		}
+	}
+
+	var unused []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !matchName(pattern, d.Name()) {
+			return nil
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if !used[abs] {
+			unused = append(unused, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(unused)
+	return unused, nil
+}
+
+// unusedFilesFS is UnusedFiles for a config read with WithFS
+func (g *IncludeGraph) unusedFilesFS(dir, pattern string) ([]string, error) {
+	used := make(map[string]bool, len(g.Nodes))
+	for name := range g.Nodes {
+		used[config.FSName(name)] = true
+	}
+
+	var unused []string
+	err := fs.WalkDir(g.fsys, config.FSName(dir), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && matchName(pattern, d.Name()) && !used[name] {
+			if path.IsAbs(dir) {
+				name = "/" + name
+			}
+			unused = append(unused, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(unused)
+	return unused, nil
+}
+
+// matchName returns true if pattern is empty or matches the file name
+func matchName(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, name)
+	return ok
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// writeTree writes files below a temporary directory and returns it
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NilError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestParser_IncludeGraph(t *testing.T) {
+	t.Parallel()
+
+	dir := writeTree(t, map[string]string{
+		"nginx.conf":             "http {\n    include sites/*.conf;\n    include missing/*.conf;\n}\nstream {\n    include snippets/common.conf;\n}\n",
+		"sites/a.conf":           "server {\n    include snippets/common.conf;\n}\n",
+		"sites/b.conf":           "server {\n    listen 81;\n}\n",
+		"snippets/common.conf":   "proxy_timeout 10s;\n",
+		"snippets/dead.conf":     "gzip on;\n",
+		"snippets/readme.txt":    "not a config\n",
+		"nested/deeper/old.conf": "gzip off;\n",
+	})
+	main := filepath.Join(dir, "nginx.conf")
+	p, err := NewParser(main, WithIncludeParsing(), WithSkipValidDirectivesErr())
+	assert.NilError(t, err)
+	assert.Assert(t, p.IncludeGraph() == nil)
+	_, err = p.Parse()
+	assert.NilError(t, err)
+
+	g := p.IncludeGraph()
+	assert.Equal(t, g.Root, main)
+	assert.Equal(t, len(g.Edges), 4)
+	assert.Equal(t, len(g.Nodes), 4)
+
+	first := g.Edges[0]
+	assert.Equal(t, first.From, main)
+	assert.Equal(t, first.Line, 2)
+	assert.Equal(t, first.Context, "http")
+	assert.DeepEqual(t, first.Targets, []string{filepath.Join(dir, "sites/a.conf"), filepath.Join(dir, "sites/b.conf")})
+	// the include of a.conf comes before the next one of nginx.conf
+	assert.Equal(t, g.Edges[1].From, filepath.Join(dir, "sites/a.conf"))
+	assert.Equal(t, g.Edges[1].Context, "server")
+
+	unmatched := g.UnmatchedGlobs()
+	assert.Equal(t, len(unmatched), 1)
+	assert.Equal(t, unmatched[0].Pattern, "missing/*.conf")
+
+	common := g.Nodes[filepath.Join(dir, "snippets/common.conf")]
+	assert.Equal(t, common.FanIn(), 2)
+	assert.DeepEqual(t, common.Contexts(), []string{"server", "stream"})
+	multi := g.MultiContextFiles()
+	assert.Equal(t, len(multi), 1)
+	assert.Equal(t, multi[0], common)
+	assert.Assert(t, common.Config != nil)
+
+	unused, err := g.UnusedFiles(dir, "*.conf")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, unused, []string{filepath.Join(dir, "nested/deeper/old.conf"), filepath.Join(dir, "snippets/dead.conf")})
+	unused, err = g.UnusedFiles(dir, "")
+	assert.NilError(t, err)
+	assert.Equal(t, len(unused), 3)
+}
+
+func TestParser_IncludeCycles(t *testing.T) {
+	t.Parallel()
+
+	dir := writeTree(t, map[string]string{
+		"nginx.conf": "http {\n    include a.conf;\n}\n",
+		"a.conf":     "include b.conf;\n",
+		"b.conf":     "include a.conf;\ninclude self.conf;\n",
+		"self.conf":  "include self.conf;\n",
+	})
+	p, err := NewParser(filepath.Join(dir, "nginx.conf"), WithIncludeParsing())
+	assert.NilError(t, err)
+	conf, err := p.Parse()
+	assert.NilError(t, err)
+
+	g := p.IncludeGraph()
+	assert.Equal(t, len(g.Cycles), 2)
+	path := func(names ...string) []string {
+		for i, name := range names {
+			names[i] = filepath.Join(dir, name)
+		}
+		return names
+	}
+	assert.DeepEqual(t, g.Cycles[0].Path, path("a.conf", "b.conf", "a.conf"))
+	assert.Equal(t, g.Cycles[0].Include.GetLine(), 1)
+	assert.DeepEqual(t, g.Cycles[1].Path, path("self.conf", "self.conf"))
+
+	// the include closing a cycle is kept but not followed
+	assert.Equal(t, len(conf.FindDirectives("include")), 5)
+	assert.Equal(t, len(g.Cycles[0].Include.Configs), 0)
+}
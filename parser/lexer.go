@@ -5,7 +5,9 @@ import (
 	"bytes"
 	"io"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/lefeck/gonginx/config"
 	"github.com/lefeck/gonginx/parser/token"
 )
 
@@ -19,6 +21,11 @@ type lexer struct {
 	column     int           // 当前列号
 	inLuaBlock bool          // 是否在Lua代码块中
 	Latest     token.Token   // Latest 保存上一个扫描的token
+	offset     int           // 当前字节偏移量
+	keepSource bool          // 是否保留已读取的源文本
+	source     bytes.Buffer
+	tolerant   bool           // 遇到未闭合的字符串时记录错误而不是panic
+	errors     []*SyntaxError // tolerant 模式下记录的错误
 }
 
 // lex initializes a lexer from string conetnt
@@ -30,6 +37,7 @@ func lex(content string) *lexer {
 func newLexer(r io.Reader) *lexer {
 	return &lexer{
 		line:   1,
+		column: 1,
 		reader: bufio.NewReader(r),
 	}
 }
@@ -140,6 +148,7 @@ func (s *lexer) NewToken(tokenType token.Type) token.Token {
 		Type:   tokenType,
 		Line:   s.line,
 		Column: s.column,
+		Offset: s.offset,
 	}
 }
 
@@ -176,6 +185,10 @@ func (s *lexer) scanLuaCode() token.Token {
 	for {
 		ch := s.read()
 		if ch == rune(token.EOF) {
+			if s.tolerant {
+				s.fail(ret, "unexpected end of file while scanning a string, maybe an unclosed lua code?")
+				return ret.Lit(code.String())
+			}
 			panic("unexpected end of file while scanning a string, maybe an unclosed lua code?")
 		}
 		if ch == '#' {
@@ -185,7 +198,7 @@ func (s *lexer) scanLuaCode() token.Token {
 		} else if ch == '}' {
 			if len(stack) == 0 {
 				// the end of block
-				_ = s.reader.UnreadRune()
+				s.unread(ch)
 				return ret.Lit(code.String())
 			}
 			// maybe it's lua table end, pop stack
@@ -200,6 +213,14 @@ func (s *lexer) scanLuaCode() token.Token {
 	}
 }
 
+// fail records an error at the start of the token being scanned
+func (s *lexer) fail(t token.Token, message string) {
+	s.errors = append(s.errors, &SyntaxError{
+		Message: message,
+		Pos:     config.Position{File: s.file, Line: t.Line, Column: t.Column, Offset: t.Offset},
+	})
+}
+
 /*
 *
 \” – To escape " within double quoted string.
@@ -216,6 +237,10 @@ func (s *lexer) scanQuotedString(delimiter rune) token.Token {
 		ch := s.read()
 
 		if ch == rune(token.EOF) {
+			if s.tolerant {
+				s.fail(tok, "unexpected end of file while scanning a string, maybe an unclosed quote?")
+				return tok.Lit(buf.String())
+			}
 			panic("unexpected end of file while scanning a string, maybe an unclosed quote?")
 		}
 
@@ -293,9 +318,31 @@ func (s *lexer) read() rune {
 	} else {
 		s.column++
 	}
+	s.offset += utf8.RuneLen(ch)
+	if s.keepSource {
+		s.source.WriteRune(ch)
+	}
 	return ch
 }
 
+// unread puts back the latest rune consumed by read
+func (s *lexer) unread(ch rune) {
+	_ = s.reader.UnreadRune()
+	s.offset -= utf8.RuneLen(ch)
+	if s.keepSource {
+		s.source.Truncate(s.source.Len() - utf8.RuneLen(ch))
+	}
+}
+
+// text returns the source text between two byte offsets, it only works if the source is kept
+func (s *lexer) text(start, end int) string {
+	src := s.source.Bytes()
+	if start < 0 || end > len(src) || start > end {
+		return ""
+	}
+	return string(src[start:end])
+}
+
 func isQuote(ch rune) bool {
 	return ch == '"' || ch == '\'' || ch == '`'
 }
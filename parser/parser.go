@@ -2,9 +2,9 @@ package parser
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
-	"os"
+	"io"
+	"io/fs"
 	"path/filepath"
 	"strings"
 
@@ -22,6 +22,10 @@ type options struct {
 	customDirectives           map[string]string
 	skipValidSubDirectiveBlock map[string]struct{}
 	skipValidDirectivesErr     bool
+	preserveTrivia             bool
+	recoverErrors              bool
+	fsys                       fs.FS           // nil reads from the operating system
+	profile                    *config.Profile // the build the config targets, nil for any
 }
 
 func defaultOptions() options {
@@ -32,6 +36,8 @@ func defaultOptions() options {
 		customDirectives:           map[string]string{},
 		skipValidSubDirectiveBlock: map[string]struct{}{},
 		skipValidDirectivesErr:     false,
+		preserveTrivia:             false,
+		recoverErrors:              false,
 	}
 }
 
@@ -42,15 +48,31 @@ type Parser struct {
 	lexer             *lexer
 	currentToken      token.Token
 	followingToken    token.Token
-	parsedIncludes    map[*config.Include]*config.Config
+	includes          *includeState // shared with the parsers of included files
+	includeStack      []string      // files being parsed, from the main file to this one
+	config            *config.Config
 	statementParsers  map[string]func() (config.IDirective, error)
 	blockWrappers     map[string]func(*config.Directive) (config.IDirective, error)
 	directiveWrappers map[string]func(*config.Directive) (config.IDirective, error)
 	includeWrappers   map[string]func(*config.Directive) (config.IDirective, error)
 
 	commentBuffer []string
-	file          *os.File
+	commentStart  int // byte offset of the first buffered comment
+	lastBlock     *blockInfo
+	terminator    token.Token // the ';' that ended the latest directive without a block
+	file          io.Closer
 	contextStack  []string // Track parsing context (e.g., "stream", "http")
+
+	errors        ErrorList // syntax errors found while recovering from errors
+	includeErrors ErrorList // syntax errors found in included files
+	eofReported   bool      // an unclosed block was reported already
+}
+
+// blockInfo keeps the braces of the most recently parsed block
+type blockInfo struct {
+	open    token.Token
+	close   token.Token
+	closing string // source text after the last statement, kept when parsing with trivia
 }
 
 // WithSameOptions copy options from another parser
@@ -60,9 +82,10 @@ func WithSameOptions(p *Parser) Option {
 	}
 }
 
-func withParsedIncludes(parsedIncludes map[*config.Include]*config.Config) Option {
+func withIncludeState(includes *includeState, stack []string) Option {
 	return func(p *Parser) {
-		p.parsedIncludes = parsedIncludes
+		p.includes = includes
+		p.includeStack = stack
 	}
 }
 
@@ -125,6 +148,31 @@ func WithSkipValidDirectivesErr() Option {
 	}
 }
 
+// WithProfile rejects the directives the target build does not have, like the directives of
+// NGINX Plus or of modules the profile is not built with, and accepts the directives of its
+// modules, see config.LookupProfile
+func WithProfile(profile *config.Profile) Option {
+	return func(p *Parser) {
+		p.opts.profile = profile
+	}
+}
+
+// WithPreserveTrivia keeps whitespace, blank lines, quoting and comment placement,
+// so that the config can be written back losslessly with dumper.LosslessStyle
+func WithPreserveTrivia() Option {
+	return func(p *Parser) {
+		p.opts.preserveTrivia = true
+	}
+}
+
+// WithErrorRecovery keeps parsing after a syntax error by resynchronizing at the next ';' or '}',
+// Parse then returns the partial config together with an ErrorList holding every error
+func WithErrorRecovery() Option {
+	return func(p *Parser) {
+		p.opts.recoverErrors = true
+	}
+}
+
 // NewStringParser parses nginx conf from string
 func NewStringParser(str string, opts ...Option) *Parser {
 	return NewParserFromLexer(lex(str), opts...)
@@ -132,40 +180,52 @@ func NewStringParser(str string, opts ...Option) *Parser {
 
 // NewParser create new parser
 func NewParser(filePath string, opts ...Option) (*Parser, error) {
-	f, err := os.Open(filePath)
+	// the file system is an option, so the options are applied before the file is opened
+	parser := newParser(filePath, opts)
+	f, err := openFile(parser.opts.fsys, filePath)
 	if err != nil {
 		return nil, err
 	}
 	l := newLexer(bufio.NewReader(f))
 	l.file = filePath
-	p := NewParserFromLexer(l, opts...)
-	p.file = f
-	return p, nil
+	parser.file = f
+	parser.start(l)
+	return parser, nil
 }
 
 // NewParserFromLexer initilizes a new Parser
 func NewParserFromLexer(lexer *lexer, opts ...Option) *Parser {
-	configRoot, _ := filepath.Split(lexer.file)
+	parser := newParser(lexer.file, opts)
+	parser.start(lexer)
+	return parser
+}
+
+// newParser returns a parser for the file with the options applied, it reads nothing until started
+func newParser(file string, opts []Option) *Parser {
+	configRoot, _ := filepath.Split(file)
 	parser := &Parser{
-		lexer:          lexer,
-		opts:           defaultOptions(),
-		parsedIncludes: make(map[*config.Include]*config.Config),
-		configRoot:     configRoot,
+		opts:       defaultOptions(),
+		includes:   newIncludeState(),
+		configRoot: configRoot,
 	}
-
 	for _, o := range opts {
 		o(parser)
 	}
-
-	parser.nextToken()
-	parser.nextToken()
-
 	parser.blockWrappers = config.BlockWrappers
 	parser.directiveWrappers = config.DirectiveWrappers
 	parser.includeWrappers = config.IncludeWrappers
 	return parser
 }
 
+// start sets the lexer of the parser and reads the first two tokens
+func (p *Parser) start(lexer *lexer) {
+	p.lexer = lexer
+	lexer.keepSource = p.opts.preserveTrivia
+	lexer.tolerant = p.opts.recoverErrors
+	p.nextToken()
+	p.nextToken()
+}
+
 func (p *Parser) nextToken() {
 	p.currentToken = p.followingToken
 	p.followingToken = p.lexer.scan()
@@ -189,10 +249,48 @@ func (p *Parser) Parse() (*config.Config, error) {
 		FilePath: p.lexer.file, //TODO: set filepath here,
 		Block:    parsedBlock,
 	}
+	if p.opts.preserveTrivia && p.lastBlock != nil {
+		c.Trailing = p.lastBlock.closing
+	}
+	c.Snapshot()
+	p.config = c
 	err = p.Close()
+	if err == nil && p.opts.recoverErrors {
+		if errs := p.collectErrors(); len(errs) > 0 {
+			return c, errs
+		}
+	}
 	return c, err
 }
 
+// collectErrors returns the errors of this file in source order, followed by those of the included files
+func (p *Parser) collectErrors() ErrorList {
+	errs := append(ErrorList{}, p.errors...)
+	errs = append(errs, p.lexer.errors...)
+	errs.sort()
+	return append(errs, p.includeErrors...)
+}
+
+// recordError keeps a syntax error to be returned by Parse
+func (p *Parser) recordError(err *SyntaxError) {
+	p.errors = append(p.errors, err)
+}
+
+// syntaxError creates an error positioned at a token
+func (p *Parser) syntaxError(t token.Token, directive, message string) *SyntaxError {
+	return &SyntaxError{
+		Message:   message,
+		Directive: directive,
+		Pos:       p.tokenRange(t).Start,
+	}
+}
+
+// unexpectedToken creates an error for the current token, which has no place where it was found
+func (p *Parser) unexpectedToken(directive string) *SyntaxError {
+	return p.syntaxError(p.currentToken, directive,
+		fmt.Sprintf("unexpected token %s (%s)", p.currentToken.Type.String(), p.currentToken.Literal))
+}
+
 // ParseBlock parse a block statement
 func (p *Parser) parseBlock(inBlock bool, isSkipValidDirective bool) (*config.Block, error) {
 
@@ -202,24 +300,79 @@ func (p *Parser) parseBlock(inBlock bool, isSkipValidDirective bool) (*config.Bl
 	var s config.IDirective
 	var err error
 	var line int
+	// prevEnd is the end of the previous statement, or of the opening brace
+	prevEnd, open := 0, token.Token{Offset: -1}
+	if inBlock {
+		// the current token is the opening brace
+		prevEnd, open = tokenEnd(p.currentToken), p.currentToken
+		p.nextToken()
+	}
 parsingLoop:
 	for {
 		switch {
 		case p.curTokenIs(token.EOF):
 			if inBlock {
-				return nil, errors.New("unexpected eof in block")
+				err := p.syntaxError(open, "", "unexpected eof in block")
+				if !p.opts.recoverErrors {
+					return nil, err
+				}
+				// only the innermost unclosed block is reported
+				if !p.eofReported {
+					p.recordError(err)
+					p.eofReported = true
+				}
 			}
+			p.lastBlock = &blockInfo{open: open, close: p.currentToken, closing: p.lexer.text(prevEnd, p.currentToken.Offset)}
 			break parsingLoop
 		case p.curTokenIs(token.LuaCode):
 			context.IsLuaBlock = true
 			context.LiteralCode = p.currentToken.Literal
 		case p.curTokenIs(token.BlockEnd):
+			if !inBlock {
+				// a '}' closing no block
+				err := p.unexpectedToken("")
+				if !p.opts.recoverErrors {
+					return nil, err
+				}
+				p.recordError(err)
+				break
+			}
+			p.lastBlock = &blockInfo{open: open, close: p.currentToken, closing: p.lexer.text(prevEnd, tokenEnd(p.currentToken))}
 			break parsingLoop
+		case p.curTokenIs(token.BlockStart):
+			// a '{' without a directive name
+			err := p.unexpectedToken("")
+			if !p.opts.recoverErrors {
+				return nil, err
+			}
+			p.recordError(err)
+			// its directives are kept in this block and its '}' is consumed, so that it does not close this block
+			stray, blockErr := p.parseBlock(true, isSkipValidDirective)
+			if blockErr != nil {
+				return nil, blockErr
+			}
+			context.Directives = append(context.Directives, stray.Directives...)
+			p.lastBlock = nil
+			prevEnd = tokenEnd(p.currentToken)
+			if p.curTokenIs(token.EOF) {
+				continue
+			}
 		case p.curTokenIs(token.Keyword) || p.curTokenIs(token.QuotedString):
+			start := p.currentToken.Offset
+			if len(p.commentBuffer) > 0 && p.commentStart >= prevEnd {
+				start = p.commentStart
+			}
+			name := p.currentToken
+			p.lastBlock = nil
 			s, err = p.parseStatement(isSkipValidDirective)
 			if err != nil {
 				return nil, err
 			}
+			p.setRange(s, name)
+			if p.opts.preserveTrivia {
+				p.setTrivia(s, prevEnd, start)
+			}
+			prevEnd = tokenEnd(p.currentToken)
 			if s.GetBlock() == nil {
 				s.SetParent(s)
 			} else {
@@ -229,15 +382,25 @@ parsingLoop:
 				for _, dir := range b.GetDirectives() {
 					dir.SetParent(s)
 				}
+				if block, ok := b.(*config.Block); ok {
+					block.SetParent(s)
+				}
 			}
 			line = p.currentToken.Line
 			s.SetLine(line)
 			context.Directives = append(context.Directives, s)
+			if s.GetBlock() == nil && (p.curTokenIs(token.BlockEnd) || p.curTokenIs(token.EOF)) {
+				// the statement was cut short while recovering from an error, the token belongs to this block
+				continue
+			}
 		case p.curTokenIs(token.Comment):
 			if p.opts.skipComments {
 				break
 			}
 			// outline comment
+			if len(p.commentBuffer) == 0 {
+				p.commentStart = p.currentToken.Offset
+			}
 			p.commentBuffer = append(p.commentBuffer, p.currentToken.Literal)
 		}
 		p.nextToken()
@@ -250,13 +413,33 @@ func (p *Parser) parseStatement(isSkipValidDirective bool) (config.IDirective, e
 	d := &config.Directive{
 		Name: p.currentToken.Literal,
 	}
+	name := p.currentToken
 
 	if !p.opts.skipValidDirectivesErr && !isSkipValidDirective {
 		_, ok := ValidDirectives[d.Name]
 		_, ok2 := p.opts.customDirectives[d.Name]
+		declared := p.opts.profile != nil && p.opts.profile.Declares(d.Name)
 
-		if !ok && !ok2 {
-			return nil, fmt.Errorf("unknown directive '%s' on line %d, column %d", d.Name, p.currentToken.Line, p.currentToken.Column)
+		message := ""
+		switch {
+		case !ok && !ok2 && !declared && !config.IsKnownDirective(d.Name):
+			message = fmt.Sprintf("unknown directive '%s'", d.Name)
+		case p.opts.profile != nil && !ok2:
+			// a known directive the target build does not have
+			for _, issue := range p.opts.profile.CheckDirective(d.Name, nil) {
+				if issue.Level == config.ValidationError {
+					message = issue.Message
+					break
+				}
+			}
+		}
+		if message != "" {
+			err := p.syntaxError(name, d.Name, message)
+			if !p.opts.recoverErrors {
+				return nil, err
+			}
+			// keep the directive, it is most likely a typo or a module the parser does not know
+			p.recordError(err)
 		}
 	}
 
@@ -272,47 +455,75 @@ func (p *Parser) parseStatement(isSkipValidDirective bool) (config.IDirective, e
 	}
 
 	directiveLineIndex := p.currentToken.Line // keep track of the line index of the directive
+	prevEnd := tokenEnd(p.currentToken)       // end of the previous token that is not a line break
+	last := p.currentToken                    // the previous token that is not a line break
 	// Parse parameters until reaching the semicolon that ends the directive.
 	for {
 		p.nextToken()
 		if p.currentToken.IsParameterEligible() {
+			last = p.currentToken
 			param := config.Parameter{
 				Value:             p.currentToken.Literal,
 				Type:              config.DetectParameterType(p.currentToken.Literal),
 				RelativeLineIndex: p.currentToken.Line - directiveLineIndex,
 			}
+			if p.opts.preserveTrivia {
+				param.Leading = p.lexer.text(prevEnd, p.currentToken.Offset)
+			}
+			param.Range = p.tokenRange(p.currentToken)
+			prevEnd = tokenEnd(p.currentToken)
 			d.Parameters = append(d.Parameters, param)
 			if p.currentToken.Is(token.BlockEnd) {
 				return d, nil
 			}
 		} else if p.curTokenIs(token.Semicolon) {
+			p.terminator = p.currentToken
 			// inline comment in following token
 			if !p.opts.skipComments {
 				if p.followingTokenIs(token.Comment) && p.followingToken.Line == p.currentToken.Line {
 					// if following token is a comment, then it is an inline comment, fetch next token
+					semicolonEnd := tokenEnd(p.currentToken)
 					p.nextToken()
-					d.SetInlineComment(config.InlineComment{
+					comment := config.InlineComment{
 						Value:             p.currentToken.Literal,
 						RelativeLineIndex: p.currentToken.Line - directiveLineIndex,
-					})
+					}
+					if p.opts.preserveTrivia {
+						comment.Leading = p.lexer.text(semicolonEnd, p.currentToken.Offset)
+					}
+					d.SetInlineComment(comment)
 				}
 			}
 			if iw, ok := p.includeWrappers[d.Name]; ok {
 				include, err := iw(d)
 				if err != nil {
-					return nil, err
+					return p.recoverWrapper(d, name, err)
+				}
+				result, err := p.ParseInclude(include.(*config.Include))
+				if err != nil {
+					return p.recoverWrapper(include, name, err)
 				}
-				return p.ParseInclude(include.(*config.Include))
+				return result, nil
 			} else if dw, ok := p.directiveWrappers[p.getContextAwareWrapperKey(d.Name)]; ok {
-				return dw(d)
+				result, err := dw(d)
+				if err != nil {
+					return p.recoverWrapper(d, name, err)
+				}
+				return result, nil
 			}
 			return d, nil
 		} else if p.curTokenIs(token.Comment) {
+			last = p.currentToken
 			// param comment
-			d.SetInlineComment(config.InlineComment{
+			comment := config.InlineComment{
 				Value:             p.currentToken.Literal,
 				RelativeLineIndex: p.currentToken.Line - directiveLineIndex,
-			})
+			}
+			if p.opts.preserveTrivia {
+				comment.Leading = p.lexer.text(prevEnd, p.currentToken.Offset)
+			}
+			d.SetInlineComment(comment)
+			prevEnd = tokenEnd(p.currentToken)
 		} else if p.curTokenIs(token.BlockStart) {
 			_, blockSkip1 := SkipValidBlocks[d.Name]
 			_, blockSkip2 := p.opts.skipValidSubDirectiveBlock[d.Name]
@@ -328,6 +539,7 @@ func (p *Parser) parseStatement(isSkipValidDirective bool) (config.IDirective, e
 				}
 
 				// Skip past the opening brace
+				open := p.currentToken
 				p.nextToken()
 
 				// Collect all content until the matching closing brace
@@ -360,8 +572,20 @@ func (p *Parser) parseStatement(isSkipValidDirective bool) (config.IDirective, e
 					p.nextToken()
 				}
 
+				if p.curTokenIs(token.EOF) {
+					err := p.syntaxError(open, d.Name, "unexpected eof in block")
+					if !p.opts.recoverErrors {
+						return nil, err
+					}
+					if !p.eofReported {
+						p.recordError(err)
+						p.eofReported = true
+					}
+				}
+
 				b.LiteralCode = strings.TrimSpace(luaCode.String())
 				d.Block = b
+				p.lastBlock = &blockInfo{open: open, close: p.currentToken, closing: p.lexer.text(tokenEnd(open), tokenEnd(p.currentToken))}
 
 				// Use the appropriate wrapper based on the directive name
 				if strings.HasSuffix(d.Name, "_by_lua_block") {
@@ -397,7 +621,10 @@ func (p *Parser) parseStatement(isSkipValidDirective bool) (config.IDirective, e
 				if shouldPushContext {
 					p.popContext()
 				}
-				return result, err
+				if err != nil {
+					return p.recoverWrapper(d, name, err)
+				}
+				return result, nil
 			}
 
 			if shouldPushContext {
@@ -407,15 +634,46 @@ func (p *Parser) parseStatement(isSkipValidDirective bool) (config.IDirective, e
 		} else if p.currentToken.Is(token.EndOfLine) {
 			continue
 		} else {
-			return nil, fmt.Errorf("unexpected token %s (%s) on line %d, column %d", p.currentToken.Type.String(), p.currentToken.Literal, p.currentToken.Line, p.currentToken.Column)
+			err := p.unexpectedToken(d.Name)
+			if !p.opts.recoverErrors {
+				return nil, err
+			}
+			p.recordError(err)
+			p.resync(last)
+			return d, nil
 		}
 	}
 }
 
+// resync skips the rest of a broken statement, up to and including the next ';'.
+// It stops in front of a '}' or the end of file, which belong to the enclosing block
+func (p *Parser) resync(last token.Token) {
+	for !p.curTokenIs(token.Semicolon) && !p.curTokenIs(token.BlockEnd) && !p.curTokenIs(token.EOF) {
+		if !p.curTokenIs(token.EndOfLine) {
+			last = p.currentToken
+		}
+		p.nextToken()
+	}
+	p.terminator = last
+	if p.curTokenIs(token.Semicolon) {
+		p.terminator = p.currentToken
+	}
+}
+
+// recoverWrapper handles a directive that could not be turned into its typed wrapper,
+// when recovering from errors the plain directive is kept instead
+func (p *Parser) recoverWrapper(d config.IDirective, name token.Token, err error) (config.IDirective, error) {
+	if !p.opts.recoverErrors {
+		return nil, err
+	}
+	p.recordError(p.syntaxError(name, d.GetName(), err.Error()))
+	return d, nil
+}
+
 // 中文解释: ParseInclude 解析 include 指令
 // 如果配置选项 parseInclude 为 true，则会解析 include 指令
 // 如果 include 路径不是绝对路径，则将其与配置根目录拼接
-// 然后使用 filepath.Glob 查找匹配的文件路径
+// 然后使用 filepath.Glob 查找匹配的文件路径 (使用 WithFS 时在该文件系统中查找)
 // 如果解析过程中发生错误且配置选项 skipIncludeParsingErr 为 false，则返回错误
 // 对于每个匹配的 include 路径，创建一个新的 Parser 实例
 // 并调用 Parse 方法解析配置
@@ -423,27 +681,23 @@ func (p *Parser) parseStatement(isSkipValidDirective bool) (config.IDirective, e
 // 如果解析过程中发生错误且配置选项 skipIncludeParsingErr 为 false，则返回错误
 func (p *Parser) ParseInclude(include *config.Include) (config.IDirective, error) {
 	if p.opts.parseInclude {
-		includePath := include.IncludePath
-		if !filepath.IsAbs(includePath) {
-			includePath = filepath.Join(p.configRoot, include.IncludePath)
-		}
-		includePaths, err := filepath.Glob(includePath)
+		includePath := joinPath(p.opts.fsys, p.configRoot, include.IncludePath)
+		includePaths, err := globFiles(p.opts.fsys, includePath)
 		if err != nil && !p.opts.skipIncludeParsingErr {
 			return nil, err
 		}
+		p.includes.matches[include] = includePaths
 		for _, includePath := range includePaths {
-			if conf, ok := p.parsedIncludes[include]; ok {
-				// same file includes itself? don't blow up the parser
-				if conf == nil {
-					continue
-				}
-			} else {
-				p.parsedIncludes[include] = nil
+			stack := p.fileStack()
+			if cycle := includeCycle(stack, includePath); cycle != nil {
+				// a file including itself, directly or not, would never end
+				p.includes.cycles = append(p.includes.cycles, IncludeCycle{Path: cycle, Include: include})
+				continue
 			}
 
 			parser, err := NewParser(includePath,
 				WithSameOptions(p),
-				withParsedIncludes(p.parsedIncludes),
+				withIncludeState(p.includes, append(stack, filepath.Clean(includePath))),
 				withConfigRoot(p.configRoot),
 			)
 
@@ -455,18 +709,92 @@ func (p *Parser) ParseInclude(include *config.Include) (config.IDirective, error
 			}
 
 			config, err := parser.Parse()
-			if err != nil {
+			if errs, ok := err.(ErrorList); ok && p.opts.recoverErrors {
+				p.includeErrors = append(p.includeErrors, errs...)
+			} else if err != nil {
 				return nil, err
 			}
-			//TODO: link parent config or include direcitve?
+			// link the included file and its top level directives back to the include
+			config.SetParent(include)
+			for _, d := range config.GetDirectives() {
+				d.SetParent(include)
+			}
 
-			p.parsedIncludes[include] = config
 			include.Configs = append(include.Configs, config)
 		}
 	}
 	return include, nil
 }
 
+// setTrivia attaches the source text of the statement that just got parsed
+func (p *Parser) setTrivia(d config.IDirective, prevEnd, start int) {
+	holder, ok := d.(config.TriviaHolder)
+	if !ok {
+		return
+	}
+	end := tokenEnd(p.currentToken)
+	trivia := &config.Trivia{
+		Offset:  start,
+		Leading: p.lexer.text(prevEnd, start),
+		Text:    p.lexer.text(start, end),
+	}
+	if p.lastBlock != nil && d.GetBlock() != nil {
+		trivia.Header = p.lexer.text(start, tokenEnd(p.lastBlock.open))
+		trivia.Closing = p.lastBlock.closing
+	}
+	trivia.Snapshot(d)
+	holder.SetTrivia(trivia)
+}
+
+// setRange attaches the source range of the statement that just got parsed
+func (p *Parser) setRange(d config.IDirective, name token.Token) {
+	holder, ok := d.(config.RangeHolder)
+	if !ok {
+		return
+	}
+	r := &config.DirectiveRange{
+		Name: p.tokenRange(name),
+	}
+	r.Start = r.Name.Start
+	if p.lastBlock != nil && d.GetBlock() != nil {
+		r.BlockStart = p.tokenRange(p.lastBlock.open)
+		r.BlockEnd = p.tokenRange(p.lastBlock.close)
+		r.End = r.BlockEnd.End
+	} else {
+		r.End = p.tokenRange(p.terminator).End
+	}
+	holder.SetRange(r)
+}
+
+// tokenRange returns the source range of a token
+func (p *Parser) tokenRange(t token.Token) config.Range {
+	start := config.Position{
+		File:   p.lexer.file,
+		Line:   t.Line,
+		Column: t.Column,
+		Offset: t.Offset,
+	}
+	end := start
+	end.Offset = tokenEnd(t)
+	for _, ch := range t.Literal {
+		if ch == '\n' {
+			end.Line++
+			end.Column = 1
+		} else {
+			end.Column++
+		}
+	}
+	return config.Range{Start: start, End: end}
+}
+
+// tokenEnd returns the byte offset right after the token
+func tokenEnd(t token.Token) int {
+	if t.Is(token.EOF) {
+		return t.Offset
+	}
+	return t.Offset + len(t.Literal)
+}
+
 // Close closes the file handler and releases the resources
 func (p *Parser) Close() (err error) {
 	if p.file != nil {
@@ -3,6 +3,7 @@ package parser
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/lefeck/gonginx/config"
@@ -249,6 +250,50 @@ func TestParser_IncludeParserErrorSkip(t *testing.T) {
 	assert.Equal(t, len(inc.Configs), 0)
 }
 
+func TestParser_Ranges(t *testing.T) {
+	t.Parallel()
+	p := NewStringParser("user  nginx;\nhttp {\n    server {\n        server_name \"a b\";\n    }\n}\n")
+	c, err := p.Parse()
+	assert.NilError(t, err)
+
+	user := config.RangeOf(c.FindDirectives("user")[0])
+	assert.Equal(t, user.Start, config.Position{Line: 1, Column: 1, Offset: 0})
+	assert.Equal(t, user.End, config.Position{Line: 1, Column: 13, Offset: 12})
+	assert.Equal(t, user.Name.End.Column, 5)
+	assert.Assert(t, !user.BlockStart.IsValid())
+
+	serverName := c.FindDirectives("server_name")[0]
+	param := serverName.GetParameters()[0]
+	assert.Equal(t, param.GetRange().String(), "4:21-4:26")
+	assert.Equal(t, config.RangeOf(serverName).String(), "4:9-4:27")
+
+	server := config.RangeOf(c.FindDirectives("server")[0])
+	assert.Equal(t, server.BlockStart.Start.Line, 3)
+	assert.Equal(t, server.BlockEnd.String(), "5:5-5:6")
+	assert.Equal(t, server.End.Offset, 65)
+	assert.Assert(t, server.Contains(config.RangeOf(serverName).Start))
+}
+
+func TestParser_RangesInInclude(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	included := filepath.Join(dir, "included.conf")
+	assert.NilError(t, os.WriteFile(included, []byte("\n  gzip on;\n"), 0o644))
+
+	p := NewStringParser("include "+included+";", WithIncludeParsing())
+	c, err := p.Parse()
+	assert.NilError(t, err)
+
+	include := c.FindDirectives("include")[0]
+	assert.Equal(t, config.RangeOf(include).File(), "")
+
+	gzip := c.FindDirectives("gzip")[0]
+	r := config.RangeOf(gzip)
+	assert.Equal(t, r.File(), included)
+	assert.Equal(t, r.String(), included+":2:3-2:11")
+}
+
 func Benchmark_ParseFullExample(t *testing.B) {
 	fullconf := `user www www;
 worker_processes 5;
@@ -717,3 +762,146 @@ local foo = if -- comment
     }
 }`, s)
 }
+
+func TestParser_ErrorRecovery(t *testing.T) {
+	t.Parallel()
+	p := NewStringParser(`user nginx;
+http {
+    servr_name a;
+    server {
+        listen 80
+    }
+    server {
+        listen 81;
+        location / {
+            root /var/www;
+    }
+}
+}
+worker_processes 2;
+}
+pid /run/nginx.pid;
+`, WithErrorRecovery())
+	c, err := p.Parse()
+	assert.Assert(t, c != nil)
+
+	errs, ok := err.(ErrorList)
+	assert.Assert(t, ok, "expected an ErrorList, got %v", err)
+	assert.Equal(t, len(errs), 3)
+	assert.Equal(t, errs[0].Error(), "unknown directive 'servr_name' on line 3, column 5")
+	assert.Equal(t, errs[0].Directive, "servr_name")
+	assert.Equal(t, errs[1].Error(), "unexpected token BlockEnd (}) on line 6, column 5")
+	assert.Equal(t, errs[1].Directive, "listen")
+	assert.Equal(t, errs[2].Error(), "unexpected token BlockEnd (}) on line 15, column 1")
+
+	// everything around the errors is kept
+	assert.Equal(t, len(c.FindDirectives("servr_name")), 1)
+	assert.Equal(t, len(c.FindDirectives("server")), 2)
+	listens := c.FindDirectives("listen")
+	assert.Equal(t, len(listens), 2)
+	assert.Equal(t, listens[0].GetParameters()[0].GetValue(), "80")
+	assert.Equal(t, config.RangeOf(listens[0]).End.Line, 5)
+	assert.Equal(t, len(c.FindDirectives("root")), 1)
+	assert.Equal(t, len(c.FindDirectives("worker_processes")), 1)
+	assert.Equal(t, len(c.FindDirectives("pid")), 1)
+}
+
+func TestParser_ErrorRecoveryMatchesStrict(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		conf   string
+		errors []string // the errors of the recovery mode, strict mode stops at the first one
+	}{
+		{"user nginx;\nhttp {\n}\n", nil},
+		{"{ }", []string{"unexpected token BlockStart ({) on line 1, column 1"}},
+		{"http { { } }", []string{"unexpected token BlockStart ({) on line 1, column 8"}},
+		{"}\nuser nginx;", []string{"unexpected token BlockEnd (}) on line 1, column 1"}},
+		{"http {\n}\n}\n", []string{"unexpected token BlockEnd (}) on line 3, column 1"}},
+		{"{ { ;;", []string{
+			"unexpected token BlockStart ({) on line 1, column 1",
+			"unexpected token BlockStart ({) on line 1, column 3",
+			"unexpected eof in block on line 1, column 3",
+		}},
+		{"http {\n    server {\n", []string{"unexpected eof in block on line 2, column 12"}},
+	}
+	for _, tt := range tests {
+		_, strictErr := NewStringParser(tt.conf).Parse()
+		c, err := NewStringParser(tt.conf, WithErrorRecovery()).Parse()
+		if tt.errors == nil {
+			assert.NilError(t, strictErr, tt.conf)
+			assert.NilError(t, err, tt.conf)
+			continue
+		}
+		assert.Assert(t, c != nil, tt.conf)
+		assert.Error(t, strictErr, tt.errors[0], tt.conf)
+		errs, ok := err.(ErrorList)
+		assert.Assert(t, ok, "%s: expected an ErrorList, got %v", tt.conf, err)
+		messages := make([]string, len(errs))
+		for i, e := range errs {
+			messages[i] = e.Error()
+		}
+		assert.DeepEqual(t, messages, tt.errors)
+	}
+
+	// the directives of a block without a name are kept in the enclosing block
+	c, _ := NewStringParser("http {\n    { gzip on; }\n    sendfile on;\n}\n", WithErrorRecovery()).Parse()
+	http := c.FindDirectives("http")[0]
+	assert.Equal(t, len(http.GetBlock().GetDirectives()), 2)
+	assert.Equal(t, http.GetBlock().GetDirectives()[0].GetParent(), http)
+}
+
+func TestParser_ErrorRecoveryUnclosedBlock(t *testing.T) {
+	t.Parallel()
+	p := NewStringParser("http {\n    server {\n        listen 80;\n", WithErrorRecovery())
+	c, err := p.Parse()
+	errs, ok := err.(ErrorList)
+	assert.Assert(t, ok, "expected an ErrorList, got %v", err)
+	assert.Equal(t, len(errs), 1)
+	assert.Equal(t, errs[0].Error(), "unexpected eof in block on line 2, column 12")
+	assert.Equal(t, len(c.FindDirectives("listen")), 1)
+}
+
+func TestParser_ErrorRecoveryUnclosedQuote(t *testing.T) {
+	t.Parallel()
+	p := NewStringParser("user nginx;\nerror_log \"/var/log;\n", WithErrorRecovery())
+	c, err := p.Parse()
+	errs, ok := err.(ErrorList)
+	assert.Assert(t, ok, "expected an ErrorList, got %v", err)
+	assert.Equal(t, len(errs), 2)
+	assert.Equal(t, errs[0].Error(), "unexpected end of file while scanning a string, maybe an unclosed quote? on line 2, column 11")
+	assert.Assert(t, strings.HasPrefix(errs[1].Message, "unexpected token Eof"))
+	assert.Equal(t, len(c.FindDirectives("user")), 1)
+	assert.Equal(t, len(c.FindDirectives("error_log")), 1)
+}
+
+func TestParser_ErrorRecoveryWrapper(t *testing.T) {
+	t.Parallel()
+	p := NewStringParser("http {\n    map $a {\n        default 0;\n    }\n    gzip on;\n}\n", WithErrorRecovery())
+	c, err := p.Parse()
+	errs, ok := err.(ErrorList)
+	assert.Assert(t, ok, "expected an ErrorList, got %v", err)
+	assert.Equal(t, len(errs), 1)
+	assert.Equal(t, errs[0].Pos.Line, 2)
+	assert.Equal(t, errs[0].Directive, "map")
+	_, isDirective := c.FindDirectives("map")[0].(*config.Directive)
+	assert.Assert(t, isDirective)
+	assert.Equal(t, len(c.FindDirectives("gzip")), 1)
+}
+
+func TestParser_ErrorRecoveryInclude(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	included := filepath.Join(dir, "included.conf")
+	assert.NilError(t, os.WriteFile(included, []byte("gzip on;\ngzp off;\n"), 0o644))
+
+	p := NewStringParser("include "+included+";\nfoo bar;\n", WithIncludeParsing(), WithErrorRecovery())
+	c, err := p.Parse()
+	errs, ok := err.(ErrorList)
+	assert.Assert(t, ok, "expected an ErrorList, got %v", err)
+	assert.Equal(t, len(errs), 2)
+	assert.Equal(t, errs[0].Directive, "foo")
+	assert.Equal(t, errs[1].Directive, "gzp")
+	assert.Equal(t, errs[1].Pos.File, included)
+	assert.Equal(t, len(c.FindDirectives("gzip")), 1)
+}
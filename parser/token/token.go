@@ -63,6 +63,7 @@ type Token struct {
 	Literal string
 	Line    int
 	Column  int
+	Offset  int // byte offset of the first character
 }
 
 func (t Token) String() string {
@@ -0,0 +1,2 @@
+// Package sim simulates how nginx handles requests with a parsed configuration.
+package sim
@@ -0,0 +1,382 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// Request is the part of an HTTP request nginx looks at to pick a server and a location
+type Request struct {
+	Scheme  string            // "http" or "https", it gives the default port
+	Host    string            // the Host header, with or without a port; Headers["Host"] when empty
+	Port    int               // the port the request came in on, 80 or 443 by scheme when zero
+	Path    string            // the request URI, a query string is ignored
+	Headers map[string]string // the request headers
+}
+
+// Match is the server and location nginx selects for a request
+type Match struct {
+	Server        *config.Server
+	ServerName    string // the server_name that matched the host, empty when the default server was used
+	DefaultServer bool   // the server was selected as default server of the port
+	Location      *config.Location
+	Locations     []*config.Location // the selected location and the locations around it, outermost first
+	Captures      map[string]string  // the captures of the regex that selected the location or the server name
+	// Directives are the directives in effect in the selected location, with the ones inherited
+	// from the server and http levels, see config.EffectiveIn.
+	Directives []config.IDirective
+	Warnings   []string // regexes that could not be evaluated and were skipped
+
+	http config.IDirective
+}
+
+// Route returns the server and location handling a request, following the rules of nginx:
+// the port selects the servers, then the host is matched against their server_name (exact
+// names, longest leading wildcard, longest trailing wildcard, then regexes in order), falling
+// back to the default server of the port. In the server the location is selected by exact
+// match, longest prefix, regexes in order unless the prefix is a ^~ one, and nested locations.
+// The listen addresses are not compared, if blocks are not evaluated.
+func Route(conf *config.Config, req Request) (*Match, error) {
+	if conf == nil {
+		return nil, errors.New("no config")
+	}
+	http := findHTTP(directives(conf.Block))
+	if http == nil {
+		return nil, errors.New("config has no http block")
+	}
+
+	port := req.Port
+	if port == 0 {
+		port = 80
+		if strings.EqualFold(req.Scheme, "https") {
+			port = 443
+		}
+	}
+	m := &Match{Captures: map[string]string{}, http: http}
+	if err := m.selectServer(http, port, requestHost(req)); err != nil {
+		return nil, err
+	}
+	m.locate(requestPath(req.Path))
+	return m, nil
+}
+
+// locate selects the location of path in the server of m
+func (m *Match) locate(path string) {
+	m.Locations = nil
+	m.selectLocations(directives(m.Server.Block), path)
+	m.setLocations(m.Locations)
+}
+
+// setLocations makes locations the selected ones, the last one being the innermost
+func (m *Match) setLocations(locations []*config.Location) {
+	m.Locations = locations
+	m.Location = nil
+	if len(locations) > 0 {
+		m.Location = locations[len(locations)-1]
+	}
+	m.Directives = m.effectiveDirectives()
+}
+
+// directives returns the directives of a block with the directives of included files in place of the include
+func directives(block config.IBlock) []config.IDirective {
+	if block == nil {
+		return nil
+	}
+	var all []config.IDirective
+	for _, d := range block.GetDirectives() {
+		if include, ok := d.(*config.Include); ok {
+			for _, c := range include.Configs {
+				all = append(all, directives(c.Block)...)
+			}
+			continue
+		}
+		all = append(all, d)
+	}
+	return all
+}
+
+// findHTTP returns the http block of the main context
+func findHTTP(main []config.IDirective) config.IDirective {
+	for _, d := range main {
+		if d.GetName() == "http" && d.GetBlock() != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// requestHost returns the lower cased host name of a request, without port and trailing dot
+func requestHost(req Request) string {
+	host := req.Host
+	if host == "" {
+		for name, value := range req.Headers {
+			if strings.EqualFold(name, "Host") {
+				host = value
+			}
+		}
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// requestPath returns the path of a request URI
+func requestPath(uri string) string {
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+	if uri == "" {
+		return "/"
+	}
+	return uri
+}
+
+// listenPort returns the port of a listen directive and whether it is marked as default server
+func listenPort(listen config.IDirective) (int, bool, bool) {
+	params := listen.GetParameters()
+	if len(params) == 0 {
+		return 0, false, false
+	}
+	address := params[0].GetValue()
+	if strings.HasPrefix(address, "unix:") {
+		return 0, false, false
+	}
+	port := 80
+	if n, err := strconv.Atoi(address); err == nil {
+		port = n
+	} else if i := strings.LastIndex(address, ":"); i >= 0 && !strings.HasSuffix(address, "]") {
+		n, err := strconv.Atoi(address[i+1:])
+		if err != nil {
+			return 0, false, false
+		}
+		port = n
+	}
+	isDefault := false
+	for _, param := range params[1:] {
+		if param.GetValue() == "default_server" || param.GetValue() == "default" {
+			isDefault = true
+		}
+	}
+	return port, isDefault, true
+}
+
+// selectServer picks the server of the port whose server_name matches host
+func (m *Match) selectServer(http config.IDirective, port int, host string) error {
+	var candidates []*config.Server
+	var fallback *config.Server
+	for _, d := range directives(http.GetBlock()) {
+		server, ok := d.(*config.Server)
+		if !ok {
+			continue
+		}
+		listens := 0
+		for _, listen := range directives(server.Block) {
+			if listen.GetName() != "listen" {
+				continue
+			}
+			listens++
+			if p, isDefault, ok := listenPort(listen); ok && p == port {
+				candidates = append(candidates, server)
+				if isDefault && fallback == nil {
+					fallback = server
+				}
+				break
+			}
+		}
+		// a server without listen listens on port 80
+		if listens == 0 && port == 80 {
+			candidates = append(candidates, server)
+		}
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no server listens on port %d", port)
+	}
+
+	var exact, leading, trailing, regex *config.Server
+	var exactName, leadingName, trailingName, regexName string
+	var regexCaptures map[string]string
+	for _, server := range candidates {
+		for _, d := range directives(server.Block) {
+			if d.GetName() != "server_name" {
+				continue
+			}
+			for _, param := range d.GetParameters() {
+				value := strings.Trim(param.GetValue(), `"'`)
+				name := strings.ToLower(value)
+				switch {
+				case name == host:
+					if exact == nil {
+						exact, exactName = server, param.GetValue()
+					}
+				case strings.HasPrefix(name, "~"):
+					if regex != nil {
+						continue
+					}
+					// server names are not case sensitive, the regex itself is kept as written
+					re, err := compileRegex(value[1:], true)
+					if err != nil {
+						m.Warnings = append(m.Warnings, err.Error())
+						continue
+					}
+					if captures, ok := matchRegex(re, host); ok {
+						regex, regexName, regexCaptures = server, param.GetValue(), captures
+					}
+				case strings.HasPrefix(name, "*.") || strings.HasPrefix(name, "."):
+					suffix := strings.TrimPrefix(name, "*")
+					matched := strings.HasSuffix(host, suffix) || (name[0] == '.' && host == name[1:])
+					if matched && len(suffix) > len(strings.TrimPrefix(leadingName, "*")) {
+						leading, leadingName = server, param.GetValue()
+					}
+				case strings.HasSuffix(name, ".*"):
+					prefix := strings.TrimSuffix(name, "*")
+					if strings.HasPrefix(host, prefix) && len(prefix) > len(strings.TrimSuffix(trailingName, "*")) {
+						trailing, trailingName = server, param.GetValue()
+					}
+				}
+			}
+		}
+	}
+
+	switch {
+	case exact != nil:
+		m.Server, m.ServerName = exact, exactName
+	case leading != nil:
+		m.Server, m.ServerName = leading, leadingName
+	case trailing != nil:
+		m.Server, m.ServerName = trailing, trailingName
+	case regex != nil:
+		m.Server, m.ServerName = regex, regexName
+		for name, value := range regexCaptures {
+			m.Captures[name] = value
+		}
+	case fallback != nil:
+		m.Server, m.DefaultServer = fallback, true
+	default:
+		m.Server, m.DefaultServer = candidates[0], true
+	}
+	return nil
+}
+
+// locationKind splits the modifier from the match of a location, like nginx does for "location =/"
+func locationKind(l *config.Location) (string, string) {
+	if l.Modifier != "" {
+		return l.Modifier, l.Match
+	}
+	for _, modifier := range []string{"=", "^~", "~*", "~", "@"} {
+		if strings.HasPrefix(l.Match, modifier) && len(l.Match) > len(modifier) {
+			return modifier, l.Match[len(modifier):]
+		}
+	}
+	return "", l.Match
+}
+
+// selectLocations looks for the location of path among the directives of a server or a location,
+// appending the selected locations to m.Locations. It returns true when the search is over:
+// an exact location or a regex location matched.
+func (m *Match) selectLocations(block []config.IDirective, path string) bool {
+	var prefix *config.Location
+	var prefixLen int
+	var regexes []*config.Location
+	for _, d := range block {
+		l, ok := d.(*config.Location)
+		if !ok {
+			continue
+		}
+		modifier, match := locationKind(l)
+		switch modifier {
+		case "=":
+			if match == path {
+				m.Locations = append(m.Locations, l)
+				return true
+			}
+		case "", "^~":
+			if strings.HasPrefix(path, match) && (prefix == nil || len(match) > prefixLen) {
+				prefix, prefixLen = l, len(match)
+			}
+		case "~", "~*":
+			regexes = append(regexes, l)
+		}
+	}
+
+	depth := len(m.Locations)
+	noRegex := false
+	if prefix != nil {
+		m.Locations = append(m.Locations, prefix)
+		modifier, _ := locationKind(prefix)
+		noRegex = modifier == "^~"
+		if m.selectLocations(directives(prefix.GetBlock()), path) {
+			return true
+		}
+	}
+	if noRegex {
+		return false
+	}
+	for _, l := range regexes {
+		modifier, match := locationKind(l)
+		re, err := compileRegex(match, modifier == "~*")
+		if err != nil {
+			m.Warnings = append(m.Warnings, err.Error())
+			continue
+		}
+		captures, ok := matchRegex(re, path)
+		if !ok {
+			continue
+		}
+		// a regex location replaces the prefix location and what was found in it
+		m.Locations = append(m.Locations[:depth], l)
+		for name, value := range captures {
+			m.Captures[name] = value
+		}
+		m.selectLocations(directives(l.GetBlock()), path)
+		return true
+	}
+	return false
+}
+
+// compileRegex compiles a PCRE regex of the config with the Go regexp syntax
+func compileRegex(expr string, caseInsensitive bool) (*regexp.Regexp, error) {
+	expr = strings.Trim(expr, `"'`)
+	if caseInsensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate regex %q: %v", expr, err)
+	}
+	return re, nil
+}
+
+// matchRegex matches s against re, returning the numbered and named captures
+func matchRegex(re *regexp.Regexp, s string) (map[string]string, bool) {
+	groups := re.FindStringSubmatch(s)
+	if groups == nil {
+		return nil, false
+	}
+	captures := make(map[string]string, len(groups))
+	for i, name := range re.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+		captures[strconv.Itoa(i)] = groups[i]
+		if name != "" {
+			captures[name] = groups[i]
+		}
+	}
+	return captures, true
+}
+
+// effectiveDirectives returns the directives in effect in the selected location
+func (m *Match) effectiveDirectives() []config.IDirective {
+	levels := []config.IDirective{m.http, m.Server}
+	for _, l := range m.Locations {
+		levels = append(levels, l)
+	}
+	return config.Directives(config.EffectiveIn(levels...))
+}
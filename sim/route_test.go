@@ -0,0 +1,153 @@
+package sim_test
+
+import (
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/sim"
+	"gotest.tools/v3/assert"
+)
+
+const routeConfig = `http {
+    gzip on;
+    add_header X-Frame-Options DENY;
+    server {
+        listen 80;
+        server_name fallback.example.com;
+    }
+    server {
+        listen 80 default_server;
+        server_name _;
+        return 444;
+    }
+    server {
+        listen 80;
+        listen 443 ssl;
+        server_name www.example.com *.example.com;
+        root /srv/www;
+        add_header X-Server www;
+        location / {
+            try_files $uri /index.html;
+        }
+        location = /health {
+            return 200 ok;
+        }
+        location ^~ /static/ {
+            expires 30d;
+        }
+        location ~* \.(png|jpg)$ {
+            expires 7d;
+        }
+        location /api/ {
+            proxy_pass http://api;
+            location ~ ^/api/(?<version>v[0-9]+)/ {
+                proxy_pass http://api_$version;
+            }
+            location /api/admin/ {
+                gzip off;
+                add_header X-Admin 1;
+            }
+        }
+    }
+    server {
+        listen 80;
+        server_name www.example.*;
+    }
+    server {
+        listen 80;
+        server_name ~^(?<tenant>[a-z]+)\.apps\.net$;
+    }
+}`
+
+func route(t *testing.T, req sim.Request) *sim.Match {
+	t.Helper()
+	conf, err := parser.NewStringParser(routeConfig).Parse()
+	assert.NilError(t, err)
+	m, err := sim.Route(conf, req)
+	assert.NilError(t, err)
+	return m
+}
+
+func names(m *sim.Match) []string {
+	var names []string
+	for _, d := range m.Directives {
+		names = append(names, d.GetName())
+	}
+	return names
+}
+
+func TestRoute_Server(t *testing.T) {
+	t.Parallel()
+
+	for host, want := range map[string]string{
+		"www.example.com":       "www.example.com",
+		"WWW.Example.com.:8080": "www.example.com",
+		"shop.example.com":      "*.example.com",
+		"www.example.org":       "www.example.*",
+		"acme.apps.net":         `~^(?<tenant>[a-z]+)\.apps\.net$`,
+		"fallback.example.com":  "fallback.example.com",
+	} {
+		m := route(t, sim.Request{Host: host, Path: "/"})
+		assert.Equal(t, m.ServerName, want, host)
+		assert.Assert(t, !m.DefaultServer)
+	}
+
+	m := route(t, sim.Request{Host: "unknown.org", Path: "/"})
+	assert.Assert(t, m.DefaultServer)
+	assert.Equal(t, m.Server.Block.FindDirectives("return")[0].GetParameters()[0].GetValue(), "444")
+
+	m = route(t, sim.Request{Headers: map[string]string{"host": "acme.apps.net"}, Path: "/"})
+	assert.Equal(t, m.Captures["tenant"], "acme")
+
+	// only the www server listens on 443
+	m = route(t, sim.Request{Scheme: "https", Host: "unknown.org", Path: "/"})
+	assert.Assert(t, m.DefaultServer)
+	assert.Equal(t, m.Server.Block.FindDirectives("root")[0].GetParameters()[0].GetValue(), "/srv/www")
+
+	conf, err := parser.NewStringParser(routeConfig).Parse()
+	assert.NilError(t, err)
+	_, err = sim.Route(conf, sim.Request{Port: 8443, Path: "/"})
+	assert.ErrorContains(t, err, "no server listens on port 8443")
+}
+
+func TestRoute_Location(t *testing.T) {
+	t.Parallel()
+
+	location := func(path string) string {
+		m := route(t, sim.Request{Host: "www.example.com", Path: path})
+		if m.Location == nil {
+			return ""
+		}
+		return m.Location.Modifier + " " + m.Location.Match
+	}
+	assert.Equal(t, location("/health"), "= /health")
+	assert.Equal(t, location("/health/check"), " /")
+	assert.Equal(t, location("/static/logo.png"), "^~ /static/")
+	assert.Equal(t, location("/img/logo.PNG?v=1"), `~* \.(png|jpg)$`)
+	assert.Equal(t, location("/api/users"), " /api/")
+	assert.Equal(t, location("/api/v2/users"), "~ ^/api/(?<version>v[0-9]+)/")
+	assert.Equal(t, location("/api/admin/users"), " /api/admin/")
+	// a regex of the server wins over a nested prefix location
+	assert.Equal(t, location("/api/admin/logo.png"), `~* \.(png|jpg)$`)
+
+	m := route(t, sim.Request{Host: "www.example.com", Path: "/api/v2/users"})
+	assert.Equal(t, len(m.Locations), 2)
+	assert.Equal(t, m.Captures["version"], "v2")
+	assert.Equal(t, m.Captures["1"], "v2")
+}
+
+func TestRoute_Directives(t *testing.T) {
+	t.Parallel()
+
+	m := route(t, sim.Request{Host: "www.example.com", Path: "/api/admin/users"})
+	assert.DeepEqual(t, names(m), []string{"root", "gzip", "add_header"})
+	gzip := m.Directives[1].(*config.Directive)
+	assert.Equal(t, gzip.Parameters[0].Value, "off")
+	// add_header of the location replaces those of the server and http levels
+	assert.Equal(t, m.Directives[2].GetParameters()[0].GetValue(), "X-Admin")
+
+	m = route(t, sim.Request{Host: "www.example.com", Path: "/api/users"})
+	assert.DeepEqual(t, names(m), []string{"gzip", "root", "add_header", "proxy_pass"})
+	assert.Equal(t, m.Directives[2].GetParameters()[0].GetValue(), "X-Server")
+}
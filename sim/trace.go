@@ -0,0 +1,641 @@
+package sim
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// MaxURIChanges is the number of internal redirects nginx allows before failing with
+// "rewrite or internal redirection cycle"
+const MaxURIChanges = 10
+
+// StepKind tells what happened in a step of a trace
+type StepKind string
+
+const (
+	// StepLocation is the selection of a location for the current URI
+	StepLocation StepKind = "location"
+	// StepRewrite is a rewrite directive whose regex matched
+	StepRewrite StepKind = "rewrite"
+	// StepSet is a set directive
+	StepSet StepKind = "set"
+	// StepIf is an if block, its condition was true when its directives ran
+	StepIf StepKind = "if"
+	// StepReturn is a return directive, or a rewrite redirecting the client
+	StepReturn StepKind = "return"
+	// StepTryFiles is a try_files directive, serving a file or redirecting to its last parameter
+	StepTryFiles StepKind = "try_files"
+	// StepErrorPage is an error_page directive redirecting an error status
+	StepErrorPage StepKind = "error_page"
+	// StepError is a request that failed, like a redirection cycle
+	StepError StepKind = "error"
+)
+
+// Step is a step of the way nginx processes a request
+type Step struct {
+	Kind      StepKind
+	Directive config.IDirective // the directive of the step, nil for an error
+	URI       string            // the URI after the step
+	Captures  map[string]string // the captures of the regex of the step
+	Note      string            // what the step did
+}
+
+// String returns the step on one line
+func (s Step) String() string {
+	text := string(s.Kind)
+	if s.Directive != nil {
+		text += " " + directiveText(s.Directive)
+	}
+	if s.Note != "" {
+		text += ": " + s.Note
+	}
+	return text
+}
+
+// directiveText returns a directive with its parameters, without its block
+func directiveText(d config.IDirective) string {
+	parts := []string{d.GetName()}
+	for _, p := range d.GetParameters() {
+		parts = append(parts, p.GetValue())
+	}
+	return strings.Join(parts, " ")
+}
+
+// TraceResult is the outcome of a request traced through the rewrite rules
+type TraceResult struct {
+	Steps []Step
+	URI   string // the URI when processing ended
+	Args  string // the query string when processing ended
+	// Status is the status of a return, a redirect or an error, 0 when the request is
+	// left to the content handler of the location, like proxy_pass or a static file
+	Status   int
+	Redirect string // the target of a redirect sent to the client
+	Body     string // the text of a return
+	File     string // the file served by try_files
+	Match    *Match // the server and location processing ended in
+}
+
+// TraceOption configures Trace
+type TraceOption func(*tracer)
+
+// WithFiles tells Trace which files exist, for try_files and the file tests of if.
+// exists is called with the root or alias of the location joined with the URI.
+// Without it no file exists.
+func WithFiles(exists func(path string) bool) TraceOption {
+	return func(t *tracer) {
+		t.exists = exists
+	}
+}
+
+// tracer runs the rewrite rules of a request
+type tracer struct {
+	req      Request
+	match    *Match
+	res      *TraceResult
+	uri      string
+	args     string
+	captures map[string]string
+	vars     map[string]string
+	exists   func(path string) bool
+	changes  int  // internal redirects left
+	errored  bool // an error_page redirect happened, further errors are not redirected
+	status   int  // the status an error page is served with
+}
+
+// action is what comes after a directive of the rewrite phase
+type action int
+
+const (
+	next     action = iota // go on with the next directive
+	stop                   // stop the rewrite phase and go on in the location
+	relocate               // search the location of the new URI
+	finish                 // the response is known
+)
+
+// Trace follows a request through the rewrite, return, try_files and error_page
+// directives of the server and locations it goes through, like nginx does. It stops
+// at a return, a redirect, a file found by try_files, when the location leaves the
+// request to its content handler, or after MaxURIChanges internal redirects.
+func Trace(conf *config.Config, req Request, opts ...TraceOption) (*TraceResult, error) {
+	m, err := Route(conf, req)
+	if err != nil {
+		return nil, err
+	}
+	t := &tracer{
+		req:      req,
+		match:    m,
+		res:      &TraceResult{Match: m},
+		uri:      requestPath(req.Path),
+		captures: map[string]string{},
+		vars:     map[string]string{},
+		exists:   func(string) bool { return false },
+		changes:  MaxURIChanges,
+	}
+	if i := strings.Index(req.Path, "?"); i >= 0 {
+		t.args = req.Path[i+1:]
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.run()
+	t.res.URI, t.res.Args = t.uri, t.args
+	return t.res, nil
+}
+
+// run processes the request from the server rewrite phase to the content phase
+func (t *tracer) run() {
+	if t.rewrite(directives(t.match.Server.Block), true) == finish {
+		t.finish()
+		return
+	}
+	t.match.locate(t.uri)
+	t.stepLocation()
+	for {
+		a, uri := next, t.uri
+		if t.match.Location != nil {
+			a = t.rewrite(directives(t.match.Location.GetBlock()), false)
+			// a URI changed by a rewrite without flag is searched again
+			if a == next && t.uri != uri {
+				a = relocate
+			}
+		}
+		if a == relocate {
+			if !t.internalRedirect() {
+				t.finish()
+				return
+			}
+			t.match.locate(t.uri)
+			t.stepLocation()
+			continue
+		}
+		if a != finish {
+			a = t.tryFiles()
+		}
+		if a == relocate {
+			continue
+		}
+		if a == finish && t.errorPage() {
+			continue
+		}
+		t.finish()
+		return
+	}
+}
+
+// finish completes the result once the response is known
+func (t *tracer) finish() {
+	if t.status != 0 && t.res.Status == 0 {
+		t.res.Status = t.status
+	}
+}
+
+// stepLocation records the location selected for the URI
+func (t *tracer) stepLocation() {
+	t.captures = map[string]string{}
+	for name, value := range t.match.Captures {
+		t.captures[name] = value
+	}
+	step := Step{Kind: StepLocation, URI: t.uri, Captures: t.match.Captures, Note: "no location matches " + t.uri}
+	if l := t.match.Location; l != nil {
+		step.Directive = l
+		step.Note = "selected for " + t.uri
+	}
+	t.res.Steps = append(t.res.Steps, step)
+}
+
+// internalRedirect counts an internal redirect, it returns false when there were too many
+func (t *tracer) internalRedirect() bool {
+	t.changes--
+	if t.changes >= 0 {
+		return true
+	}
+	t.res.Status = 500
+	t.res.Steps = append(t.res.Steps, Step{Kind: StepError, URI: t.uri, Note: "rewrite or internal redirection cycle"})
+	return false
+}
+
+// rewrite runs the rewrite phase directives of a server or a location
+func (t *tracer) rewrite(block []config.IDirective, server bool) action {
+	for _, d := range block {
+		var a action
+		switch d.GetName() {
+		case "rewrite":
+			a = t.rewriteDirective(d, server)
+		case "return":
+			a = t.returnDirective(d)
+		case "set":
+			if params := d.GetParameters(); len(params) == 2 {
+				name := strings.ToLower(strings.TrimPrefix(params[0].GetValue(), "$"))
+				t.vars[name] = t.expand(params[1].GetValue())
+				t.res.Steps = append(t.res.Steps, Step{Kind: StepSet, Directive: d, URI: t.uri, Note: "$" + name + " = " + t.vars[name]})
+			}
+		case "break":
+			a = stop
+		case "if":
+			if t.condition(d) {
+				t.res.Steps = append(t.res.Steps, Step{Kind: StepIf, Directive: d, URI: t.uri, Note: "condition is true"})
+				a = t.rewrite(directives(d.GetBlock()), server)
+			}
+		}
+		if a != next {
+			return a
+		}
+	}
+	return next
+}
+
+// rewriteDirective runs a rewrite directive, in the server rewrite phase last works like break
+func (t *tracer) rewriteDirective(d config.IDirective, server bool) action {
+	params := d.GetParameters()
+	if len(params) < 2 {
+		return next
+	}
+	re, err := compileRegex(params[0].GetValue(), false)
+	if err != nil {
+		t.match.Warnings = append(t.match.Warnings, err.Error())
+		return next
+	}
+	captures, ok := matchRegex(re, t.uri)
+	if !ok {
+		return next
+	}
+	t.captures = captures
+	flag := ""
+	if len(params) > 2 {
+		flag = params[2].GetValue()
+	}
+
+	target := t.expand(strings.Trim(params[1].GetValue(), `"'`))
+	if flag == "redirect" || flag == "permanent" || isURL(target) {
+		t.res.Status = 302
+		if flag == "permanent" {
+			t.res.Status = 301
+		}
+		t.res.Redirect = t.absolute(target)
+		t.res.Steps = append(t.res.Steps, Step{Kind: StepReturn, Directive: d, URI: t.uri, Captures: captures, Note: fmt.Sprintf("%d redirect to %s", t.res.Status, t.res.Redirect)})
+		return finish
+	}
+
+	from := t.uri
+	t.setURI(target, true)
+	note := from + " -> " + t.uri
+	if flag != "" {
+		note += " (" + flag + ")"
+	}
+	t.res.Steps = append(t.res.Steps, Step{Kind: StepRewrite, Directive: d, URI: t.uri, Captures: captures, Note: note})
+	switch flag {
+	case "last":
+		if server {
+			return stop
+		}
+		return relocate
+	case "break":
+		return stop
+	}
+	return next
+}
+
+// setURI sets the URI and the query string of a target. With appendArgs the query string
+// of a rewrite target comes before the one of the request, a target ending with '?' drops it.
+func (t *tracer) setURI(target string, appendArgs bool) {
+	if i := strings.Index(target, "?"); i >= 0 {
+		query := target[i+1:]
+		target = target[:i]
+		if appendArgs && query != "" && t.args != "" {
+			query += "&" + t.args
+		}
+		t.args = query
+	}
+	t.uri = target
+}
+
+// returnDirective runs a return directive
+func (t *tracer) returnDirective(d config.IDirective) action {
+	params := d.GetParameters()
+	if len(params) == 0 {
+		return next
+	}
+	code, err := strconv.Atoi(params[0].GetValue())
+	text := ""
+	if err != nil {
+		// return URL is a temporary redirect
+		code, text = 302, params[0].GetValue()
+	} else if len(params) > 1 {
+		text = params[1].GetValue()
+	}
+	text = t.expand(strings.Trim(text, `"'`))
+
+	t.res.Status = code
+	note := strconv.Itoa(code)
+	switch code {
+	case 301, 302, 303, 307, 308:
+		t.res.Redirect = t.absolute(text)
+		note += " redirect to " + t.res.Redirect
+	default:
+		t.res.Body = text
+	}
+	t.res.Steps = append(t.res.Steps, Step{Kind: StepReturn, Directive: d, URI: t.uri, Note: note})
+	return finish
+}
+
+// tryFiles runs the try_files directive in effect, if any
+func (t *tracer) tryFiles() action {
+	var d config.IDirective
+	for _, directive := range t.match.Directives {
+		if directive.GetName() == "try_files" {
+			d = directive
+		}
+	}
+	params := []config.Parameter{}
+	if d != nil {
+		params = d.GetParameters()
+	}
+	if len(params) < 2 {
+		return next
+	}
+
+	for _, param := range params[:len(params)-1] {
+		file := t.expand(param.GetValue())
+		if path := t.filePath(file); t.exists(path) {
+			t.res.File = path
+			t.res.Steps = append(t.res.Steps, Step{Kind: StepTryFiles, Directive: d, URI: t.uri, Note: "serves " + path})
+			return finish
+		}
+	}
+
+	fallback := t.expand(params[len(params)-1].GetValue())
+	if strings.HasPrefix(fallback, "=") {
+		code, _ := strconv.Atoi(fallback[1:])
+		t.res.Status = code
+		t.res.Steps = append(t.res.Steps, Step{Kind: StepTryFiles, Directive: d, URI: t.uri, Note: "no file found, status " + fallback[1:]})
+		return finish
+	}
+	t.res.Steps = append(t.res.Steps, Step{Kind: StepTryFiles, Directive: d, URI: t.uri, Note: "no file found, internal redirect to " + fallback})
+	if !t.redirectTo(fallback) {
+		return finish
+	}
+	return relocate
+}
+
+// redirectTo makes an internal redirect to a URI or a named location.
+// It returns false when there were too many internal redirects.
+func (t *tracer) redirectTo(target string) bool {
+	if !t.internalRedirect() {
+		return false
+	}
+	if strings.HasPrefix(target, "@") {
+		for _, d := range directives(t.match.Server.Block) {
+			if l, ok := d.(*config.Location); ok {
+				if modifier, name := locationKind(l); modifier == "@" && "@"+name == target || l.Match == target {
+					t.match.setLocations([]*config.Location{l})
+					t.stepLocation()
+					return true
+				}
+			}
+		}
+		t.res.Status = 500
+		t.res.Steps = append(t.res.Steps, Step{Kind: StepError, URI: t.uri, Note: "no location " + target})
+		return false
+	}
+	t.setURI(target, false)
+	t.match.locate(t.uri)
+	t.stepLocation()
+	return true
+}
+
+// errorPage redirects an error status to the error_page in effect for it, if any.
+// Like nginx without recursive_error_pages only the first error is redirected.
+func (t *tracer) errorPage() bool {
+	if t.errored || t.res.Status < 400 {
+		return false
+	}
+	status := strconv.Itoa(t.res.Status)
+	for _, d := range t.match.Directives {
+		if d.GetName() != "error_page" {
+			continue
+		}
+		params := d.GetParameters()
+		if len(params) < 2 {
+			continue
+		}
+		matched := false
+		code := t.res.Status
+		for _, param := range params[:len(params)-1] {
+			value := param.GetValue()
+			switch {
+			case value == status:
+				matched = true
+			case strings.HasPrefix(value, "="):
+				if n, err := strconv.Atoi(value[1:]); err == nil {
+					code = n
+				}
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		target := t.expand(params[len(params)-1].GetValue())
+		t.errored = true
+		if isURL(target) {
+			if code == t.res.Status {
+				code = 302
+			}
+			t.res.Status, t.res.Redirect, t.res.Body = code, target, ""
+			t.res.Steps = append(t.res.Steps, Step{Kind: StepErrorPage, Directive: d, URI: t.uri, Note: fmt.Sprintf("%d redirect to %s", code, target)})
+			return false
+		}
+		t.res.Steps = append(t.res.Steps, Step{Kind: StepErrorPage, Directive: d, URI: t.uri, Note: "status " + status + ", internal redirect to " + target})
+		t.status = code
+		t.res.Status, t.res.Redirect, t.res.Body = 0, "", ""
+		return t.redirectTo(target)
+	}
+	return false
+}
+
+// condition evaluates the condition of an if block. Conditions that cannot be evaluated are false.
+func (t *tracer) condition(d config.IDirective) bool {
+	var parts []string
+	for _, p := range d.GetParameters() {
+		parts = append(parts, p.GetValue())
+	}
+	cond := strings.TrimSpace(strings.Join(parts, " "))
+	cond = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(cond, "("), ")"))
+	fields := strings.SplitN(cond, " ", 3)
+	unquote := func(s string) string { return strings.Trim(strings.TrimSpace(s), `"'`) }
+
+	switch {
+	case len(fields) == 1:
+		value := t.expand(unquote(fields[0]))
+		return value != "" && value != "0"
+	case len(fields) == 2:
+		// file tests like -f $request_filename
+		op, operand := fields[0], t.expand(unquote(fields[1]))
+		negate := strings.HasPrefix(op, "!")
+		switch strings.TrimPrefix(op, "!") {
+		case "-f", "-d", "-e", "-x":
+			return t.exists(t.filePath(operand)) != negate
+		}
+		return false
+	}
+
+	left, op, right := t.expand(unquote(fields[0])), fields[1], unquote(fields[2])
+	switch op {
+	case "=":
+		return left == t.expand(right)
+	case "!=":
+		return left != t.expand(right)
+	case "~", "~*", "!~", "!~*":
+		re, err := compileRegex(right, strings.HasSuffix(op, "*"))
+		if err != nil {
+			t.match.Warnings = append(t.match.Warnings, err.Error())
+			return false
+		}
+		captures, ok := matchRegex(re, left)
+		if ok {
+			t.captures = captures
+		}
+		return ok != strings.HasPrefix(op, "!")
+	}
+	return false
+}
+
+// filePath returns the path of a URI on disk, from the root or alias in effect
+func (t *tracer) filePath(uri string) string {
+	if strings.HasPrefix(uri, "/") || uri == "" {
+		root := "html"
+		for _, d := range t.match.Directives {
+			params := d.GetParameters()
+			if len(params) == 0 {
+				// root and alias without a path are not valid for nginx, they are skipped
+				continue
+			}
+			switch d.GetName() {
+			case "root":
+				root = params[0].GetValue()
+			case "alias":
+				if l := t.match.Location; l != nil {
+					_, match := locationKind(l)
+					return params[0].GetValue() + strings.TrimPrefix(uri, match)
+				}
+			}
+		}
+		return path.Join(root, uri) + trailingSlash(uri)
+	}
+	return uri
+}
+
+// trailingSlash keeps the trailing slash of a directory URI, which path.Join drops
+func trailingSlash(uri string) string {
+	if strings.HasSuffix(uri, "/") && uri != "/" {
+		return "/"
+	}
+	return ""
+}
+
+// isURL returns true for the targets nginx sends to the client as a redirect
+func isURL(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// absolute makes a redirect target absolute like nginx does with absolute_redirect on
+func (t *tracer) absolute(target string) string {
+	if !strings.HasPrefix(target, "/") {
+		return target
+	}
+	scheme := t.req.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + t.host() + target
+}
+
+// host returns the host of the request as sent, with its port
+func (t *tracer) host() string {
+	if t.req.Host != "" {
+		return t.req.Host
+	}
+	for name, value := range t.req.Headers {
+		if strings.EqualFold(name, "Host") {
+			return value
+		}
+	}
+	return t.match.ServerName
+}
+
+var expandRe = regexp.MustCompile(`\$(\{[A-Za-z0-9_]+\}|[A-Za-z_][A-Za-z0-9_]*|[0-9])`)
+
+// expand replaces the variables of a value. Variables the trace knows nothing about are kept as written.
+func (t *tracer) expand(value string) string {
+	return expandRe.ReplaceAllStringFunc(value, func(ref string) string {
+		name := strings.ToLower(strings.Trim(ref[1:], "{}"))
+		if value, ok := t.variable(name); ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// variable returns the value of a variable during the trace
+func (t *tracer) variable(name string) (string, bool) {
+	if value, ok := t.captures[name]; ok {
+		return value, true
+	}
+	if len(name) == 1 && name[0] >= '0' && name[0] <= '9' {
+		// a capture the last regex did not have
+		return "", true
+	}
+	if value, ok := t.vars[name]; ok {
+		return value, true
+	}
+	switch name {
+	case "uri", "document_uri":
+		return t.uri, true
+	case "args", "query_string":
+		return t.args, true
+	case "is_args":
+		if t.args != "" {
+			return "?", true
+		}
+		return "", true
+	case "request_uri":
+		return t.req.Path, true
+	case "host":
+		return requestHost(t.req), true
+	case "scheme":
+		if t.req.Scheme == "" {
+			return "http", true
+		}
+		return strings.ToLower(t.req.Scheme), true
+	case "request_filename":
+		return t.filePath(t.uri), true
+	case "server_name":
+		return t.match.ServerName, true
+	}
+	if strings.HasPrefix(name, "http_") {
+		header := strings.ReplaceAll(strings.TrimPrefix(name, "http_"), "_", "-")
+		for key, value := range t.req.Headers {
+			if strings.EqualFold(key, header) {
+				return value, true
+			}
+		}
+		if header == "host" {
+			return t.host(), true
+		}
+		return "", true
+	}
+	if strings.HasPrefix(name, "arg_") {
+		for _, pair := range strings.Split(t.args, "&") {
+			if key, value, _ := strings.Cut(pair, "="); key == strings.TrimPrefix(name, "arg_") {
+				return value, true
+			}
+		}
+		return "", true
+	}
+	return "", false
+}
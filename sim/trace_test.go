@@ -0,0 +1,200 @@
+package sim_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/sim"
+	"gotest.tools/v3/assert"
+)
+
+const traceConfig = `http {
+    server {
+        listen 80;
+        server_name example.com;
+        root /srv/www;
+        rewrite ^/blog/(\d+)$ /posts/$1 last;
+        error_page 404 /404.html;
+        if ($http_x_legacy = "1") {
+            return 301 /legacy$request_uri;
+        }
+        location / {
+            try_files $uri $uri/ /index.php?q=$uri&$args;
+        }
+        location /old/ {
+            rewrite ^/old/(.*)$ /new/$1;
+            rewrite ^/new/(.*)\.htm$ /new/$1.html;
+        }
+        location /new/ {
+            add_header X-New 1;
+        }
+        location /posts/ {
+            rewrite ^/posts/(?<id>\d+)$ /article.php?id=$id break;
+            proxy_pass http://app;
+        }
+        location /moved {
+            rewrite ^ https://other.example.com$uri permanent;
+        }
+        location /gone {
+            return 410;
+        }
+        location /missing {
+            return 404;
+        }
+        location = /404.html {
+            internal;
+        }
+        location /loop {
+            rewrite ^ /loop last;
+        }
+        location /named {
+            try_files $uri @backend;
+        }
+        location @backend {
+            return 200 "backend $uri";
+        }
+        location ~ \.php$ {
+            fastcgi_pass unix:/run/php.sock;
+        }
+    }
+}`
+
+func trace(t *testing.T, path string, opts ...sim.TraceOption) *sim.TraceResult {
+	t.Helper()
+	conf, err := parser.NewStringParser(traceConfig).Parse()
+	assert.NilError(t, err)
+	res, err := sim.Trace(conf, sim.Request{Host: "example.com", Path: path}, opts...)
+	assert.NilError(t, err)
+	return res
+}
+
+func steps(res *sim.TraceResult) []string {
+	var steps []string
+	for _, step := range res.Steps {
+		steps = append(steps, step.String())
+	}
+	return steps
+}
+
+func TestTrace_Rewrite(t *testing.T) {
+	t.Parallel()
+
+	res := trace(t, "/blog/42")
+	assert.DeepEqual(t, steps(res), []string{
+		`rewrite rewrite ^/blog/(\d+)$ /posts/$1 last: /blog/42 -> /posts/42 (last)`,
+		"location location /posts/: selected for /posts/42",
+		`rewrite rewrite ^/posts/(?<id>\d+)$ /article.php?id=$id break: /posts/42 -> /article.php (break)`,
+	})
+	assert.Equal(t, res.URI, "/article.php")
+	assert.Equal(t, res.Args, "id=42")
+	assert.Equal(t, res.Status, 0)
+	assert.Equal(t, res.Match.Location.Match, "/posts/")
+
+	// rewrites without flag go on, and the new URI is searched again
+	res = trace(t, "/old/page.htm")
+	assert.Equal(t, res.URI, "/new/page.html")
+	assert.Equal(t, res.Match.Location.Match, "/new/")
+	assert.Equal(t, len(res.Steps), 4)
+
+	res = trace(t, "/moved/a")
+	assert.Equal(t, res.Status, 301)
+	assert.Equal(t, res.Redirect, "https://other.example.com/moved/a")
+}
+
+func TestTrace_ReturnAndErrorPage(t *testing.T) {
+	t.Parallel()
+
+	res := trace(t, "/gone")
+	assert.Equal(t, res.Status, 410)
+
+	res = trace(t, "/missing")
+	assert.Equal(t, res.Status, 404)
+	assert.Equal(t, res.URI, "/404.html")
+	assert.Equal(t, res.Match.Location.Match, "/404.html")
+	assert.Assert(t, strings.HasPrefix(res.Steps[2].String(), "error_page error_page 404 /404.html: status 404"))
+
+	res = trace(t, "/named/x")
+	assert.Equal(t, res.Status, 200)
+	assert.Equal(t, res.Body, "backend /named/x")
+
+	res = trace(t, "/loop")
+	assert.Equal(t, res.Status, 500)
+	last := res.Steps[len(res.Steps)-1]
+	assert.Equal(t, last.Kind, sim.StepError)
+	assert.Equal(t, last.Note, "rewrite or internal redirection cycle")
+
+	conf, err := parser.NewStringParser(traceConfig).Parse()
+	assert.NilError(t, err)
+	res, err = sim.Trace(conf, sim.Request{Host: "example.com", Path: "/x?a=1", Headers: map[string]string{"X-Legacy": "1"}})
+	assert.NilError(t, err)
+	assert.Equal(t, res.Status, 301)
+	assert.Equal(t, res.Redirect, "http://example.com/legacy/x?a=1")
+}
+
+func TestTrace_TryFiles(t *testing.T) {
+	t.Parallel()
+
+	res := trace(t, "/about", sim.WithFiles(func(path string) bool { return path == "/srv/www/about/" }))
+	assert.Equal(t, res.File, "/srv/www/about/")
+	assert.Equal(t, res.Status, 0)
+
+	// no file: internal redirect to the php front controller
+	res = trace(t, "/about?lang=en")
+	assert.Equal(t, res.URI, "/index.php")
+	assert.Equal(t, res.Args, "q=/about&lang=en")
+	assert.Equal(t, res.Match.Location.Match, `\.php$`)
+}
+
+func TestTrace_URIChangesLimit(t *testing.T) {
+	t.Parallel()
+	// a chain of locations, each rewriting to the next one, the last one answers
+	chain := func(redirects int) *sim.TraceResult {
+		var locations strings.Builder
+		for i := 0; i < redirects; i++ {
+			fmt.Fprintf(&locations, "        location = /r%d {\n            rewrite ^ /r%d last;\n        }\n", i, i+1)
+		}
+		fmt.Fprintf(&locations, "        location = /r%d {\n            return 200 done;\n        }\n", redirects)
+		conf, err := parser.NewStringParser("http {\n    server {\n        listen 80;\n" + locations.String() + "    }\n}\n").Parse()
+		assert.NilError(t, err)
+		res, err := sim.Trace(conf, sim.Request{Host: "example.com", Path: "/r0"})
+		assert.NilError(t, err)
+		return res
+	}
+
+	res := chain(sim.MaxURIChanges)
+	assert.Equal(t, res.Status, 200)
+	assert.Equal(t, res.URI, fmt.Sprintf("/r%d", sim.MaxURIChanges))
+
+	res = chain(sim.MaxURIChanges + 1)
+	assert.Equal(t, res.Status, 500)
+	assert.Equal(t, res.Steps[len(res.Steps)-1].Note, "rewrite or internal redirection cycle")
+}
+
+func TestTrace_RootWithoutPath(t *testing.T) {
+	t.Parallel()
+	// the parser accepts root and alias without a path, tracing skips them
+	conf, err := parser.NewStringParser(`http {
+    server {
+        listen 80;
+        root;
+        location / {
+            try_files $uri =404;
+        }
+        location /static/ {
+            alias;
+            try_files $uri =404;
+        }
+    }
+}`).Parse()
+	assert.NilError(t, err)
+
+	exists := sim.WithFiles(func(string) bool { return true })
+	res, err := sim.Trace(conf, sim.Request{Host: "example.com", Path: "/index.html"}, exists)
+	assert.NilError(t, err)
+	assert.Equal(t, res.File, "html/index.html")
+	res, err = sim.Trace(conf, sim.Request{Host: "example.com", Path: "/static/a.css"}, exists)
+	assert.NilError(t, err)
+	assert.Equal(t, res.File, "html/static/a.css")
+}
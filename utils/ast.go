@@ -0,0 +1,356 @@
+package utils
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+	"gopkg.in/yaml.v2"
+)
+
+// ASTVersion is the version of the AST schema written by ConfigToAST, ASTToConfig refuses others
+const ASTVersion = 1
+
+// ASTSchema is the JSON Schema of ASTPayload at ASTVersion, the ast.schema.json file of this package
+//
+//go:embed ast.schema.json
+var ASTSchema string
+
+// ASTPayload is the lossless JSON and YAML form of a config, modeled after crossplane. Config
+// lists the main file first and then every included file, include directives refer to them by
+// index. Converting a config to the payload and back gives a config that dumps the same and
+// converts to the same payload again.
+type ASTPayload struct {
+	Version int        `json:"version" yaml:"version"`
+	Config  []*ASTFile `json:"config" yaml:"config"`
+}
+
+// ASTFile is one file of an ASTPayload
+type ASTFile struct {
+	File   string          `json:"file" yaml:"file"` // empty for a config parsed from a string
+	Parsed []*ASTDirective `json:"parsed" yaml:"parsed"`
+}
+
+// ASTDirective is a directive of an ASTPayload. Lines are 1-based, zero when unknown.
+type ASTDirective struct {
+	Directive      string           `json:"directive" yaml:"directive"`
+	Args           []string         `json:"args" yaml:"args"`
+	Line           int              `json:"line,omitempty" yaml:"line,omitempty"`
+	EndLine        int              `json:"end_line,omitempty" yaml:"end_line,omitempty"`   // the line of the ';' or '}', when not Line
+	ArgLines       []int            `json:"arg_lines,omitempty" yaml:"arg_lines,omitempty"` // the line of every arg, when one is not on Line
+	File           string           `json:"file,omitempty" yaml:"file,omitempty"`
+	Comments       []string         `json:"comments,omitempty" yaml:"comments,omitempty"` // the comment lines above the directive
+	InlineComments []ASTComment     `json:"inline_comments,omitempty" yaml:"inline_comments,omitempty"`
+	Block          *[]*ASTDirective `json:"block,omitempty" yaml:"block,omitempty"`       // nil when the directive has no block
+	Code           string           `json:"code,omitempty" yaml:"code,omitempty"`         // the code of *_by_lua_block directives
+	Includes       []int            `json:"includes,omitempty" yaml:"includes,omitempty"` // the indexes of the included files
+}
+
+// ASTComment is a comment on the line of a directive or of one of its args
+type ASTComment struct {
+	Comment string `json:"comment" yaml:"comment"`
+	Line    int    `json:"line,omitempty" yaml:"line,omitempty"`
+}
+
+// ConfigToAST converts a config and the configs of its include directives to an ASTPayload
+func ConfigToAST(conf *config.Config) *ASTPayload {
+	e := &astEncoder{
+		payload: &ASTPayload{Version: ASTVersion},
+		indexes: make(map[*config.Config]int),
+	}
+	e.file(conf)
+	return e.payload
+}
+
+type astEncoder struct {
+	payload *ASTPayload
+	indexes map[*config.Config]int
+}
+
+// file adds a config to the payload unless it is there already and returns its index
+func (e *astEncoder) file(conf *config.Config) int {
+	if index, ok := e.indexes[conf]; ok {
+		return index
+	}
+	index := len(e.payload.Config)
+	e.indexes[conf] = index
+	file := &ASTFile{File: conf.FilePath, Parsed: []*ASTDirective{}}
+	e.payload.Config = append(e.payload.Config, file)
+	if conf.Block != nil {
+		file.Parsed = e.directives(conf.Block.GetDirectives(), conf.FilePath)
+	}
+	return index
+}
+
+func (e *astEncoder) directives(directives []config.IDirective, file string) []*ASTDirective {
+	result := make([]*ASTDirective, 0, len(directives))
+	for _, dir := range directives {
+		result = append(result, e.directive(dir, file))
+	}
+	return result
+}
+
+func (e *astEncoder) directive(dir config.IDirective, file string) *ASTDirective {
+	ad := &ASTDirective{
+		Directive: dir.GetName(),
+		Args:      []string{},
+		Line:      dir.GetLine(),
+		File:      file,
+		Comments:  dir.GetComment(),
+	}
+	if r := config.RangeOf(dir); r != nil && r.IsValid() {
+		ad.Line, ad.File = r.Start.Line, r.File()
+		if end := dir.GetLine(); end != ad.Line {
+			ad.EndLine = end
+		}
+	}
+
+	params := dir.GetParameters()
+	argLines := make([]int, len(params))
+	spread := false
+	for i, param := range params {
+		ad.Args = append(ad.Args, param.Value)
+		argLines[i] = ad.Line + param.RelativeLineIndex
+		spread = spread || param.RelativeLineIndex != 0
+	}
+	if spread {
+		ad.ArgLines = argLines
+	}
+	for _, comment := range dir.GetInlineComment() {
+		ad.InlineComments = append(ad.InlineComments, ASTComment{
+			Comment: comment.Value,
+			Line:    ad.Line + comment.RelativeLineIndex,
+		})
+	}
+
+	if include, ok := dir.(*config.Include); ok {
+		for _, conf := range include.Configs {
+			ad.Includes = append(ad.Includes, e.file(conf))
+		}
+	} else if block := dir.GetBlock(); block != nil {
+		ad.Code = block.GetCodeBlock()
+		children := e.directives(block.GetDirectives(), file)
+		ad.Block = &children
+	}
+	return ad
+}
+
+// ASTToConfig converts an ASTPayload back to a config, the first file is the main one. Directives
+// get the typed wrappers the parser would give them, like *config.Server or *config.Map.
+func ASTToConfig(payload *ASTPayload) (*config.Config, error) {
+	if payload.Version != ASTVersion {
+		return nil, fmt.Errorf("unsupported AST version %d, want %d", payload.Version, ASTVersion)
+	}
+	if len(payload.Config) == 0 {
+		return nil, fmt.Errorf("AST has no config")
+	}
+	d := &astDecoder{payload: payload, configs: make(map[int]*config.Config)}
+	return d.file(0)
+}
+
+type astDecoder struct {
+	payload *ASTPayload
+	configs map[int]*config.Config
+}
+
+// file returns the config of the file at index, an included file is decoded once
+func (d *astDecoder) file(index int) (*config.Config, error) {
+	if conf, ok := d.configs[index]; ok {
+		return conf, nil
+	}
+	if index < 0 || index >= len(d.payload.Config) {
+		return nil, fmt.Errorf("AST has no config %d", index)
+	}
+	file := d.payload.Config[index]
+	conf := &config.Config{Block: &config.Block{Directives: []config.IDirective{}}, FilePath: file.File}
+	d.configs[index] = conf
+	directives, err := d.directives(file.Parsed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.File, err)
+	}
+	conf.Block.Directives = directives
+	return conf, nil
+}
+
+// directives decodes the directives of a block, contexts are the enclosing blocks that change
+// the meaning of upstream and server, like the context stack of the parser
+func (d *astDecoder) directives(ads []*ASTDirective, contexts []string) ([]config.IDirective, error) {
+	directives := make([]config.IDirective, 0, len(ads))
+	for _, ad := range ads {
+		dir, err := d.directive(ad, contexts)
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, dir)
+	}
+	return directives, nil
+}
+
+func (d *astDecoder) directive(ad *ASTDirective, contexts []string) (config.IDirective, error) {
+	if ad.Directive == "" {
+		return nil, fmt.Errorf("line %d: directive without a name", ad.Line)
+	}
+	if ad.ArgLines != nil && len(ad.ArgLines) != len(ad.Args) {
+		return nil, fmt.Errorf("line %d: %s has %d args but %d arg lines", ad.Line, ad.Directive, len(ad.Args), len(ad.ArgLines))
+	}
+	raw := &config.Directive{Name: ad.Directive, Comment: ad.Comments}
+	for i, arg := range ad.Args {
+		param := config.Parameter{Value: arg, Type: config.DetectParameterType(arg)}
+		if ad.ArgLines != nil {
+			param.RelativeLineIndex = ad.ArgLines[i] - ad.Line
+		}
+		raw.Parameters = append(raw.Parameters, param)
+	}
+	for _, comment := range ad.InlineComments {
+		raw.SetInlineComment(config.InlineComment{Value: comment.Comment, RelativeLineIndex: comment.Line - ad.Line})
+	}
+
+	dir, err := d.wrap(raw, ad, contexts)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %s: %w", ad.Line, ad.Directive, err)
+	}
+
+	// parents and lines are set like the parser does
+	if block := dir.GetBlock(); block == nil {
+		dir.SetParent(dir)
+	} else {
+		for _, child := range block.GetDirectives() {
+			child.SetParent(dir)
+		}
+		if b, ok := block.(*config.Block); ok {
+			b.SetParent(dir)
+		}
+	}
+	end := ad.Line
+	if ad.EndLine != 0 {
+		end = ad.EndLine
+	}
+	dir.SetLine(end)
+	if holder, ok := dir.(config.RangeHolder); ok && ad.Line > 0 {
+		start := config.Position{File: ad.File, Line: ad.Line}
+		holder.SetRange(&config.DirectiveRange{
+			Range: config.Range{Start: start, End: config.Position{File: ad.File, Line: end}},
+			Name:  config.Range{Start: start, End: start},
+		})
+	}
+	return dir, nil
+}
+
+// wrap gives the directive its block or included files and its typed wrapper
+func (d *astDecoder) wrap(raw *config.Directive, ad *ASTDirective, contexts []string) (config.IDirective, error) {
+	if wrapper, ok := config.IncludeWrappers[raw.Name]; ok && ad.Block == nil {
+		dir, err := wrapper(raw)
+		if err != nil {
+			return nil, err
+		}
+		include := dir.(*config.Include)
+		for _, index := range ad.Includes {
+			conf, err := d.file(index)
+			if err != nil {
+				return nil, err
+			}
+			conf.SetParent(include)
+			for _, child := range conf.GetDirectives() {
+				child.SetParent(include)
+			}
+			include.Configs = append(include.Configs, conf)
+		}
+		return include, nil
+	}
+
+	if ad.Block == nil {
+		if wrapper, ok := config.DirectiveWrappers[astWrapperKey(raw.Name, contexts)]; ok {
+			return wrapper(raw)
+		}
+		return raw, nil
+	}
+
+	if strings.HasSuffix(raw.Name, "_by_lua_block") {
+		raw.Block = &config.Block{IsLuaBlock: true, Directives: []config.IDirective{}, LiteralCode: ad.Code}
+		return config.BlockWrappers["_by_lua_block"](raw)
+	}
+
+	inner := contexts
+	switch raw.Name {
+	case "stream", "http", "events", "mail", "upstream":
+		inner = append(contexts[:len(contexts):len(contexts)], raw.Name)
+	}
+	children, err := d.directives(*ad.Block, inner)
+	if err != nil {
+		return nil, err
+	}
+	raw.Block = &config.Block{Directives: children, IsLuaBlock: ad.Code != "", LiteralCode: ad.Code}
+	if wrapper, ok := config.BlockWrappers[astWrapperKey(raw.Name, contexts)]; ok {
+		return wrapper(raw)
+	}
+	return raw, nil
+}
+
+// astWrapperKey returns the wrapper of a directive in the enclosing contexts, upstream and server
+// mean something else in the stream context
+func astWrapperKey(name string, contexts []string) string {
+	inStream, inUpstream := false, false
+	for _, context := range contexts {
+		switch context {
+		case "stream":
+			inStream = true
+		case "upstream":
+			inUpstream = true
+		}
+	}
+	switch {
+	case name == "upstream" && inStream:
+		return "stream_upstream"
+	case name == "server" && inStream && inUpstream:
+		return "stream_upstream_server"
+	case name == "server" && inStream:
+		return "stream_server"
+	}
+	return name
+}
+
+// ConvertToASTJSON converts the configuration to the JSON form of ConfigToAST
+func (cc *ConfigConverter) ConvertToASTJSON(pretty bool) (string, error) {
+	payload := ConfigToAST(cc.config)
+
+	var data []byte
+	var err error
+	if pretty {
+		data, err = json.MarshalIndent(payload, "", "  ")
+	} else {
+		data, err = json.Marshal(payload)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal to JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// ConvertToASTYAML converts the configuration to the YAML form of ConfigToAST
+func (cc *ConfigConverter) ConvertToASTYAML() (string, error) {
+	data, err := yaml.Marshal(ConfigToAST(cc.config))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal to YAML: %w", err)
+	}
+	return string(data), nil
+}
+
+// ConvertFromASTJSON converts the JSON form of an ASTPayload to a config
+func ConvertFromASTJSON(jsonData string) (*config.Config, error) {
+	var payload ASTPayload
+	if err := json.Unmarshal([]byte(jsonData), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return ASTToConfig(&payload)
+}
+
+// ConvertFromASTYAML converts the YAML form of an ASTPayload to a config
+func ConvertFromASTYAML(yamlData string) (*config.Config, error) {
+	var payload ASTPayload
+	if err := yaml.Unmarshal([]byte(yamlData), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+	return ASTToConfig(&payload)
+}
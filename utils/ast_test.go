@@ -0,0 +1,223 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/utils"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gotest.tools/v3/assert"
+)
+
+func TestAST_RoundTrip(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"nginx.conf": {Data: []byte(`# main config
+worker_processes 4; # per cpu
+http {
+    map $http_host $backend {
+        default web;
+        api.example.com api; # the api
+    }
+    geo $internal {
+        default 0;
+        10.0.0.0/8 1;
+    }
+    log_format main '$remote_addr - $request'
+                    '$status';
+    server {
+        listen 80;
+        server_name a.example.com;
+        location / {
+            content_by_lua_block {
+                ngx.say("hello")
+            }
+        }
+    }
+    server {
+        listen 80;
+        server_name b.example.com;
+        # static files
+        location ~ \.png$ {
+            root /srv;
+        }
+    }
+    include conf.d/*.conf;
+}
+stream {
+    upstream dns {
+        server 10.0.0.53:53;
+    }
+    server {
+        listen 53 udp;
+        proxy_pass dns;
+    }
+}
+`)},
+		"conf.d/c.conf": {Data: []byte("server {\n    listen 8080;\n    server_name c.example.com;\n}\n")},
+	}
+	p, err := parser.NewParser("nginx.conf", parser.WithFS(fsys), parser.WithIncludeParsing())
+	assert.NilError(t, err)
+	conf, err := p.Parse()
+	assert.NilError(t, err)
+
+	payload := utils.ConfigToAST(conf)
+	assert.Equal(t, len(payload.Config), 2)
+	parsed := payload.Config[0].Parsed
+	assert.DeepEqual(t, parsed[0], &utils.ASTDirective{
+		Directive:      "worker_processes",
+		Args:           []string{"4"},
+		Line:           2,
+		File:           "nginx.conf",
+		Comments:       []string{"# main config"},
+		InlineComments: []utils.ASTComment{{Comment: "# per cpu", Line: 2}},
+	})
+	http := *parsed[1].Block
+	assert.Equal(t, http[2].Directive, "log_format")
+	assert.DeepEqual(t, http[2].ArgLines, []int{12, 12, 13})
+	assert.DeepEqual(t, http[3].Includes, []int{1})
+	lua := (*(*http[4].Block)[2].Block)[0]
+	assert.Equal(t, lua.Code, `ngx.say("hello")`)
+	assert.Equal(t, payload.Config[1].File, "conf.d/c.conf")
+
+	data, err := utils.NewConfigConverter(conf).ConvertToASTJSON(true)
+	assert.NilError(t, err)
+	decoded, err := utils.ConvertFromASTJSON(data)
+	assert.NilError(t, err)
+
+	// the decoded config has the wrappers of the parser, dumps the same and converts back the same
+	_, ok := decoded.FindDirectives("map")[0].(*config.Map)
+	assert.Assert(t, ok)
+	assert.Equal(t, len(decoded.FindDirectives("http")[0].(*config.HTTP).Servers), 2)
+	_, ok = decoded.FindDirectives("stream")[0].GetBlock().GetDirectives()[1].(*config.StreamServer)
+	assert.Assert(t, ok)
+	assert.Equal(t, dumper.DumpConfig(decoded, dumper.IndentedStyle), dumper.DumpConfig(conf, dumper.IndentedStyle))
+	include := decoded.FindDirectives("include")[0].(*config.Include)
+	assert.Equal(t, dumper.DumpConfig(include.Configs[0], dumper.IndentedStyle),
+		"server {\n    listen 8080;\n    server_name c.example.com;\n}")
+	assert.Equal(t, config.RangeOf(include.Configs[0].FindDirectives("server_name")[0]).String(), "conf.d/c.conf:3:0-3:0")
+	again, err := utils.NewConfigConverter(decoded).ConvertToASTJSON(true)
+	assert.NilError(t, err)
+	assert.Equal(t, again, data)
+
+	yamlData, err := utils.NewConfigConverter(conf).ConvertToASTYAML()
+	assert.NilError(t, err)
+	fromYAML, err := utils.ConvertFromASTYAML(yamlData)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, utils.ConfigToAST(fromYAML), payload)
+}
+
+func TestAST_Errors(t *testing.T) {
+	t.Parallel()
+	_, err := utils.ConvertFromASTJSON(`{"version": 2, "config": []}`)
+	assert.Error(t, err, "unsupported AST version 2, want 1")
+
+	payload := &utils.ASTPayload{Version: utils.ASTVersion, Config: []*utils.ASTFile{{
+		File:   "nginx.conf",
+		Parsed: []*utils.ASTDirective{{Directive: "include", Args: []string{"a.conf"}, Line: 1, Includes: []int{3}}},
+	}}}
+	data, err := json.Marshal(payload)
+	assert.NilError(t, err)
+	_, err = utils.ConvertFromASTJSON(string(data))
+	assert.Error(t, err, "nginx.conf: line 1: include: AST has no config 3")
+}
+
+// compileASTSchema compiles utils.ASTSchema, failing the test when it is not a valid schema
+func compileASTSchema(t *testing.T) *jsonschema.Schema {
+	t.Helper()
+	compiler := jsonschema.NewCompiler()
+	assert.NilError(t, compiler.AddResource("ast.schema.json", strings.NewReader(utils.ASTSchema)))
+	schema, err := compiler.Compile("ast.schema.json")
+	assert.NilError(t, err)
+	return schema
+}
+
+// validateAST validates a JSON AST against utils.ASTSchema
+func validateAST(t *testing.T, schema *jsonschema.Schema, data string) error {
+	t.Helper()
+	var doc interface{}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	assert.NilError(t, decoder.Decode(&doc))
+	return schema.Validate(doc)
+}
+
+func TestAST_Schema(t *testing.T) {
+	t.Parallel()
+	schema := compileASTSchema(t)
+
+	// the schema pins the version the encoder writes
+	var pinned struct {
+		Properties struct {
+			Version struct {
+				Const int `json:"const"`
+			} `json:"version"`
+		} `json:"properties"`
+	}
+	assert.NilError(t, json.Unmarshal([]byte(utils.ASTSchema), &pinned))
+	assert.Equal(t, pinned.Properties.Version.Const, utils.ASTVersion)
+
+	fsys := fstest.MapFS{
+		"nginx.conf": {Data: []byte(`# main config
+user nginx; # the user
+http {
+    log_format main '$remote_addr'
+                    '$status'; # spread
+    server {
+        listen 80;
+        location / {
+            content_by_lua_block {
+                ngx.say("hello")
+            }
+        }
+    }
+    include conf.d/*.conf;
+}
+`)},
+		"conf.d/a.conf": {Data: []byte("server {\n    listen 8080;\n}\n")},
+		"conf.d/b.conf": {Data: []byte("")},
+	}
+	p, err := parser.NewParser("nginx.conf", parser.WithFS(fsys), parser.WithIncludeParsing())
+	assert.NilError(t, err)
+	conf, err := p.Parse()
+	assert.NilError(t, err)
+	data, err := utils.NewConfigConverter(conf).ConvertToASTJSON(true)
+	assert.NilError(t, err)
+	assert.NilError(t, validateAST(t, schema, data))
+
+	// a config parsed from a string has no file names and no included files
+	conf, err = parser.NewStringParser("events {}\nworker_processes auto;").Parse()
+	assert.NilError(t, err)
+	data, err = utils.NewConfigConverter(conf).ConvertToASTJSON(false)
+	assert.NilError(t, err)
+	assert.NilError(t, validateAST(t, schema, data))
+}
+
+func TestAST_SchemaRejects(t *testing.T) {
+	t.Parallel()
+	schema := compileASTSchema(t)
+	for _, tc := range []struct {
+		name string
+		data string
+	}{
+		{"other version", `{"version": 2, "config": []}`},
+		{"no config", `{"version": 1}`},
+		{"file without parsed", `{"version": 1, "config": [{"file": "nginx.conf"}]}`},
+		{"directive without args", `{"version": 1, "config": [{"file": "", "parsed": [{"directive": "user"}]}]}`},
+		{"empty directive", `{"version": 1, "config": [{"file": "", "parsed": [{"directive": "", "args": []}]}]}`},
+		{"unknown field", `{"version": 1, "config": [{"file": "", "parsed": [{"directive": "user", "args": [], "name": "x"}]}]}`},
+		{"zero line", `{"version": 1, "config": [{"file": "", "parsed": [{"directive": "user", "args": [], "line": 0}]}]}`},
+		{"nested args", `{"version": 1, "config": [{"file": "", "parsed": [{"directive": "http", "args": [], "block": [{"directive": "gzip", "args": [true]}]}]}]}`},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Assert(t, validateAST(t, schema, tc.data) != nil)
+		})
+	}
+}
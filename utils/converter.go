@@ -3,8 +3,11 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
 	"gopkg.in/yaml.v2"
 )
 
@@ -20,6 +23,10 @@ const (
 	FormatYAML
 	// FormatTOML represents TOML format
 	FormatTOML
+	// FormatASTJSON represents the lossless JSON form of ConfigToAST
+	FormatASTJSON
+	// FormatASTYAML represents the lossless YAML form of ConfigToAST
+	FormatASTYAML
 )
 
 // String returns the string representation of the format
@@ -33,11 +40,35 @@ func (cf ConfigFormat) String() string {
 		return "yaml"
 	case FormatTOML:
 		return "toml"
+	case FormatASTJSON:
+		return "ast-json"
+	case FormatASTYAML:
+		return "ast-yaml"
 	default:
 		return "unknown"
 	}
 }
 
+// ParseConfigFormat returns the format with the given name, as returned by ConfigFormat.String
+func ParseConfigFormat(name string) (ConfigFormat, error) {
+	switch strings.ToLower(name) {
+	case "nginx", "conf":
+		return FormatNginx, nil
+	case "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "toml":
+		return FormatTOML, nil
+	case "ast-json":
+		return FormatASTJSON, nil
+	case "ast-yaml", "ast-yml":
+		return FormatASTYAML, nil
+	default:
+		return FormatNginx, fmt.Errorf("unknown format: %s", name)
+	}
+}
+
 // ConfigConverter handles conversion between different configuration formats
 type ConfigConverter struct {
 	config *config.Config
@@ -50,7 +81,8 @@ func NewConfigConverter(conf *config.Config) *ConfigConverter {
 	}
 }
 
-// ConvertToJSON converts the configuration to JSON format
+// ConvertToJSON converts the configuration to JSON format. The map it writes merges repeated
+// directives and leaves comments and lines out, ConvertToASTJSON keeps everything.
 func (cc *ConfigConverter) ConvertToJSON(pretty bool) (string, error) {
 	configMap := cc.configToMap()
 
@@ -591,8 +623,12 @@ func (fc *FormatConverter) Convert(input string, fromFormat, toFormat ConfigForm
 		conf, err = ConvertFromJSON(input)
 	case FormatYAML:
 		conf, err = ConvertFromYAML(input)
+	case FormatASTJSON:
+		conf, err = ConvertFromASTJSON(input)
+	case FormatASTYAML:
+		conf, err = ConvertFromASTYAML(input)
 	case FormatNginx:
-		return "", fmt.Errorf("nginx to other format conversion not implemented")
+		conf, err = parser.NewStringParser(input, parser.WithSkipValidDirectivesErr()).Parse()
 	default:
 		return "", fmt.Errorf("unsupported source format: %s", fromFormat)
 	}
@@ -608,8 +644,12 @@ func (fc *FormatConverter) Convert(input string, fromFormat, toFormat ConfigForm
 		return converter.ConvertToJSON(true)
 	case FormatYAML:
 		return converter.ConvertToYAML()
+	case FormatASTJSON:
+		return converter.ConvertToASTJSON(true)
+	case FormatASTYAML:
+		return converter.ConvertToASTYAML()
 	case FormatNginx:
-		return "", fmt.Errorf("conversion to nginx format not implemented")
+		return dumper.DumpConfig(conf, dumper.IndentedStyle), nil
 	default:
 		return "", fmt.Errorf("unsupported target format: %s", toFormat)
 	}
@@ -18,8 +18,10 @@ const (
 	DiffRemoved
 	// DiffModified represents a modified directive
 	DiffModified
-	// DiffMoved represents a moved directive
+	// DiffMoved represents a directive moved to another block
 	DiffMoved
+	// DiffReordered represents a directive whose position changed in its block
+	DiffReordered
 )
 
 // String returns the string representation of the diff type
@@ -33,6 +35,8 @@ func (dt DiffType) String() string {
 		return "MODIFIED"
 	case DiffMoved:
 		return "MOVED"
+	case DiffReordered:
+		return "REORDERED"
 	default:
 		return "UNKNOWN"
 	}
@@ -46,8 +50,16 @@ type Difference struct {
 	OldValue      string
 	NewValue      string
 	Context       string
-	Line          int
+	Line          int // the line in the new configuration, in the old one for a removed directive
+	OldLine       int // the line in the old configuration, zero for an added directive
+	NewLine       int // the line in the new configuration, zero for a removed directive
 	Description   string
+	OldDirective  config.IDirective // the directive in the old configuration, nil for an added directive
+	NewDirective  config.IDirective // the directive in the new configuration, nil for a removed directive
+
+	// where the directive sits among its siblings, see DiffResult.Patch
+	oldAfter, newAfter string
+	newPath            string // the block a moved directive went to
 }
 
 // String returns a human-readable representation of the difference
@@ -61,6 +73,8 @@ func (d *Difference) String() string {
 		return fmt.Sprintf("~ [%s] %s: %s -> %s", d.Path, d.DirectiveName, d.OldValue, d.NewValue)
 	case DiffMoved:
 		return fmt.Sprintf("^ [%s] %s: moved to %s", d.Path, d.DirectiveName, d.NewValue)
+	case DiffReordered:
+		return fmt.Sprintf("> [%s] %s: reordered (line %d -> %d)", d.Path, strings.TrimSpace(d.DirectiveName+" "+d.NewValue), d.OldLine, d.NewLine)
 	default:
 		return fmt.Sprintf("? [%s] %s", d.Path, d.DirectiveName)
 	}
@@ -74,17 +88,18 @@ type DiffResult struct {
 
 // DiffSummary provides summary statistics about the differences
 type DiffSummary struct {
-	Total    int
-	Added    int
-	Removed  int
-	Modified int
-	Moved    int
+	Total     int
+	Added     int
+	Removed   int
+	Modified  int
+	Moved     int
+	Reordered int
 }
 
 // String returns a summary of the differences
 func (ds *DiffSummary) String() string {
-	return fmt.Sprintf("Total: %d, Added: %d, Removed: %d, Modified: %d, Moved: %d",
-		ds.Total, ds.Added, ds.Removed, ds.Modified, ds.Moved)
+	return fmt.Sprintf("Total: %d, Added: %d, Removed: %d, Modified: %d, Moved: %d, Reordered: %d",
+		ds.Total, ds.Added, ds.Removed, ds.Modified, ds.Moved, ds.Reordered)
 }
 
 // HasChanges returns true if there are any differences
@@ -103,7 +118,10 @@ func (dr *DiffResult) GetByType(diffType DiffType) []Difference {
 	return filtered
 }
 
-// CompareConfigs compares two nginx configurations and returns the differences
+// CompareConfigs compares two nginx configurations and returns the differences. Servers are
+// matched by server_name and listen, locations by modifier and match, and repeated directives by
+// their parameters, so a change to one server is not reported as a change to another. Blocks moved
+// to another block are reported as DiffMoved, directives whose order changed as DiffReordered.
 func CompareConfigs(oldConfig, newConfig *config.Config) *DiffResult {
 	differ := &configDiffer{
 		result: &DiffResult{
@@ -113,6 +131,7 @@ func CompareConfigs(oldConfig, newConfig *config.Config) *DiffResult {
 	}
 
 	differ.compareBlocks("", oldConfig.Block, newConfig.Block)
+	differ.findMoves()
 	differ.calculateSummary()
 
 	return differ.result
@@ -152,9 +171,27 @@ func CompareConfigMaps(oldConfig, newConfig map[string]interface{}) *DiffResult
 	return differ.result
 }
 
-// configDiffer handles comparison between config.Config objects
+// configDiffer handles comparison between config.Config objects. Directives are matched by
+// identity rather than by name: servers by server_name and listen, locations by modifier and
+// match, upstreams and other blocks by their parameters, and repeated directives like add_header
+// by a longest common subsequence of their parameters.
 type configDiffer struct {
-	result *DiffResult
+	result  *DiffResult
+	removed []pendingDiff // removed directives, paired with added ones into moves by findMoves
+	added   []pendingDiff
+}
+
+// pendingDiff is an added or removed directive that may turn out to be moved
+type pendingDiff struct {
+	index     int // the index of the difference in result
+	path      string
+	label     string // the name of the directive in a path, see directiveLabel
+	directive config.IDirective
+}
+
+// directivePair is a directive of the old block matched with one of the new block, by index
+type directivePair struct {
+	old, new int
 }
 
 func (cd *configDiffer) compareBlocks(path string, oldBlock, newBlock config.IBlock) {
@@ -172,63 +209,428 @@ func (cd *configDiffer) compareBlocks(path string, oldBlock, newBlock config.IBl
 		return
 	}
 
-	// Compare directives
-	oldDirectives := cd.getDirectiveMap(oldBlock.GetDirectives())
-	newDirectives := cd.getDirectiveMap(newBlock.GetDirectives())
+	oldDirectives := oldBlock.GetDirectives()
+	newDirectives := newBlock.GetDirectives()
+	pairs := matchDirectives(oldDirectives, newDirectives)
+	reordered := cd.reorderedPairs(pairs)
 
-	// Find added and modified directives
-	for name, newDir := range newDirectives {
-		if oldDir, exists := oldDirectives[name]; exists {
-			cd.compareDirectives(path, oldDir, newDir)
-		} else {
-			cd.addDirectiveDifference(DiffAdded, path, newDir, "", cd.getDirectiveValue(newDir))
-		}
+	matchedOld := make(map[int]bool)
+	matchedNew := make(map[int]directivePair)
+	for _, pair := range pairs {
+		matchedOld[pair.old] = true
+		matchedNew[pair.new] = pair
 	}
 
 	// Find removed directives
-	for name, oldDir := range oldDirectives {
-		if _, exists := newDirectives[name]; !exists {
-			cd.addDirectiveDifference(DiffRemoved, path, oldDir, cd.getDirectiveValue(oldDir), "")
+	for i, oldDir := range oldDirectives {
+		if !matchedOld[i] {
+			diff := cd.addDirectiveDifference(DiffRemoved, path, oldDir, nil)
+			diff.oldAfter = anchorBefore(oldDirectives, i)
+			cd.removed = append(cd.removed, cd.pending(path, oldDir, oldDirectives))
 		}
 	}
+
+	// Find added, modified and reordered directives
+	for j, newDir := range newDirectives {
+		pair, exists := matchedNew[j]
+		if !exists {
+			diff := cd.addDirectiveDifference(DiffAdded, path, nil, newDir)
+			diff.newAfter = anchorBefore(newDirectives, j)
+			cd.added = append(cd.added, cd.pending(path, newDir, newDirectives))
+			continue
+		}
+		if reordered[pair] {
+			diff := cd.addDirectiveDifference(DiffReordered, path, oldDirectives[pair.old], newDir)
+			diff.Description = fmt.Sprintf("position %d -> %d", pair.old+1, pair.new+1)
+			diff.oldAfter, diff.newAfter = anchorBefore(oldDirectives, pair.old), anchorBefore(newDirectives, pair.new)
+		}
+		cd.compareDirectives(path, oldDirectives, newDirectives, pair)
+	}
+}
+
+// pending returns the last difference as one findMoves may pair
+func (cd *configDiffer) pending(path string, dir config.IDirective, siblings []config.IDirective) pendingDiff {
+	return pendingDiff{
+		index:     len(cd.result.Differences) - 1,
+		path:      path,
+		label:     directiveLabel(dir, siblings),
+		directive: dir,
+	}
 }
 
-func (cd *configDiffer) compareDirectives(path string, oldDir, newDir config.IDirective) {
+func (cd *configDiffer) compareDirectives(path string, oldDirectives, newDirectives []config.IDirective, pair directivePair) {
+	oldDir, newDir := oldDirectives[pair.old], newDirectives[pair.new]
 	oldValue := cd.getDirectiveValue(oldDir)
 	newValue := cd.getDirectiveValue(newDir)
 
 	if oldValue != newValue {
-		cd.addDirectiveDifference(DiffModified, path, newDir, oldValue, newValue)
+		diff := cd.addDirectiveDifference(DiffModified, path, oldDir, newDir)
+		diff.oldAfter, diff.newAfter = anchorBefore(oldDirectives, pair.old), anchorBefore(newDirectives, pair.new)
 	}
 
 	// Compare blocks if both directives have them
+	blockPath := cd.buildPath(path, directiveLabel(newDir, newDirectives))
 	if oldDir.GetBlock() != nil && newDir.GetBlock() != nil {
-		blockPath := cd.buildPath(path, newDir.GetName())
 		cd.compareBlocks(blockPath, oldDir.GetBlock(), newDir.GetBlock())
 	} else if oldDir.GetBlock() != nil {
-		blockPath := cd.buildPath(path, newDir.GetName())
 		cd.addDifference(DiffRemoved, blockPath, "block", "removed", "", "")
 	} else if newDir.GetBlock() != nil {
-		blockPath := cd.buildPath(path, newDir.GetName())
 		cd.addDifference(DiffAdded, blockPath, "block", "", "added", "")
 	}
 }
 
-func (cd *configDiffer) getDirectiveMap(directives []config.IDirective) map[string]config.IDirective {
-	directiveMap := make(map[string]config.IDirective)
-	for _, dir := range directives {
-		// For simplicity, use directive name as key
-		// In a more sophisticated implementation, we might include parameters
-		key := dir.GetName()
-		if len(dir.GetParameters()) > 0 {
-			key += ":" + cd.getDirectiveValue(dir)
+// matchDirectives pairs the directives of two blocks: blocks by identity, then by the looser
+// identity and by position when a single block of a name is left on both sides, and leaf
+// directives of the same name with matchRepeated
+func matchDirectives(oldDirectives, newDirectives []config.IDirective) []directivePair {
+	var pairs []directivePair
+	group := func(directives []config.IDirective) (map[string][]int, []string) {
+		groups := make(map[string][]int)
+		var names []string
+		for i, dir := range directives {
+			key := "leaf " + dir.GetName()
+			if dir.GetBlock() != nil {
+				key = "block " + dir.GetName()
+			}
+			if _, exists := groups[key]; !exists {
+				names = append(names, key)
+			}
+			groups[key] = append(groups[key], i)
+		}
+		return groups, names
+	}
+	oldGroups, names := group(oldDirectives)
+	newGroups, _ := group(newDirectives)
+
+	for _, key := range names {
+		oldIndexes, newIndexes := oldGroups[key], newGroups[key]
+		if len(newIndexes) == 0 {
+			continue
+		}
+		if strings.HasPrefix(key, "block ") {
+			identity := func(loose bool) func(config.IDirective) string {
+				return func(dir config.IDirective) string {
+					strict, looseKey := directiveIdentity(dir)
+					if loose {
+						return looseKey
+					}
+					return strict
+				}
+			}
+			matched := matchByKey(oldDirectives, newDirectives, oldIndexes, newIndexes, identity(false))
+			oldLeft, newLeft := unmatched(oldIndexes, newIndexes, matched)
+			matched = append(matched, matchByKey(oldDirectives, newDirectives, oldLeft, newLeft, identity(true))...)
+			// a block left alone on both sides is the same one changed, like the only unnamed server
+			oldLeft, newLeft = unmatched(oldIndexes, newIndexes, matched)
+			if len(oldLeft) == 1 && len(newLeft) == 1 {
+				matched = append(matched, directivePair{old: oldLeft[0], new: newLeft[0]})
+			}
+			pairs = append(pairs, matched...)
+			continue
+		}
+		pairs = append(pairs, matchRepeated(oldDirectives, newDirectives, oldIndexes, newIndexes)...)
+	}
+	return pairs
+}
+
+// matchRepeated pairs directives of the same name: the longest common subsequence of their
+// values first, then equal values out of order, then the same first parameter like the header
+// name of add_header, and a last pair when only one directive is left on each side
+func matchRepeated(oldDirectives, newDirectives []config.IDirective, oldIndexes, newIndexes []int) []directivePair {
+	oldValues := make([]string, len(oldIndexes))
+	for i, index := range oldIndexes {
+		oldValues[i] = directiveValue(oldDirectives[index])
+	}
+	newValues := make([]string, len(newIndexes))
+	for j, index := range newIndexes {
+		newValues[j] = directiveValue(newDirectives[index])
+	}
+
+	var pairs []directivePair
+	for _, pair := range longestCommonSubsequence(oldValues, newValues) {
+		pairs = append(pairs, directivePair{old: oldIndexes[pair.old], new: newIndexes[pair.new]})
+	}
+
+	oldLeft, newLeft := unmatched(oldIndexes, newIndexes, pairs)
+	pairs = append(pairs, matchByKey(oldDirectives, newDirectives, oldLeft, newLeft, directiveValue)...)
+
+	oldLeft, newLeft = unmatched(oldIndexes, newIndexes, pairs)
+	pairs = append(pairs, matchByKey(oldDirectives, newDirectives, oldLeft, newLeft, func(dir config.IDirective) string {
+		if params := dir.GetParameters(); len(params) > 1 {
+			return params[0].GetValue()
+		}
+		return ""
+	})...)
+
+	oldLeft, newLeft = unmatched(oldIndexes, newIndexes, pairs)
+	if len(oldLeft) == 1 && len(newLeft) == 1 {
+		pairs = append(pairs, directivePair{old: oldLeft[0], new: newLeft[0]})
+	}
+	return pairs
+}
+
+// matchByKey pairs directives with the same non empty key, in order
+func matchByKey(oldDirectives, newDirectives []config.IDirective, oldIndexes, newIndexes []int, key func(config.IDirective) string) []directivePair {
+	var pairs []directivePair
+	used := make(map[int]bool)
+	for _, i := range oldIndexes {
+		oldKey := key(oldDirectives[i])
+		if oldKey == "" {
+			continue
+		}
+		for _, j := range newIndexes {
+			if !used[j] && key(newDirectives[j]) == oldKey {
+				used[j] = true
+				pairs = append(pairs, directivePair{old: i, new: j})
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+// unmatched returns the indexes that are in no pair
+func unmatched(oldIndexes, newIndexes []int, pairs []directivePair) ([]int, []int) {
+	matchedOld := make(map[int]bool)
+	matchedNew := make(map[int]bool)
+	for _, pair := range pairs {
+		matchedOld[pair.old] = true
+		matchedNew[pair.new] = true
+	}
+	var oldLeft, newLeft []int
+	for _, i := range oldIndexes {
+		if !matchedOld[i] {
+			oldLeft = append(oldLeft, i)
+		}
+	}
+	for _, j := range newIndexes {
+		if !matchedNew[j] {
+			newLeft = append(newLeft, j)
+		}
+	}
+	return oldLeft, newLeft
+}
+
+// longestCommonSubsequence returns the pairs of equal values of a longest common subsequence
+func longestCommonSubsequence(a, b []string) []directivePair {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	var pairs []directivePair
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			pairs = append(pairs, directivePair{old: i, new: j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// reorderedPairs returns the matched directives whose order changed: those outside the
+// longest run of pairs that kept their relative order
+func (cd *configDiffer) reorderedPairs(pairs []directivePair) map[directivePair]bool {
+	sorted := append([]directivePair(nil), pairs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].old < sorted[j].old })
+
+	// longest increasing subsequence of the new positions
+	lengths := make([]int, len(sorted))
+	previous := make([]int, len(sorted))
+	best := -1
+	for i := range sorted {
+		lengths[i], previous[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if sorted[j].new < sorted[i].new && lengths[j]+1 > lengths[i] {
+				lengths[i], previous[i] = lengths[j]+1, j
+			}
+		}
+		if best == -1 || lengths[i] > lengths[best] {
+			best = i
+		}
+	}
+	inOrder := make(map[directivePair]bool)
+	for i := best; i >= 0; i = previous[i] {
+		inOrder[sorted[i]] = true
+	}
+
+	reordered := make(map[directivePair]bool)
+	for _, pair := range sorted {
+		if !inOrder[pair] {
+			reordered[pair] = true
 		}
-		directiveMap[key] = dir
 	}
-	return directiveMap
+	return reordered
+}
+
+// identity returns the key matching a block directive with its counterpart, and a looser key
+// used for the blocks left unmatched, like a server whose listen changed. A server without
+// server_name has its listen for both.
+func directiveIdentity(dir config.IDirective) (string, string) {
+	switch dir.GetName() {
+	case "server":
+		var listens []string
+		for _, child := range dir.GetBlock().GetDirectives() {
+			if child.GetName() == "listen" {
+				if params := child.GetParameters(); len(params) > 0 {
+					listens = append(listens, params[0].GetValue())
+				}
+			}
+		}
+		name, listen := serverName(dir), strings.Join(listens, ",")
+		if name == "" {
+			// an unnamed server, like a default server, is known by where it listens
+			return listen, listen
+		}
+		return strings.TrimSpace(name + " " + listen), name
+	case "location":
+		if location, ok := dir.(*config.Location); ok {
+			return strings.TrimSpace(location.Modifier + " " + location.Match), location.Match
+		}
+	}
+	value := directiveValue(dir)
+	if params := dir.GetParameters(); len(params) > 0 {
+		return value, params[0].GetValue()
+	}
+	return value, dir.GetName()
+}
+
+// directiveLabel names a block directive among its siblings in a path, like location[= /api].
+// Servers are named by their server_name, with their listen when another server has the same
+// names. The only server without server_name is just server, whatever it listens on.
+func directiveLabel(dir config.IDirective, siblings []config.IDirective) string {
+	key, loose := directiveIdentity(dir)
+	if dir.GetName() == "server" && dir.GetBlock() != nil {
+		unnamed := serverName(dir) == ""
+		if unnamed {
+			key = ""
+		} else {
+			key = loose
+		}
+		for _, sibling := range siblings {
+			if sibling == dir || sibling.GetName() != "server" || sibling.GetBlock() == nil {
+				continue
+			}
+			_, siblingLoose := directiveIdentity(sibling)
+			if (unnamed && serverName(sibling) == "") || (!unnamed && siblingLoose == loose) {
+				key, _ = directiveIdentity(dir)
+				break
+			}
+		}
+	}
+	if key != "" {
+		return dir.GetName() + "[" + key + "]"
+	}
+	return dir.GetName()
+}
+
+// serverName returns the names of a server block, empty when it has no server_name
+func serverName(dir config.IDirective) string {
+	var names []string
+	for _, child := range dir.GetBlock().GetDirectives() {
+		if child.GetName() == "server_name" {
+			names = append(names, directiveValue(child))
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// findMoves turns a removed directive and an added one with the same content in another block into a move
+func (cd *configDiffer) findMoves() {
+	replaced := make(map[int]bool)
+	usedAdded := make(map[int]bool)
+	for _, removed := range cd.removed {
+		content := canonical(removed.directive)
+		for i, added := range cd.added {
+			if usedAdded[i] || added.path == removed.path || canonical(added.directive) != content {
+				continue
+			}
+			usedAdded[i] = true
+			replaced[added.index] = true
+			moved := &cd.result.Differences[removed.index]
+			moved.Type = DiffMoved
+			moved.NewValue = cd.buildPath(added.path, added.label)
+			moved.NewLine = directiveLine(added.directive)
+			moved.Line = moved.NewLine
+			moved.Description = fmt.Sprintf("line %d -> %d", moved.OldLine, moved.NewLine)
+			moved.NewDirective = added.directive
+			moved.newAfter = cd.result.Differences[added.index].newAfter
+			moved.newPath = added.path
+			break
+		}
+	}
+
+	differences := cd.result.Differences[:0]
+	for i, diff := range cd.result.Differences {
+		if !replaced[i] {
+			differences = append(differences, diff)
+		}
+	}
+	cd.result.Differences = differences
+}
+
+// anchorBefore returns the anchor of the directive before the i-th one, empty for the first one
+func anchorBefore(directives []config.IDirective, i int) string {
+	if i == 0 {
+		return ""
+	}
+	return directiveAnchor(directives[i-1], directives)
+}
+
+// directiveAnchor names a directive among its siblings: the label of a block directive,
+// the name and parameters of the others
+func directiveAnchor(dir config.IDirective, siblings []config.IDirective) string {
+	if dir.GetBlock() != nil {
+		return directiveLabel(dir, siblings)
+	}
+	return strings.TrimSpace(dir.GetName() + " " + directiveValue(dir))
+}
+
+// directiveLine returns the line a directive starts on, GetLine gives the end of a block
+func directiveLine(dir config.IDirective) int {
+	if r := config.RangeOf(dir); r != nil && r.IsValid() {
+		return r.Start.Line
+	}
+	return dir.GetLine()
+}
+
+// canonical writes a directive with its block on one line, to compare contents
+func canonical(dir config.IDirective) string {
+	var b strings.Builder
+	b.WriteString(dir.GetName())
+	for _, param := range dir.GetParameters() {
+		b.WriteString(" " + param.GetValue())
+	}
+	if block := dir.GetBlock(); block != nil {
+		b.WriteString(" {")
+		for _, child := range block.GetDirectives() {
+			b.WriteString(" " + canonical(child) + ";")
+		}
+		b.WriteString(" }")
+	}
+	return b.String()
 }
 
 func (cd *configDiffer) getDirectiveValue(dir config.IDirective) string {
+	return directiveValue(dir)
+}
+
+// directiveValue returns the parameters of a directive joined by spaces
+func directiveValue(dir config.IDirective) string {
 	if dir == nil {
 		return ""
 	}
@@ -246,11 +648,25 @@ func (cd *configDiffer) getDirectiveValue(dir config.IDirective) string {
 	return strings.Join(values, " ")
 }
 
-func (cd *configDiffer) addDirectiveDifference(diffType DiffType, path string, dir config.IDirective, oldValue, newValue string) {
-	cd.addDifference(diffType, path, dir.GetName(), oldValue, newValue, "")
+// addDirectiveDifference records a difference of a directive with its lines on both sides,
+// oldDir is nil for an added directive and newDir for a removed one
+func (cd *configDiffer) addDirectiveDifference(diffType DiffType, path string, oldDir, newDir config.IDirective) *Difference {
+	dir := newDir
+	if dir == nil {
+		dir = oldDir
+	}
+	diff := cd.addDifference(diffType, path, dir.GetName(), cd.getDirectiveValue(oldDir), cd.getDirectiveValue(newDir), "")
+	diff.OldDirective, diff.NewDirective = oldDir, newDir
+	if oldDir != nil {
+		diff.OldLine, diff.Line = directiveLine(oldDir), directiveLine(oldDir)
+	}
+	if newDir != nil {
+		diff.NewLine, diff.Line = directiveLine(newDir), directiveLine(newDir)
+	}
+	return diff
 }
 
-func (cd *configDiffer) addDifference(diffType DiffType, path, directiveName, oldValue, newValue, description string) {
+func (cd *configDiffer) addDifference(diffType DiffType, path, directiveName, oldValue, newValue, description string) *Difference {
 	diff := Difference{
 		Type:          diffType,
 		Path:          path,
@@ -261,6 +677,7 @@ func (cd *configDiffer) addDifference(diffType DiffType, path, directiveName, ol
 	}
 
 	cd.result.Differences = append(cd.result.Differences, diff)
+	return &cd.result.Differences[len(cd.result.Differences)-1]
 }
 
 func (cd *configDiffer) buildPath(parentPath, name string) string {
@@ -283,6 +700,8 @@ func (cd *configDiffer) calculateSummary() {
 			summary.Modified++
 		case DiffMoved:
 			summary.Moved++
+		case DiffReordered:
+			summary.Reordered++
 		}
 	}
 	cd.result.Summary = summary
@@ -0,0 +1,153 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/utils"
+	"gotest.tools/v3/assert"
+)
+
+func parse(t *testing.T, s string) *config.Config {
+	t.Helper()
+	conf, err := parser.NewStringParser(s).Parse()
+	assert.NilError(t, err)
+	return conf
+}
+
+// diffLines compares two configs and returns every difference as a string
+func diffLines(t *testing.T, oldConf, newConf string) []string {
+	t.Helper()
+	result := utils.CompareConfigs(parse(t, oldConf), parse(t, newConf))
+	lines := make([]string, 0, len(result.Differences))
+	for _, diff := range result.Differences {
+		lines = append(lines, diff.String())
+	}
+	return lines
+}
+
+func TestCompareConfigs_ServerIdentity(t *testing.T) {
+	t.Parallel()
+	oldConf := `http {
+    server {
+        listen 80;
+        server_name a.com;
+        root /a;
+    }
+    server {
+        listen 80;
+        server_name b.com;
+        root /b;
+    }
+}`
+	// b.com moved up and changed, a.com did not change
+	newConf := `http {
+    server {
+        listen 80;
+        server_name b.com;
+        root /srv/b;
+    }
+    server {
+        listen 80;
+        server_name a.com;
+        root /a;
+    }
+}`
+	assert.DeepEqual(t, diffLines(t, oldConf, newConf), []string{
+		"> [http] server: reordered (line 7 -> 2)",
+		"~ [http/server[b.com]] root: /b -> /srv/b",
+	})
+}
+
+func TestCompareConfigs_UnnamedServers(t *testing.T) {
+	t.Parallel()
+	// unnamed servers are told apart by their listen
+	oldConf := "http {\n    server {\n        listen 8080;\n        root /a;\n    }\n    server {\n        listen 8081;\n        root /b;\n    }\n}"
+	newConf := "http {\n    server {\n        listen 8080;\n        root /a;\n    }\n    server {\n        listen 8081;\n        root /c;\n    }\n}"
+	assert.DeepEqual(t, diffLines(t, oldConf, newConf), []string{
+		"~ [http/server[8081]] root: /b -> /c",
+	})
+
+	// the only unnamed server is the same one when its listen changes
+	assert.DeepEqual(t, diffLines(t,
+		"http {\n    server {\n        listen 8080;\n        root /a;\n    }\n}",
+		"http {\n    server {\n        listen 8081;\n        root /a;\n    }\n}",
+	), []string{
+		"~ [http/server] listen: 8080 -> 8081",
+	})
+}
+
+func TestCompareConfigs_Locations(t *testing.T) {
+	t.Parallel()
+	// locations are matched by modifier and match, then by match alone when the modifier changed
+	assert.DeepEqual(t, diffLines(t,
+		"server {\n    location /api {\n        return 200;\n    }\n    location /web {\n    }\n}",
+		"server {\n    location /web {\n    }\n    location = /api {\n        return 200;\n    }\n}",
+	), []string{
+		"> [server] location /web: reordered (line 5 -> 2)",
+		"~ [server] location: /api -> = /api",
+	})
+	assert.DeepEqual(t, diffLines(t,
+		"server {\n    location /a {\n    }\n    location ~ /a {\n        return 200;\n    }\n}",
+		"server {\n    location /a {\n    }\n    location ~ /a {\n        return 204;\n    }\n}",
+	), []string{
+		"~ [server/location[~ /a]] return: 200 -> 204",
+	})
+}
+
+func TestCompareConfigs_RepeatedDirectives(t *testing.T) {
+	t.Parallel()
+	oldConf := "server {\n    add_header X-A a;\n    add_header X-B b;\n    add_header X-C c;\n}"
+
+	// an inserted header is the only change, the ones after it are not shifted
+	assert.DeepEqual(t, diffLines(t, oldConf,
+		"server {\n    add_header X-A a;\n    add_header X-N n;\n    add_header X-B b;\n    add_header X-C c;\n}",
+	), []string{
+		"+ [server] add_header: X-N n",
+	})
+
+	// a header keeps its name when its value changes
+	assert.DeepEqual(t, diffLines(t, oldConf,
+		"server {\n    add_header X-A a;\n    add_header X-B changed;\n    add_header X-C c;\n}",
+	), []string{
+		"~ [server] add_header: X-B b -> X-B changed",
+	})
+
+	// headers swapped around keep their values
+	assert.DeepEqual(t, diffLines(t, oldConf,
+		"server {\n    add_header X-C c;\n    add_header X-A a;\n    add_header X-B b;\n}",
+	), []string{
+		"> [server] add_header X-C c: reordered (line 4 -> 2)",
+	})
+}
+
+func TestCompareConfigs_Moved(t *testing.T) {
+	t.Parallel()
+	result := utils.CompareConfigs(
+		parse(t, "http {\n    server {\n        server_name a.com;\n        location /x {\n            return 204;\n        }\n    }\n    server {\n        server_name b.com;\n    }\n}"),
+		parse(t, "http {\n    server {\n        server_name a.com;\n    }\n    server {\n        server_name b.com;\n        location /x {\n            return 204;\n        }\n    }\n}"),
+	)
+	assert.Equal(t, len(result.Differences), 1)
+	moved := result.Differences[0]
+	assert.Equal(t, moved.String(), "^ [http/server[a.com]] location: moved to http/server[b.com]/location[/x]")
+	assert.Equal(t, moved.OldLine, 4)
+	assert.Equal(t, moved.NewLine, 7)
+	assert.Equal(t, result.Summary.String(), "Total: 1, Added: 0, Removed: 0, Modified: 0, Moved: 1, Reordered: 0")
+	assert.Equal(t, len(result.GetByType(utils.DiffMoved)), 1)
+	assert.Equal(t, len(result.GetByType(utils.DiffAdded)), 0)
+
+	// the same location with other contents is not a move
+	result = utils.CompareConfigs(
+		parse(t, "server {\n    server_name a.com;\n    location /x {\n        return 204;\n    }\n}"),
+		parse(t, "server {\n    server_name a.com;\n    if ($a) {\n        location /x {\n            return 200;\n        }\n    }\n}"),
+	)
+	assert.Equal(t, result.Summary.String(), "Total: 2, Added: 1, Removed: 1, Modified: 0, Moved: 0, Reordered: 0")
+}
+
+func TestCompareConfigs_NoChanges(t *testing.T) {
+	t.Parallel()
+	result := utils.CompareConfigs(parse(t, "user nginx;\nhttp {\n}"), parse(t, "user nginx;\nhttp {\n}"))
+	assert.Assert(t, !result.HasChanges())
+	assert.Equal(t, result.Summary.Total, 0)
+}
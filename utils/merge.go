@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/lefeck/gonginx/config"
+)
+
+// MergeConflict is a directive both sides of a merge changed differently
+type MergeConflict struct {
+	Path       string // like http/server[api.example.com]/location[/v1]/proxy_pass
+	Base       string // the value in the base config, empty when both sides added the directive
+	Ours       string // the value of ours, empty when ours removed the directive
+	Theirs     string // the value of theirs, empty when theirs removed the directive
+	OursLine   int
+	TheirsLine int
+	Directive  *config.Conflict // the conflict in the merged config
+}
+
+// String returns a human-readable representation of the conflict
+func (mc *MergeConflict) String() string {
+	side := func(dir config.IDirective, value string) string {
+		if dir == nil {
+			return "removed"
+		}
+		if value == "" {
+			// a block without parameters, like an unnamed server
+			return dir.GetName()
+		}
+		return value
+	}
+	return fmt.Sprintf("! [%s] ours: %s, theirs: %s", mc.Path,
+		side(mc.Directive.Ours, mc.Ours), side(mc.Directive.Theirs, mc.Theirs))
+}
+
+// MergeResult represents the result of a three-way merge
+type MergeResult struct {
+	Config    *config.Config
+	Conflicts []MergeConflict
+}
+
+// HasConflicts returns true if some changes could not be merged
+func (mr *MergeResult) HasConflicts() bool {
+	return len(mr.Conflicts) > 0
+}
+
+// Merge merges the changes ours and theirs made to base, matching directives by identity like
+// CompareConfigs: a block left alone of its name on two sides, like an unnamed default server, is
+// the same block even when its listen changed. The merged config starts as a copy of ours and gets
+// the changes of theirs: directives theirs added, removed or modified and ours left as in base.
+// Directives both sides changed differently are conflicts, as is a block theirs changed that ours
+// has no single counterpart for. They are in the merged config as *config.Conflict holding both
+// sides and behave as ours, or as theirs when ours removed them. The dumper writes them with
+// comment markers when Style.ConflictMarkers is set. A nil config is merged as an empty one.
+func Merge(base, ours, theirs *config.Config) *MergeResult {
+	empty := func(c *config.Config) *config.Config {
+		if c == nil {
+			return &config.Config{Block: &config.Block{}}
+		}
+		return c
+	}
+	base, ours, theirs = empty(base), empty(ours), empty(theirs)
+
+	merged := ours.Clone()
+	m := &merger{}
+	m.mergeBlocks("", base.Block, merged.Block, theirs.Block)
+	return &MergeResult{Config: merged, Conflicts: m.conflicts}
+}
+
+// merger applies the changes of theirs to the copy of ours, block by block
+type merger struct {
+	conflicts []MergeConflict
+}
+
+// mergeBlocks merges the directives of a block of ours, changed in place, with the changes from
+// base to theirs. base is nil when both sides added the block.
+func (m *merger) mergeBlocks(path string, base, merged, theirs config.IBlock) {
+	var baseDirectives []config.IDirective
+	if base != nil {
+		baseDirectives = base.GetDirectives()
+	}
+	oursDirectives := merged.GetDirectives()
+	theirsDirectives := theirs.GetDirectives()
+
+	oursOf, baseOfOurs := pairIndexes(matchDirectives(baseDirectives, oursDirectives))
+	theirsOf, baseOfTheirs := pairIndexes(matchDirectives(baseDirectives, theirsDirectives))
+
+	// directives added by both sides are matched with each other
+	oursAdded := addedIndexes(oursDirectives, baseOfOurs)
+	theirsAdded := addedIndexes(theirsDirectives, baseOfTheirs)
+	theirsOfAdded := make(map[int]int)
+	for _, pair := range matchDirectives(subset(oursDirectives, oursAdded), subset(theirsDirectives, theirsAdded)) {
+		theirsOfAdded[oursAdded[pair.old]] = theirsAdded[pair.new]
+	}
+
+	// placed maps the directives of theirs to their counterpart in the merged block,
+	// the directives only theirs has are inserted after the counterpart of the one before them
+	placed := make(map[int]config.IDirective)
+	for i, o := range oursDirectives {
+		b, inBase := baseOfOurs[i]
+		switch {
+		case inBase:
+			t, inTheirs := theirsOf[b]
+			if inTheirs {
+				placed[t] = m.mergeDirective(path, merged, oursDirectives, baseDirectives[b], o, theirsDirectives[t])
+			} else if !sameDirective(baseDirectives[b], o) {
+				// theirs removed what ours changed
+				_ = config.ReplaceWith(merged, o, m.conflict(path, oursDirectives, baseDirectives[b], o, nil))
+			} else {
+				_ = config.Remove(merged, o)
+			}
+		default:
+			if t, ok := theirsOfAdded[i]; ok {
+				placed[t] = m.mergeDirective(path, merged, oursDirectives, nil, o, theirsDirectives[t])
+			}
+		}
+	}
+
+	var previous config.IDirective
+	for t, theirsDir := range theirsDirectives {
+		if dir, ok := placed[t]; ok {
+			previous = dir
+			continue
+		}
+		var inserted config.IDirective
+		if b, inBase := baseOfTheirs[t]; inBase {
+			if _, inOurs := oursOf[b]; inOurs || sameDirective(baseDirectives[b], theirsDir) {
+				continue
+			}
+			// ours removed what theirs changed
+			inserted = m.conflict(path, theirsDirectives, baseDirectives[b], nil, config.Clone(theirsDir))
+		} else {
+			inserted = config.Clone(theirsDir)
+		}
+		insertAfter(merged, previous, inserted)
+		previous = inserted
+	}
+}
+
+// mergeDirective merges a directive the three sides have, or that both sides added when base
+// is nil, and returns the directive that stands for it in the merged block
+func (m *merger) mergeDirective(path string, merged config.IBlock, siblings []config.IDirective, base, ours, theirs config.IDirective) config.IDirective {
+	switch {
+	case sameDirective(ours, theirs) || (base != nil && sameDirective(base, theirs)):
+		return ours
+	case base != nil && sameDirective(base, ours):
+		clone := config.Clone(theirs)
+		_ = config.ReplaceWith(merged, ours, clone)
+		return clone
+	case ours.GetBlock() != nil && theirs.GetBlock() != nil && directiveHeader(ours) == directiveHeader(theirs):
+		var baseBlock config.IBlock
+		if base != nil {
+			baseBlock = base.GetBlock()
+		}
+		m.mergeBlocks(joinPath(path, directiveLabel(ours, siblings)), baseBlock, ours.GetBlock(), theirs.GetBlock())
+		return ours
+	}
+	conflict := m.conflict(path, siblings, base, ours, config.Clone(theirs))
+	_ = config.ReplaceWith(merged, ours, conflict)
+	return conflict
+}
+
+// conflict records a conflict between the directives of both sides, one of them may be nil
+func (m *merger) conflict(path string, siblings []config.IDirective, base, ours, theirs config.IDirective) *config.Conflict {
+	dir := ours
+	if dir == nil {
+		dir = theirs
+	}
+	name := dir.GetName()
+	if dir.GetBlock() != nil {
+		name = directiveLabel(dir, siblings)
+	}
+	conflict := config.NewConflict(joinPath(path, name), ours, theirs)
+
+	mc := MergeConflict{Path: conflict.Path, Directive: conflict}
+	if base != nil {
+		mc.Base = directiveValue(base)
+	}
+	if ours != nil {
+		mc.Ours, mc.OursLine = directiveValue(ours), directiveLine(ours)
+	}
+	if theirs != nil {
+		mc.Theirs, mc.TheirsLine = directiveValue(theirs), directiveLine(theirs)
+	}
+	m.conflicts = append(m.conflicts, mc)
+	return conflict
+}
+
+// insertAfter inserts dir after anchor in block, at its start when anchor is nil
+func insertAfter(block config.IBlock, anchor, dir config.IDirective) {
+	index := 0
+	for i, d := range block.GetDirectives() {
+		if d == anchor {
+			index = i + 1
+		}
+	}
+	_ = insertAt(block, index, dir)
+}
+
+// pairIndexes returns the pairs as maps from old to new index and from new to old index
+func pairIndexes(pairs []directivePair) (map[int]int, map[int]int) {
+	newOf := make(map[int]int, len(pairs))
+	oldOf := make(map[int]int, len(pairs))
+	for _, pair := range pairs {
+		newOf[pair.old] = pair.new
+		oldOf[pair.new] = pair.old
+	}
+	return newOf, oldOf
+}
+
+// addedIndexes returns the indexes of the directives that have no counterpart in base
+func addedIndexes(directives []config.IDirective, baseOf map[int]int) []int {
+	var added []int
+	for i := range directives {
+		if _, ok := baseOf[i]; !ok {
+			added = append(added, i)
+		}
+	}
+	return added
+}
+
+// subset returns the directives at indexes
+func subset(directives []config.IDirective, indexes []int) []config.IDirective {
+	result := make([]config.IDirective, len(indexes))
+	for i, index := range indexes {
+		result[i] = directives[index]
+	}
+	return result
+}
+
+// sameDirective tells whether two directives have the same parameters and contents
+func sameDirective(a, b config.IDirective) bool {
+	return canonical(a) == canonical(b)
+}
+
+// directiveHeader returns the name and parameters of a directive
+func directiveHeader(dir config.IDirective) string {
+	return dir.GetName() + " " + directiveValue(dir)
+}
+
+// joinPath appends name to a path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
@@ -0,0 +1,172 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	base := parse(t, `http {
+    gzip on;
+    server {
+        listen 80;
+        server_name api.example.com;
+        location /v1 {
+            proxy_pass http://v1;
+        }
+        location /old {
+            return 404;
+        }
+    }
+    server {
+        listen 80;
+        server_name www.example.com;
+        root /srv/www;
+    }
+}`)
+	ours := parse(t, `http {
+    gzip on;
+    add_header X-Ours 1;
+    server {
+        listen 80;
+        server_name www.example.com;
+        root /srv/www;
+    }
+    server {
+        listen 80;
+        server_name api.example.com;
+        location /v1 {
+            proxy_pass http://v1-ours;
+        }
+    }
+}`)
+	theirs := parse(t, `http {
+    gzip off;
+    server {
+        listen 80;
+        server_name api.example.com;
+        location /v1 {
+            proxy_pass http://v1-theirs;
+            proxy_read_timeout 5s;
+        }
+        location /old {
+            return 410;
+        }
+        location /new {
+            return 200;
+        }
+    }
+    server {
+        listen 80;
+        server_name www.example.com;
+        root /srv/www2;
+    }
+}`)
+
+	result := utils.Merge(base, ours, theirs)
+	assert.Equal(t, len(result.Conflicts), 2)
+	conflict := result.Conflicts[0]
+	assert.Equal(t, conflict.Path, "http/server[api.example.com]/location[/v1]/proxy_pass")
+	assert.Equal(t, conflict.Base, "http://v1")
+	assert.Equal(t, conflict.Ours, "http://v1-ours")
+	assert.Equal(t, conflict.Theirs, "http://v1-theirs")
+	assert.Equal(t, conflict.OursLine, 13)
+	assert.Equal(t, conflict.TheirsLine, 7)
+	assert.Equal(t, result.Conflicts[1].String(), "! [http/server[api.example.com]/location[/old]] ours: removed, theirs: /old")
+
+	style := dumper.NewStyle()
+	assert.Equal(t, dumper.DumpConfig(result.Config, style), `http {
+    gzip off;
+    add_header X-Ours 1;
+    server {
+        listen 80;
+        server_name www.example.com;
+        root /srv/www2;
+    }
+    server {
+        listen 80;
+        server_name api.example.com;
+        location /v1 {
+            proxy_pass http://v1-ours;
+            proxy_read_timeout 5s;
+        }
+        location /old {
+            return 410;
+        }
+        location /new {
+            return 200;
+        }
+    }
+}`)
+
+	style.ConflictMarkers = true
+	assert.Equal(t, dumper.DumpConfig(result.Config, style), `http {
+    gzip off;
+    add_header X-Ours 1;
+    server {
+        listen 80;
+        server_name www.example.com;
+        root /srv/www2;
+    }
+    server {
+        listen 80;
+        server_name api.example.com;
+        location /v1 {
+            # <<<<<<< ours http/server[api.example.com]/location[/v1]/proxy_pass
+            proxy_pass http://v1-ours;
+            # =======
+            proxy_pass http://v1-theirs;
+            # >>>>>>> theirs
+            proxy_read_timeout 5s;
+        }
+        # <<<<<<< ours http/server[api.example.com]/location[/old]
+        # =======
+        location /old {
+            return 410;
+        }
+        # >>>>>>> theirs
+        location /new {
+            return 200;
+        }
+    }
+}`)
+
+	// the inputs are left untouched
+	assert.Equal(t, len(ours.FindDirectives("location")), 1)
+}
+
+func TestMerge_NoConflicts(t *testing.T) {
+	t.Parallel()
+	base := parse(t, "events {\n    worker_connections 512;\n}\n")
+	ours := parse(t, "worker_processes 2;\nevents {\n    worker_connections 512;\n}\n")
+	theirs := parse(t, "events {\n    worker_connections 1024;\n    multi_accept on;\n}\n")
+
+	result := utils.Merge(base, ours, theirs)
+	assert.Assert(t, !result.HasConflicts())
+	assert.Equal(t, dumper.DumpConfig(result.Config, dumper.IndentedStyle),
+		"worker_processes 2;\nevents {\n    worker_connections 1024;\n    multi_accept on;\n}")
+}
+
+func TestMerge_UnnamedServer(t *testing.T) {
+	t.Parallel()
+	base := parse(t, "http {\n    server {\n        listen 80 default_server;\n        root /a;\n    }\n}\n")
+	ours := parse(t, "http {\n    server {\n        listen 80 default_server;\n        root /b;\n    }\n}\n")
+	theirs := parse(t, "http {\n    server {\n        listen 8080 default_server;\n        root /a;\n        gzip on;\n    }\n}\n")
+
+	// the only server of each side is the same one, even with another listen
+	result := utils.Merge(base, ours, theirs)
+	assert.Assert(t, !result.HasConflicts())
+	assert.Equal(t, dumper.DumpConfig(result.Config, dumper.IndentedStyle),
+		"http {\n    server {\n        listen 8080 default_server;\n        root /b;\n        gzip on;\n    }\n}")
+
+	// when ours has several servers none of which is the one of base, which one theirs changed is a conflict
+	ours = parse(t, "http {\n    server {\n        listen 81;\n        root /b;\n    }\n    server {\n        listen 82;\n        root /c;\n    }\n}\n")
+	result = utils.Merge(base, ours, theirs)
+	assert.Equal(t, len(result.Conflicts), 1)
+	assert.Equal(t, result.Conflicts[0].String(), "! [http/server[8080]] ours: removed, theirs: server")
+	assert.Equal(t, len(result.Config.FindDirectives("server")), 3)
+}
@@ -0,0 +1,499 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/parser"
+)
+
+// PatchOp is the kind of a patch operation
+type PatchOp string
+
+const (
+	// PatchAdd adds a directive to a block
+	PatchAdd PatchOp = "add"
+	// PatchRemove removes a directive from a block
+	PatchRemove PatchOp = "remove"
+	// PatchReplace changes the parameters of a directive
+	PatchReplace PatchOp = "replace"
+	// PatchMove moves a directive to another block or to another position in its block
+	PatchMove PatchOp = "move"
+)
+
+// PatchOperation is one change of a patch. Blocks are addressed by paths like
+// http/server[example.com]/location[/v1], the segments are matched like CompareConfigs matches
+// directives: servers by server_name, with listen when needed, locations by modifier and match,
+// other blocks by their parameters. Directives are found in their block by name and parameters.
+type PatchOperation struct {
+	Op PatchOp `json:"op"`
+	// Path is the block holding the directive, empty for the main context
+	Path string `json:"path"`
+	// Directive is the name of the directive, the path segment of a block directive like location[/v1]
+	Directive string `json:"directive"`
+	// Value is the parameters the directive has before the operation, the target must have them
+	Value string `json:"value,omitempty"`
+	// NewValue is the parameters a replace operation sets
+	NewValue string `json:"new_value,omitempty"`
+	// Config is the directive as written in the config, with its block: the directive an add
+	// operation adds, the directive a remove operation expects to find
+	Config string `json:"config,omitempty"`
+	// To is the block a move operation moves the directive to, the same as Path for a reorder
+	To string `json:"to,omitempty"`
+	// After is the directive the added or moved directive goes after, as a path segment for
+	// a block and as name and parameters otherwise. Empty puts it first, a directive that is not
+	// found puts it last.
+	After string `json:"after,omitempty"`
+	// OldAfter is the directive the removed or moved directive was after, used by Reverse
+	OldAfter string `json:"old_after,omitempty"`
+}
+
+// String returns a human-readable representation of the operation
+func (op PatchOperation) String() string {
+	target := joinPath(op.Path, op.Directive)
+	switch op.Op {
+	case PatchReplace:
+		return fmt.Sprintf("replace %s: %s -> %s", target, op.Value, op.NewValue)
+	case PatchMove:
+		return fmt.Sprintf("move %s to %s", target, joinPath(op.To, op.Directive))
+	default:
+		return strings.TrimSpace(fmt.Sprintf("%s %s %s", op.Op, target, op.Value))
+	}
+}
+
+// Patch is a list of operations changing a config, made with DiffResult.Patch and applied with
+// ApplyPatch. It is meant to be stored as JSON.
+type Patch struct {
+	Operations []PatchOperation `json:"operations"`
+}
+
+// Reverse returns the patch undoing p
+func (p *Patch) Reverse() *Patch {
+	reversed := &Patch{Operations: make([]PatchOperation, 0, len(p.Operations))}
+	for i := len(p.Operations) - 1; i >= 0; i-- {
+		op := p.Operations[i]
+		switch op.Op {
+		case PatchAdd:
+			op.Op = PatchRemove
+		case PatchRemove:
+			op.Op = PatchAdd
+		case PatchReplace:
+			op.Value, op.NewValue = op.NewValue, op.Value
+		case PatchMove:
+			op.Path, op.To = op.To, op.Path
+		}
+		op.After, op.OldAfter = op.OldAfter, op.After
+		reversed.Operations = append(reversed.Operations, op)
+	}
+	return reversed
+}
+
+// Patch returns the patch changing the old configuration of the comparison into the new one.
+// It fails for the differences that cannot be applied, like a block turned into a simple directive.
+func (dr *DiffResult) Patch() (*Patch, error) {
+	patch := &Patch{Operations: make([]PatchOperation, 0, len(dr.Differences))}
+	var replaced []string // the blocks replaced as a whole, their differences are in the new block
+	for _, diff := range dr.Differences {
+		if diff.OldDirective == nil && diff.NewDirective == nil {
+			return nil, fmt.Errorf("cannot patch %q", diff.String())
+		}
+		if insidePath(diff.Path, replaced) {
+			continue
+		}
+		switch diff.Type {
+		case DiffAdded:
+			patch.Operations = append(patch.Operations, PatchOperation{Op: PatchAdd, Path: diff.Path,
+				Directive: directiveSegment(diff.NewDirective), Value: diff.NewValue,
+				Config: dumpDirective(diff.NewDirective), After: diff.newAfter})
+		case DiffRemoved:
+			patch.Operations = append(patch.Operations, removeOperation(diff))
+		case DiffModified:
+			if diff.NewDirective.GetBlock() == nil {
+				patch.Operations = append(patch.Operations, PatchOperation{Op: PatchReplace, Path: diff.Path,
+					Directive: diff.DirectiveName, Value: diff.OldValue, NewValue: diff.NewValue})
+				continue
+			}
+			// the parameters of a block cannot be replaced in place, the whole block is
+			newPath := joinPath(diff.Path, directiveSegment(diff.NewDirective))
+			replaced = append(replaced, newPath)
+			patch.Operations = append(patch.Operations, removeOperation(diff), PatchOperation{Op: PatchAdd,
+				Path: diff.Path, Directive: directiveSegment(diff.NewDirective), Value: diff.NewValue,
+				Config: dumpDirective(diff.NewDirective), After: diff.newAfter})
+		case DiffMoved, DiffReordered:
+			to := diff.Path
+			if diff.Type == DiffMoved {
+				to = diff.newPath
+			}
+			patch.Operations = append(patch.Operations, PatchOperation{Op: PatchMove, Path: diff.Path,
+				Directive: directiveSegment(diff.OldDirective), Value: diff.OldValue,
+				To: to, After: diff.newAfter, OldAfter: diff.oldAfter})
+		}
+	}
+	return patch, nil
+}
+
+// removeOperation returns the operation removing the old directive of a difference
+func removeOperation(diff Difference) PatchOperation {
+	return PatchOperation{Op: PatchRemove, Path: diff.Path, Directive: directiveSegment(diff.OldDirective),
+		Value: diff.OldValue, Config: dumpDirective(diff.OldDirective), OldAfter: diff.oldAfter}
+}
+
+// insidePath tells whether path is one of the blocks or inside one of them
+func insidePath(path string, blocks []string) bool {
+	for _, block := range blocks {
+		if path == block || strings.HasPrefix(path, block+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// directiveSegment returns how an operation names a directive: its path segment for a block
+// directive, its name otherwise
+func directiveSegment(dir config.IDirective) string {
+	if dir.GetBlock() == nil {
+		return dir.GetName()
+	}
+	key, _ := directiveIdentity(dir)
+	if key == "" {
+		return dir.GetName()
+	}
+	return dir.GetName() + "[" + key + "]"
+}
+
+// dumpDirective writes a directive as in a config file
+func dumpDirective(dir config.IDirective) string {
+	return dumper.DumpDirective(dir, dumper.IndentedStyle)
+}
+
+// PatchOption configures ApplyPatch
+type PatchOption func(*patchOptions)
+
+type patchOptions struct {
+	dryRun  bool
+	reverse bool
+}
+
+// WithDryRun checks that the patch applies without changing the config
+func WithDryRun() PatchOption {
+	return func(o *patchOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithReverse applies the reverse of the patch, undoing it
+func WithReverse() PatchOption {
+	return func(o *patchOptions) {
+		o.reverse = true
+	}
+}
+
+// PatchFailure is an operation of a patch that does not apply to a config
+type PatchFailure struct {
+	Index     int // the index of the operation in the patch
+	Operation PatchOperation
+	Reason    string
+}
+
+// PatchError is returned by ApplyPatch when operations do not apply, it lists all of them
+type PatchError struct {
+	Failures []PatchFailure
+}
+
+func (e *PatchError) Error() string {
+	messages := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		messages[i] = fmt.Sprintf("operation %d (%s): %s", failure.Index+1, failure.Operation, failure.Reason)
+	}
+	return "patch does not apply: " + strings.Join(messages, "; ")
+}
+
+// ApplyPatch applies a patch to a config. Every operation checks that the config is as the
+// patch expects it: the blocks of the paths exist, a removed, replaced or moved directive has the
+// expected parameters and contents, an added directive is not there yet. The config is only
+// changed when all operations apply, otherwise a *PatchError lists the ones that do not.
+func ApplyPatch(conf *config.Config, patch *Patch, opts ...PatchOption) error {
+	options := &patchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.reverse {
+		patch = patch.Reverse()
+	}
+
+	// check the whole patch on a copy first so that a failure leaves the config untouched
+	if err := applyOperations(conf.Clone(), patch); err != nil {
+		return err
+	}
+	if options.dryRun {
+		return nil
+	}
+	return applyOperations(conf, patch)
+}
+
+// applyOperations applies the operations in order, skipping and reporting the failing ones
+func applyOperations(conf *config.Config, patch *Patch) error {
+	var failures []PatchFailure
+	for i, op := range patch.Operations {
+		if err := applyOperation(conf, op); err != nil {
+			failures = append(failures, PatchFailure{Index: i, Operation: op, Reason: err.Error()})
+		}
+	}
+	if len(failures) > 0 {
+		return &PatchError{Failures: failures}
+	}
+	return nil
+}
+
+func applyOperation(conf *config.Config, op PatchOperation) error {
+	block, chain, err := resolvePath(conf, op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case PatchAdd:
+		dir, err := parseDirectiveIn(chain, op.Config)
+		if err != nil {
+			return err
+		}
+		if existing, _ := findDirective(block, directiveSegment(dir), directiveValue(dir)); existing != nil {
+			return fmt.Errorf("%s is already in %s", op.Directive, describePath(op.Path))
+		}
+		return insertAt(block, anchorIndex(block.GetDirectives(), op.After), dir)
+
+	case PatchRemove:
+		dir, err := expectDirective(block, chain, op)
+		if err != nil {
+			return err
+		}
+		return config.Remove(block, dir)
+
+	case PatchReplace:
+		dir, err := findDirective(block, op.Directive, op.Value)
+		if err != nil {
+			return err
+		}
+		replacement, err := parseDirectiveIn(chain, op.Directive+" "+op.NewValue+";")
+		if err != nil {
+			return err
+		}
+		if plain, ok := dir.(*config.Directive); ok {
+			// change the parameters in place, the directive keeps its comments and formatting
+			params := replacement.GetParameters()
+			for i := range params {
+				if i < len(plain.Parameters) {
+					params[i].Leading = plain.Parameters[i].Leading
+				}
+			}
+			plain.Parameters = params
+			return nil
+		}
+		replacement.SetComment(dir.GetComment())
+		for _, comment := range dir.GetInlineComment() {
+			replacement.SetInlineComment(comment)
+		}
+		return config.ReplaceWith(block, dir, replacement)
+
+	case PatchMove:
+		// a moved directive is found by its parameters, its contents may differ
+		dir, err := findDirective(block, op.Directive, op.Value)
+		if err != nil {
+			return err
+		}
+		dest, _, err := resolvePath(conf, op.To)
+		if err != nil {
+			return err
+		}
+		remaining := dest.GetDirectives()
+		for i, d := range remaining {
+			if d == dir {
+				remaining = append(remaining[:i:i], remaining[i+1:]...)
+				break
+			}
+		}
+		return config.MoveTo(block, dir, dest, anchorIndex(remaining, op.After))
+	}
+	return fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// expectDirective returns the directive of a remove operation, checking that it has the
+// contents the patch expects
+func expectDirective(block config.IBlock, chain []config.IDirective, op PatchOperation) (config.IDirective, error) {
+	dir, err := findDirective(block, op.Directive, op.Value)
+	if err != nil || op.Config == "" {
+		return dir, err
+	}
+	expected, err := parseDirectiveIn(chain, op.Config)
+	if err != nil {
+		return nil, err
+	}
+	if canonical(expected) != canonical(dir) {
+		return nil, fmt.Errorf("%s differs from the expected directive", joinPath(op.Path, op.Directive))
+	}
+	return dir, nil
+}
+
+// resolvePath returns the block at a path and the block directives leading to it
+func resolvePath(conf *config.Config, path string) (config.IBlock, []config.IDirective, error) {
+	var block config.IBlock = conf.Block
+	var chain []config.IDirective
+	for _, segment := range splitPath(path) {
+		dir, err := findSegment(block, segment)
+		if err != nil {
+			return nil, nil, err
+		}
+		block = dir.GetBlock()
+		chain = append(chain, dir)
+	}
+	return block, chain, nil
+}
+
+// findSegment returns the block directive of a block named by a path segment
+func findSegment(block config.IBlock, segment string) (config.IDirective, error) {
+	var found []config.IDirective
+	for _, dir := range block.GetDirectives() {
+		if matchesSegment(dir, segment) {
+			found = append(found, dir)
+		}
+	}
+	return oneDirective(found, segment)
+}
+
+// findDirective returns the directive of a block named by a path segment for a block directive,
+// by name and parameters for the others
+func findDirective(block config.IBlock, name, value string) (config.IDirective, error) {
+	var found []config.IDirective
+	for _, dir := range block.GetDirectives() {
+		if matchesSegment(dir, name) || (dir.GetBlock() == nil && dir.GetName() == name && directiveValue(dir) == value) {
+			found = append(found, dir)
+		}
+	}
+	target := name
+	if value != "" && !strings.Contains(name, "[") {
+		target += " " + value
+	}
+	return oneDirective(found, target)
+}
+
+// oneDirective returns the only directive found, an error naming the target otherwise
+func oneDirective(found []config.IDirective, target string) (config.IDirective, error) {
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no %s", target)
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("%d directives match %s", len(found), target)
+}
+
+// matchesSegment tells whether a block directive is the one a path segment like location[/v1] names,
+// the key in brackets is the identity of the directive, or the server_name of a server
+func matchesSegment(dir config.IDirective, segment string) bool {
+	name, key, _ := strings.Cut(segment, "[")
+	if dir.GetBlock() == nil || dir.GetName() != name {
+		return false
+	}
+	key = strings.TrimSuffix(key, "]")
+	strict, loose := directiveIdentity(dir)
+	if name == "server" {
+		// servers are known by their names, the only unnamed one by the bare name
+		return key == strict || key == loose || (key == "" && serverName(dir) == "")
+	}
+	return key == strict
+}
+
+// splitPath splits a path into its segments, a / between brackets is part of the segment
+func splitPath(path string) []string {
+	var segments []string
+	depth, start := 0, 0
+	for i, r := range path {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				segments = append(segments, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if path != "" {
+		segments = append(segments, path[start:])
+	}
+	return segments
+}
+
+// describePath names a block path in messages
+func describePath(path string) string {
+	if path == "" {
+		return "the main context"
+	}
+	return path
+}
+
+// parseDirectiveIn parses a directive as if it was written in the blocks of chain, so that it
+// gets the type it has there like the server of an upstream
+func parseDirectiveIn(chain []config.IDirective, text string) (config.IDirective, error) {
+	var buf strings.Builder
+	for _, dir := range chain {
+		buf.WriteString(strings.TrimSpace(dir.GetName()+" "+directiveValue(dir)) + " {\n")
+	}
+	buf.WriteString(text + "\n")
+	buf.WriteString(strings.Repeat("}\n", len(chain)))
+
+	conf, err := parser.NewStringParser(buf.String(), parser.WithSkipValidDirectivesErr()).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid directive %q: %v", text, err)
+	}
+	directives := conf.GetDirectives()
+	for range chain {
+		if len(directives) != 1 || directives[0].GetBlock() == nil {
+			return nil, fmt.Errorf("invalid directive %q", text)
+		}
+		directives = directives[0].GetBlock().GetDirectives()
+	}
+	if len(directives) != 1 {
+		return nil, fmt.Errorf("invalid directive %q: want one directive", text)
+	}
+	dir := directives[0]
+	dir.SetParent(nil)
+	return dir, nil
+}
+
+// anchorIndex returns the position after the directive named by anchor, 0 for an empty anchor
+// and the end of the block when the anchor is not found
+func anchorIndex(directives []config.IDirective, anchor string) int {
+	if anchor == "" {
+		return 0
+	}
+	for i, dir := range directives {
+		if directiveAnchor(dir, directives) == anchor || matchesSegment(dir, anchor) {
+			return i + 1
+		}
+	}
+	return len(directives)
+}
+
+// insertAt inserts dir at index of block, appending it when index is out of range
+func insertAt(block config.IBlock, index int, dir config.IDirective) error {
+	directives := block.GetDirectives()
+	if index < 0 || index > len(directives) {
+		index = len(directives)
+	}
+	switch {
+	case index > 0:
+		return config.InsertAfter(block, directives[index-1], dir)
+	case len(directives) > 0:
+		return config.InsertBefore(block, directives[0], dir)
+	}
+	// inserting needs an anchor, an empty block gets dir moved in from a block holding it
+	holder := &config.Block{Directives: []config.IDirective{dir}}
+	return config.MoveTo(holder, dir, block, 0)
+}
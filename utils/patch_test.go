@@ -0,0 +1,170 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lefeck/gonginx/dumper"
+	"github.com/lefeck/gonginx/utils"
+	"gotest.tools/v3/assert"
+)
+
+const patchOld = `http {
+    server {
+        listen 80;
+        server_name a.com;
+        root /a;
+    }
+    server {
+        listen 80;
+        server_name b.com;
+        location /api {
+            proxy_pass http://api;
+        }
+    }
+    server {
+        listen 8080;
+        root /default;
+    }
+}`
+
+// makePatch diffs two configs and returns the patch between them
+func makePatch(t *testing.T, oldConf, newConf string) *utils.Patch {
+	t.Helper()
+	patch, err := utils.CompareConfigs(parse(t, oldConf), parse(t, newConf)).Patch()
+	assert.NilError(t, err)
+	return patch
+}
+
+func TestPatch_NamedAndUnnamedServers(t *testing.T) {
+	t.Parallel()
+	newConf := `http {
+    server {
+        listen 80;
+        server_name a.com;
+        root /a;
+    }
+    server {
+        listen 80;
+        server_name b.com;
+        location /api {
+            proxy_pass http://api;
+        }
+    }
+    server {
+        listen 8081;
+        root /default;
+    }
+}`
+	patch := makePatch(t, patchOld, newConf)
+	conf := parse(t, patchOld)
+	assert.NilError(t, utils.ApplyPatch(conf, patch))
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.IndentedStyle), newConf)
+}
+
+func TestPatch_Operations(t *testing.T) {
+	t.Parallel()
+	newConf := `http {
+    server {
+        listen 80;
+        server_name a.com;
+        root /srv/a;
+        add_header X-A 1;
+    }
+    server {
+        listen 80;
+        server_name b.com;
+    }
+    server {
+        listen 8080;
+        root /default;
+        location /api {
+            proxy_pass http://api;
+        }
+    }
+}`
+	patch := makePatch(t, patchOld, newConf)
+	operations := make([]string, len(patch.Operations))
+	for i, op := range patch.Operations {
+		operations[i] = op.String()
+	}
+	assert.DeepEqual(t, operations, []string{
+		"replace http/server[a.com]/root: /a -> /srv/a",
+		"add http/server[a.com]/add_header X-A 1",
+		"move http/server[b.com]/location[/api] to http/server/location[/api]",
+	})
+
+	// a patch is stored as JSON
+	data, err := json.Marshal(patch)
+	assert.NilError(t, err)
+	var decoded utils.Patch
+	assert.NilError(t, json.Unmarshal(data, &decoded))
+	assert.DeepEqual(t, &decoded, patch)
+
+	conf := parse(t, patchOld)
+	assert.NilError(t, utils.ApplyPatch(conf, &decoded))
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.IndentedStyle), newConf)
+
+	// the reverse patch undoes it
+	assert.NilError(t, utils.ApplyPatch(conf, &decoded, utils.WithReverse()))
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.IndentedStyle), patchOld)
+}
+
+func TestPatch_DryRun(t *testing.T) {
+	t.Parallel()
+	patch := makePatch(t, patchOld, strings.Replace(patchOld, "root /a;", "root /b;", 1))
+	conf := parse(t, patchOld)
+	assert.NilError(t, utils.ApplyPatch(conf, patch, utils.WithDryRun()))
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.IndentedStyle), patchOld)
+}
+
+func TestPatch_Errors(t *testing.T) {
+	t.Parallel()
+	newConf := strings.Replace(patchOld, "root /a;", "root /b;", 1)
+	patch := makePatch(t, patchOld, newConf)
+
+	// applying the reverse patch to the config it came from fails and leaves the config untouched
+	conf := parse(t, patchOld)
+	err := utils.ApplyPatch(conf, patch, utils.WithReverse())
+	assert.Error(t, err, "patch does not apply: operation 1 (replace http/server[a.com]/root: /b -> /a): no root /b")
+	assert.Equal(t, dumper.DumpConfig(conf, dumper.IndentedStyle), patchOld)
+
+	// applying twice fails the same way
+	assert.NilError(t, utils.ApplyPatch(conf, patch))
+	err = utils.ApplyPatch(conf, patch, utils.WithDryRun())
+	var patchErr *utils.PatchError
+	assert.Assert(t, errors.As(err, &patchErr))
+	assert.Equal(t, len(patchErr.Failures), 1)
+	assert.Equal(t, patchErr.Failures[0].Reason, "no root /a")
+
+	tests := []struct {
+		op     utils.PatchOperation
+		reason string
+	}{
+		// a block that is not there
+		{utils.PatchOperation{Op: utils.PatchRemove, Path: "http/server[c.com]", Directive: "root", Value: "/c"}, "no server[c.com]"},
+		// named servers are not named by their listen alone
+		{utils.PatchOperation{Op: utils.PatchRemove, Path: "http", Directive: "server[80]"}, "no server[80]"},
+		// path segments only name block directives
+		{utils.PatchOperation{Op: utils.PatchRemove, Path: "http/server[a.com]/root", Directive: "root", Value: "/a"}, "no root"},
+		// the directive to remove differs from the one of the patch
+		{utils.PatchOperation{Op: utils.PatchRemove, Path: "http", Directive: "server[a.com]", Config: "server {\n    server_name a.com;\n}"}, "http/server[a.com] differs from the expected directive"},
+		{utils.PatchOperation{Op: utils.PatchAdd, Path: "http/server[a.com]", Directive: "root", Value: "/a", Config: "root /a;"}, "root is already in http/server[a.com]"},
+		{utils.PatchOperation{Op: utils.PatchAdd, Path: "", Directive: "user", Config: "user nginx; pid /run/nginx.pid;"}, `invalid directive "user nginx; pid /run/nginx.pid;": want one directive`},
+		{utils.PatchOperation{Op: "rename", Path: "http"}, `unknown operation "rename"`},
+	}
+	for _, tt := range tests {
+		err := utils.ApplyPatch(parse(t, patchOld), &utils.Patch{Operations: []utils.PatchOperation{tt.op}})
+		assert.Assert(t, errors.As(err, &patchErr), "%v", tt.op)
+		assert.Equal(t, patchErr.Failures[0].Reason, tt.reason, "%v", tt.op)
+	}
+
+	// with two unnamed servers the bare name is ambiguous
+	conf = parse(t, "http {\n    server {\n        listen 81;\n    }\n    server {\n        listen 82;\n    }\n}")
+	err = utils.ApplyPatch(conf, &utils.Patch{Operations: []utils.PatchOperation{
+		{Op: utils.PatchAdd, Path: "http/server", Directive: "gzip", Config: "gzip on;"},
+	}})
+	assert.ErrorContains(t, err, "2 directives match server")
+}
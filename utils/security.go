@@ -347,46 +347,75 @@ func (sc *SecurityChecker) checkSecurityHeaders() {
 	}
 
 	for _, httpBlock := range httpBlocks {
-		if http, ok := httpBlock.(*config.HTTP); ok {
-			foundHeaders := make(map[string]bool)
-
-			// Check in http block
-			sc.checkHeadersInDirectives(http.Directives, foundHeaders, "http")
+		http, ok := httpBlock.(*config.HTTP)
+		if !ok {
+			continue
+		}
+
+		// the servers and locations answering requests, add_header of an inner block
+		// shadows all the add_header of the blocks around it
+		blocks := []config.IDirective{}
+		_ = config.Walk(http, config.VisitorFuncs{EnterFunc: func(c *config.Cursor) error {
+			switch c.Directive().(type) {
+			case *config.Server, *config.Location:
+				blocks = append(blocks, c.Directive())
+			}
+			return nil
+		}}, config.WalkIncludes(), config.WalkInContext("http"))
+		if len(blocks) == 0 {
+			blocks = append(blocks, http)
+		}
+
+		missing := make(map[string][]config.IDirective)
+		for _, block := range blocks {
+			found := make(map[string]bool)
+			for _, e := range config.Effective(block) {
+				if dir := e.Directive; dir.GetName() == "add_header" && len(dir.GetParameters()) >= 2 {
+					found[strings.ToLower(dir.GetParameters()[0].GetValue())] = true
+				}
+			}
+			for header := range securityHeaders {
+				if !found[strings.ToLower(header)] {
+					missing[header] = append(missing[header], block)
+				}
+			}
+		}
 
-			// Check in servers
-			for _, server := range http.Servers {
-				sc.checkHeadersInDirectives(server.GetDirectives(), foundHeaders, "server")
-			}
-
-			// Report missing headers
-			for header, recommendation := range securityHeaders {
-				if !foundHeaders[strings.ToLower(header)] {
-					sc.addIssue(SecurityWarning, "Security Headers",
-						fmt.Sprintf("Missing %s header", header),
-						fmt.Sprintf("Security header %s not configured", header),
-						"add_header", "", "server",
-						fmt.Sprintf("Add 'add_header %s \"%s\" always;'", header, recommendation),
-						"https://owasp.org/www-project-secure-headers/")
-				} else {
-					sc.addPassed(fmt.Sprintf("Security header %s configured", header))
+		// Report missing headers
+		for header, recommendation := range securityHeaders {
+			switch without := missing[header]; {
+			case len(without) == len(blocks):
+				sc.addIssue(SecurityWarning, "Security Headers",
+					fmt.Sprintf("Missing %s header", header),
+					fmt.Sprintf("Security header %s not configured", header),
+					"add_header", "", "server",
+					fmt.Sprintf("Add 'add_header %s \"%s\" always;'", header, recommendation),
+					"https://owasp.org/www-project-secure-headers/")
+			case len(without) > 0:
+				var where []string
+				for _, block := range without {
+					where = append(where, blockDescription(block))
 				}
+				sc.addIssue(SecurityWarning, "Security Headers",
+					fmt.Sprintf("%s header not in effect everywhere", header),
+					fmt.Sprintf("Security header %s is not in effect in %s, the add_header of a block drops those of the blocks around it", header, strings.Join(where, ", ")),
+					"add_header", "", without[0].GetName(),
+					fmt.Sprintf("Repeat 'add_header %s \"%s\" always;' in blocks giving their own add_header", header, recommendation),
+					"https://nginx.org/en/docs/http/ngx_http_headers_module.html#add_header")
+			default:
+				sc.addPassed(fmt.Sprintf("Security header %s configured", header))
 			}
 		}
 	}
 }
 
-func (sc *SecurityChecker) checkHeadersInDirectives(directives []config.IDirective, foundHeaders map[string]bool, context string) {
-	for _, dir := range directives {
-		if dir.GetName() == "add_header" && len(dir.GetParameters()) >= 2 {
-			header := strings.ToLower(dir.GetParameters()[0].GetValue())
-			foundHeaders[header] = true
-		}
-
-		// Check in nested blocks (locations)
-		if dir.GetBlock() != nil {
-			sc.checkHeadersInDirectives(dir.GetBlock().GetDirectives(), foundHeaders, context)
-		}
+// blockDescription names a server or location block for a report
+func blockDescription(block config.IDirective) string {
+	parts := []string{block.GetName()}
+	for _, p := range block.GetParameters() {
+		parts = append(parts, p.GetValue())
 	}
+	return fmt.Sprintf("%s (line %d)", strings.Join(parts, " "), block.GetLine())
 }
 
 // checkDirectoryTraversal checks for directory traversal vulnerabilities
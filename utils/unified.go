@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/dumper"
+)
+
+// the ANSI escape sequences of the colored output, like git diff
+const (
+	colorBold  = "\x1b[1m"
+	colorCyan  = "\x1b[36m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorReset = "\x1b[0m"
+)
+
+// UnifiedOption configures UnifiedDiff and UnifiedConfigDiff
+type UnifiedOption func(*unifiedOptions)
+
+type unifiedOptions struct {
+	context int
+	color   bool
+	style   *dumper.Style
+}
+
+// WithContextLines sets the number of unchanged lines shown around a change, 3 by default
+func WithContextLines(n int) UnifiedOption {
+	return func(o *unifiedOptions) {
+		o.context = max(n, 0)
+	}
+}
+
+// WithColor colors the headers, hunk headers, removed and added lines with ANSI escape sequences
+func WithColor() UnifiedOption {
+	return func(o *unifiedOptions) {
+		o.color = true
+	}
+}
+
+// WithDumpStyle sets the style configs are dumped with before being compared, dumper.IndentedStyle by default
+func WithDumpStyle(style *dumper.Style) UnifiedOption {
+	return func(o *unifiedOptions) {
+		o.style = style
+	}
+}
+
+func newUnifiedOptions(opts []UnifiedOption) *unifiedOptions {
+	options := &unifiedOptions{context: 3, style: dumper.IndentedStyle}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// UnifiedConfigDiff returns the changes between two configs as a unified diff of their dumped
+// files, the files of their include directives included. Files are paired by their path relative
+// to the directory of the main file, a file only one side has is compared with /dev/null.
+// It returns an empty string when the configs are written the same.
+func UnifiedConfigDiff(oldConfig, newConfig *config.Config, opts ...UnifiedOption) string {
+	options := newUnifiedOptions(opts)
+	oldFiles := dumpFiles(oldConfig, options.style, "old")
+	newFiles := dumpFiles(newConfig, options.style, "new")
+
+	keys := make([]string, 0, len(oldFiles)+len(newFiles))
+	for key := range oldFiles {
+		keys = append(keys, key)
+	}
+	for key := range newFiles {
+		if _, ok := oldFiles[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	// the main file first, then the included files by path
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == "") != (keys[j] == "") {
+			return keys[i] == ""
+		}
+		return keys[i] < keys[j]
+	})
+
+	var buf strings.Builder
+	for _, key := range keys {
+		oldFile, newFile := oldFiles[key], newFiles[key]
+		oldName, newName := oldFile.name, newFile.name
+		if oldName == "" {
+			oldName = "/dev/null"
+		}
+		if newName == "" {
+			newName = "/dev/null"
+		}
+		buf.WriteString(unifiedDiff(oldName, newName, oldFile.text, newFile.text, options))
+	}
+	return buf.String()
+}
+
+// dumpedFile is a file of a config as the dumper writes it
+type dumpedFile struct {
+	name string
+	text string
+}
+
+// dumpFiles dumps the main file and the included files of a config, by path relative to the
+// directory of the main file, the main file has the empty key. fallback names a config parsed
+// from a string.
+func dumpFiles(conf *config.Config, style *dumper.Style, fallback string) map[string]dumpedFile {
+	files := make(map[string]dumpedFile)
+	if conf == nil {
+		return files
+	}
+	dir := filepath.Dir(conf.FilePath)
+	var add func(c *config.Config, main bool)
+	add = func(c *config.Config, main bool) {
+		key, name := "", c.FilePath
+		if !main {
+			key = c.FilePath
+			if rel, err := filepath.Rel(dir, c.FilePath); err == nil && conf.FilePath != "" {
+				key = rel
+			}
+		}
+		if name == "" {
+			name = fallback
+		}
+		if _, seen := files[key]; seen {
+			return
+		}
+		text := dumper.DumpConfig(c, style)
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		files[key] = dumpedFile{name: name, text: text}
+		for _, directive := range c.FindDirectives("include") {
+			if include, ok := directive.(*config.Include); ok {
+				for _, included := range include.Configs {
+					add(included, false)
+				}
+			}
+		}
+	}
+	add(conf, true)
+	return files
+}
+
+// UnifiedDiff returns the changes between two texts in unified format, empty if they are equal
+func UnifiedDiff(oldName, newName, a, b string, opts ...UnifiedOption) string {
+	return unifiedDiff(oldName, newName, a, b, newUnifiedOptions(opts))
+}
+
+func unifiedDiff(oldName, newName, a, b string, options *unifiedOptions) string {
+	if a == b {
+		return ""
+	}
+	oldLines, newLines := splitLines(a), splitLines(b)
+	ops := diffLines(oldLines, newLines)
+	context := options.context
+	paint := func(color, text string) string {
+		if !options.color {
+			return text
+		}
+		return color + text + colorReset
+	}
+
+	var buf strings.Builder
+	buf.WriteString(paint(colorBold, "--- "+oldName) + "\n")
+	buf.WriteString(paint(colorBold, "+++ "+newName) + "\n")
+	for start := 0; start < len(ops); {
+		// find the next change and the hunk around it
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		from := max(start-context, 0)
+		end := start
+		for unchanged := 0; end < len(ops) && unchanged <= 2*context; end++ {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		// trim the trailing context to context lines
+		to := end
+		for to > start && ops[to-1].kind == ' ' {
+			to--
+		}
+		to = min(to+context, len(ops))
+
+		oldStart, newStart := ops[from].oldLine, ops[from].newLine
+		oldCount, newCount := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		header := fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		buf.WriteString(paint(colorCyan, header) + "\n")
+		for _, op := range ops[from:to] {
+			line := string(op.kind) + op.text
+			switch op.kind {
+			case '-':
+				line = paint(colorRed, line)
+			case '+':
+				line = paint(colorGreen, line)
+			}
+			buf.WriteString(line + "\n")
+		}
+		start = to
+	}
+	return buf.String()
+}
+
+// lineOp is one line of a diff: ' ' kept, '-' removed or '+' added
+type lineOp struct {
+	kind    byte
+	text    string
+	oldLine int // 1-based line in the old text, of the next old line for additions
+	newLine int // 1-based line in the new text, of the next new line for removals
+}
+
+// diffLines turns the longest common subsequence of the lines, the one CompareConfigs uses for
+// repeated directives, into an edit script. Removals come before the additions that replace them
+func diffLines(a, b []string) []lineOp {
+	var ops []lineOp
+	i, j := 0, 0
+	for _, pair := range append(longestCommonSubsequence(a, b), directivePair{old: len(a), new: len(b)}) {
+		for ; i < pair.old; i++ {
+			ops = append(ops, lineOp{'-', a[i], i + 1, j + 1})
+		}
+		for ; j < pair.new; j++ {
+			ops = append(ops, lineOp{'+', b[j], i + 1, j + 1})
+		}
+		if i < len(a) {
+			ops = append(ops, lineOp{' ', a[i], i + 1, j + 1})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+// hunkRange formats the start,count pair of a hunk header
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
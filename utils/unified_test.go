@@ -0,0 +1,100 @@
+package utils_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/lefeck/gonginx/config"
+	"github.com/lefeck/gonginx/parser"
+	"github.com/lefeck/gonginx/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	assert.Equal(t, utils.UnifiedDiff("a", "b", a, b), `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`)
+	assert.Equal(t, utils.UnifiedDiff("a", "b", a, a), "")
+	assert.Equal(t, utils.UnifiedDiff("a", "b", "", "x\n"), "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n")
+}
+
+func TestUnifiedDiff_Options(t *testing.T) {
+	t.Parallel()
+	a := "1\n2\n3\n4\n5\n"
+	b := "1\ntwo\nthree\n4\n5\n"
+
+	// the removed lines come before the added ones replacing them
+	assert.Equal(t, utils.UnifiedDiff("a", "b", a, b, utils.WithContextLines(0)),
+		"--- a\n+++ b\n@@ -2,2 +2,2 @@\n-2\n-3\n+two\n+three\n")
+	assert.Equal(t, utils.UnifiedDiff("a", "b", a, b, utils.WithContextLines(1)),
+		"--- a\n+++ b\n@@ -1,4 +1,4 @@\n 1\n-2\n-3\n+two\n+three\n 4\n")
+	assert.Equal(t, utils.UnifiedDiff("a", "b", "x\n", "", utils.WithColor()),
+		"\x1b[1m--- a\x1b[0m\n\x1b[1m+++ b\x1b[0m\n\x1b[36m@@ -1 +0,0 @@\x1b[0m\n\x1b[31m-x\x1b[0m\n")
+}
+
+func TestUnifiedConfigDiff(t *testing.T) {
+	t.Parallel()
+	parseFS := func(fsys fstest.MapFS) *config.Config {
+		p, err := parser.NewParser("nginx.conf", parser.WithFS(fsys), parser.WithIncludeParsing())
+		assert.NilError(t, err)
+		conf, err := p.Parse()
+		assert.NilError(t, err)
+		return conf
+	}
+	oldConf := parseFS(fstest.MapFS{
+		"nginx.conf":    {Data: []byte("http {\n    include conf.d/*.conf;\n}\n")},
+		"conf.d/a.conf": {Data: []byte("server {\n    listen 80;\n}\n")},
+		"conf.d/b.conf": {Data: []byte("server {\n    listen 81;\n}\n")},
+	})
+	newConf := parseFS(fstest.MapFS{
+		"nginx.conf":    {Data: []byte("http {\n    gzip on;\n    include conf.d/*.conf;\n}\n")},
+		"conf.d/a.conf": {Data: []byte("server {\n    listen 8080;\n}\n")},
+		"conf.d/c.conf": {Data: []byte("server {\n    listen 82;\n}\n")},
+	})
+
+	// the main file first, then the included files by path, b.conf is gone and c.conf is new
+	assert.Equal(t, utils.UnifiedConfigDiff(oldConf, newConf), `--- nginx.conf
++++ nginx.conf
+@@ -1,3 +1,4 @@
+ http {
++    gzip on;
+     include conf.d/*.conf;
+ }
+--- conf.d/a.conf
++++ conf.d/a.conf
+@@ -1,3 +1,3 @@
+ server {
+-    listen 80;
++    listen 8080;
+ }
+--- conf.d/b.conf
++++ /dev/null
+@@ -1,3 +0,0 @@
+-server {
+-    listen 81;
+-}
+--- /dev/null
++++ conf.d/c.conf
+@@ -0,0 +1,3 @@
++server {
++    listen 82;
++}
+`)
+	assert.Equal(t, utils.UnifiedConfigDiff(oldConf, oldConf), "")
+}